@@ -0,0 +1,119 @@
+package api_errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Code is a stable, machine-readable error identifier. Clients switch on these,
+// so existing values must never be renamed.
+type Code string
+
+const (
+	CodeValidationFailed     Code = "VALIDATION_FAILED"
+	CodeMalformedBody        Code = "MALFORMED_BODY"
+	CodeInvalidID            Code = "INVALID_ID"
+	CodeUnauthorized         Code = "UNAUTHORIZED"
+	CodeInvalidToken         Code = "INVALID_TOKEN"
+	CodeInvalidCredentials   Code = "INVALID_CREDENTIALS"
+	CodeForbidden            Code = "FORBIDDEN"
+	CodeNotFound             Code = "NOT_FOUND"
+	CodeMethodNotAllowed     Code = "METHOD_NOT_ALLOWED"
+	CodeProfileNotFound      Code = "PROFILE_NOT_FOUND"
+	CodeUserNotFound         Code = "USER_NOT_FOUND"
+	CodeUserAlreadyExists    Code = "USER_ALREADY_EXISTS"
+	CodeSkillNotFound        Code = "SKILL_NOT_FOUND"
+	CodeAlreadyEndorsed      Code = "ALREADY_ENDORSED"
+	CodeClubNotFound         Code = "CLUB_NOT_FOUND"
+	CodeRequestNotFound      Code = "VERIFICATION_REQUEST_NOT_FOUND"
+	CodeAlreadyVerified      Code = "ALREADY_VERIFIED"
+	CodeRequestPending       Code = "VERIFICATION_PENDING"
+	CodeRequestClosed        Code = "VERIFICATION_CLOSED"
+	CodeShortlistNotFound    Code = "SHORTLIST_NOT_FOUND"
+	CodeAlreadyShortlisted   Code = "ALREADY_SHORTLISTED"
+	CodeConversationNotFound Code = "CONVERSATION_NOT_FOUND"
+	CodeUserBlocked          Code = "USER_BLOCKED"
+	CodeMinorContact         Code = "MINOR_CONTACT_RESTRICTED"
+	CodeNotMinor             Code = "NOT_A_MINOR"
+	CodeInvalidConsentToken  Code = "INVALID_CONSENT_TOKEN"
+	CodeTrialNotFound        Code = "TRIAL_NOT_FOUND"
+	CodeApplicationNotFound  Code = "APPLICATION_NOT_FOUND"
+	CodeAlreadyApplied       Code = "ALREADY_APPLIED"
+	CodeTrialClosed          Code = "TRIAL_CLOSED"
+	CodeTrialFull            Code = "TRIAL_FULL"
+	CodeInvalidTransition    Code = "INVALID_STATUS_TRANSITION"
+	CodeInternal             Code = "INTERNAL_ERROR"
+)
+
+// FieldError describes why a single request field was rejected. Field uses the
+// JSON name the client sent, Rule the validation rule that failed.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is the error type handlers report through the context. Cause is logged
+// but never sent to the client.
+type Error struct {
+	Status int
+	Code   Code
+	Detail string
+	Fields []FieldError
+	Cause  error
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error { return e.Cause }
+
+// WithCause attaches the underlying error for logging.
+func (e *Error) WithCause(err error) *Error {
+	e.Cause = err
+	return e
+}
+
+func New(status int, code Code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func BadRequest(code Code, detail string) *Error {
+	return New(http.StatusBadRequest, code, detail)
+}
+
+func Unauthorized(code Code, detail string) *Error {
+	return New(http.StatusUnauthorized, code, detail)
+}
+
+func Forbidden(code Code, detail string) *Error {
+	return New(http.StatusForbidden, code, detail)
+}
+
+func NotFound(code Code, detail string) *Error {
+	return New(http.StatusNotFound, code, detail)
+}
+
+func Conflict(code Code, detail string) *Error {
+	return New(http.StatusConflict, code, detail)
+}
+
+// Internal reports an unexpected failure. detail is shown to the client, cause
+// only ends up in the server log.
+func Internal(detail string, cause error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, detail).WithCause(cause)
+}
+
+// InvalidID is returned when a numeric path parameter cannot be parsed.
+func InvalidID(param string) *Error {
+	return &Error{
+		Status: http.StatusBadRequest,
+		Code:   CodeInvalidID,
+		Detail: "Invalid " + param + " format.",
+		Fields: []FieldError{{Field: param, Rule: "numeric", Message: "must be a positive integer"}},
+	}
+}
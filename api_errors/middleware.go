@@ -0,0 +1,117 @@
+package api_errors
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	ProblemContentType = "application/problem+json"
+	requestIDKey       = "requestID"
+)
+
+// Problem is the RFC 7807 body sent for every error response. Code, RequestID
+// and Errors are extension members.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// Abort records err on the context and stops the handler chain. The response
+// itself is written by Handler.
+func Abort(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
+
+// RequestID makes sure every request carries an ID, reusing the client's
+// X-Request-ID when it looks sane, and echoes it on the response.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			buf := make([]byte, 16)
+			rand.Read(buf)
+			id = hex.EncodeToString(buf)
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID assigned by RequestID.
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// Handler renders the last error recorded on the context as problem+json,
+// unless the handler already wrote a response.
+func Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		var apiErr *Error
+		if !errors.As(err, &apiErr) {
+			apiErr = Internal("An unexpected error occurred.", err)
+		}
+		Render(c, apiErr)
+	}
+}
+
+// Render writes apiErr as an RFC 7807 response and logs server-side failures.
+func Render(c *gin.Context, apiErr *Error) {
+	requestID := GetRequestID(c)
+	if apiErr.Status >= http.StatusInternalServerError {
+		log.Printf("[%s] %s %s: %v", requestID, c.Request.Method, c.Request.URL.Path, apiErr)
+	}
+
+	body, _ := json.Marshal(Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(apiErr.Status),
+		Status:    apiErr.Status,
+		Detail:    apiErr.Detail,
+		Instance:  c.Request.URL.Path,
+		Code:      apiErr.Code,
+		RequestID: requestID,
+		Errors:    apiErr.Fields,
+	})
+	c.Data(apiErr.Status, ProblemContentType, body)
+}
+
+// Recovery turns panics into INTERNAL_ERROR problems instead of an empty 500.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		Render(c, Internal("An unexpected error occurred.", fmt.Errorf("panic: %v", recovered)))
+		c.Abort()
+	})
+}
+
+// NoRoute answers unknown paths with a NOT_FOUND problem.
+func NoRoute(c *gin.Context) {
+	Render(c, NotFound(CodeNotFound, "No endpoint matches "+c.Request.URL.Path+"."))
+}
+
+// NoMethod answers known paths called with the wrong verb.
+func NoMethod(c *gin.Context) {
+	Render(c, New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, c.Request.Method+" is not supported on "+c.Request.URL.Path+"."))
+}
@@ -0,0 +1,169 @@
+package api_errors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// messages maps validator rules to the human-readable text used in field
+// errors. The raw validator output is never exposed to clients.
+var messages = map[string]func(param string) string{
+	"required": func(string) string { return "is required" },
+	"email":    func(string) string { return "must be a valid email address" },
+	"url":      func(string) string { return "must be a valid URL" },
+	"http_url": func(string) string { return "must be an http or https URL" },
+	"oneof": func(p string) string {
+		return "must be one of: " + strings.Join(strings.Fields(p), ", ")
+	},
+	"min": func(p string) string { return "must be at least " + p },
+	"max": func(p string) string { return "must be at most " + p },
+	"gte": func(p string) string { return "must be greater than or equal to " + p },
+	"lte": func(p string) string { return "must be less than or equal to " + p },
+	"gt":  func(p string) string { return "must be greater than " + p },
+	"lt":  func(p string) string { return "must be less than " + p },
+	"len": func(p string) string { return "must have length " + p },
+	"required_without": func(p string) string {
+		return "is required unless " + snakeCase(p) + " is given"
+	},
+	"nefield":  func(p string) string { return "must differ from " + snakeCase(p) },
+	"gtfield":  func(p string) string { return "must be after " + snakeCase(p) },
+	"ltefield": func(p string) string { return "must not be after " + snakeCase(p) },
+	"eq":       func(p string) string { return "must be " + p },
+	"timezone": func(string) string { return "must be an IANA time zone such as Europe/London" },
+	"unique":   func(string) string { return "must not contain duplicates" },
+	"required_if": func(p string) string {
+		return "is required when " + strings.Join(strings.Fields(p), " is ")
+	},
+	"required_with": func(p string) string {
+		return "is required when " + snakeCase(p) + " is given"
+	},
+}
+
+// snakeCase turns the Go field names validators use as parameters
+// (StartsAt, ProfileID) into the JSON names clients know.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		upper := r >= 'A' && r <= 'Z'
+		if upper && i > 0 {
+			prev := rune(name[i-1])
+			if prev >= 'a' && prev <= 'z' {
+				b.WriteByte('_')
+			}
+		}
+		if upper {
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// RegisterMessage sets the field error text for a validation rule, typically a
+// custom validator tag.
+func RegisterMessage(rule string, message func(param string) string) {
+	messages[rule] = message
+}
+
+func init() {
+	// Report JSON field names (profile_id) instead of Go ones (ProfileID).
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				name = strings.Split(field.Tag.Get("form"), ",")[0]
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// FieldErrorFor builds a field error using the registered message for rule.
+func FieldErrorFor(field, rule, param string) FieldError {
+	message := "is invalid"
+	if fn, ok := messages[rule]; ok {
+		message = fn(param)
+	}
+	return FieldError{Field: field, Rule: rule, Message: message}
+}
+
+// Validation converts the error returned by gin's ShouldBind* helpers into a
+// VALIDATION_FAILED (or MALFORMED_BODY) error with per-field details.
+func Validation(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	var timeErr *time.ParseError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, FieldErrorFor(fieldPath(fe), fe.Tag(), fe.Param()))
+		}
+		return Invalid(fields...).WithCause(err)
+	case errors.As(err, &typeErr):
+		field := FieldError{Field: typeErr.Field, Rule: "type", Message: fmt.Sprintf("must be a %s", jsonType(typeErr.Type))}
+		return Invalid(field).WithCause(err)
+	case errors.As(err, &timeErr):
+		return BadRequest(CodeMalformedBody, "Dates must be RFC 3339 timestamps, e.g. 2024-08-17T15:00:00Z.").WithCause(err)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return BadRequest(CodeMalformedBody, "Request body is not valid JSON.").WithCause(err)
+	default:
+		return BadRequest(CodeMalformedBody, "Request could not be parsed.").WithCause(err)
+	}
+}
+
+// Invalid builds a VALIDATION_FAILED error from field errors. Handlers use it
+// directly for rules that can only be checked after binding.
+func Invalid(fields ...FieldError) *Error {
+	return &Error{
+		Status: http.StatusBadRequest,
+		Code:   CodeValidationFailed,
+		Detail: "One or more fields are invalid.",
+		Fields: fields,
+	}
+}
+
+// fieldPath strips the top-level struct name from the validator namespace:
+// "AddSkill.profile.first_name" becomes "profile.first_name".
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if _, rest, ok := strings.Cut(ns, "."); ok {
+		return rest
+	}
+	return fe.Field()
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	default:
+		return "string"
+	}
+}
@@ -0,0 +1,254 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/utils"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+const (
+	PreferredFootLeft  = "left"
+	PreferredFootRight = "right"
+	PreferredFootBoth  = "both"
+)
+
+// PreferredFeet lists every accepted Profile.PreferredFoot value.
+var PreferredFeet = []string{PreferredFootLeft, PreferredFootRight, PreferredFootBoth}
+
+// PlayingStyles is the curated set of playing style tags a profile can carry.
+var PlayingStyles = []string{
+	"ball_playing_defender",
+	"stopper",
+	"overlapping_fullback",
+	"inverted_fullback",
+	"ball_winner",
+	"deep_lying_playmaker",
+	"box_to_box",
+	"playmaker",
+	"inverted_winger",
+	"traditional_winger",
+	"dribbler",
+	"target_man",
+	"poacher",
+	"pressing_forward",
+	"false_nine",
+	"aerial_threat",
+	"set_piece_specialist",
+	"sweeper_keeper",
+	"shot_stopper",
+}
+
+// Physical test types and the unit each one is recorded in.
+const (
+	PhysicalTestSprint10m    = "sprint_10m"
+	PhysicalTestSprint30m    = "sprint_30m"
+	PhysicalTestSprint40m    = "sprint_40m"
+	PhysicalTestYoYoIR1      = "yo_yo_ir1"
+	PhysicalTestBeepTest     = "beep_test"
+	PhysicalTestCooper       = "cooper_test"
+	PhysicalTestVerticalJump = "vertical_jump"
+)
+
+var PhysicalTestUnits = map[string]string{
+	PhysicalTestSprint10m:    "s",
+	PhysicalTestSprint30m:    "s",
+	PhysicalTestSprint40m:    "s",
+	PhysicalTestYoYoIR1:      "m",
+	PhysicalTestBeepTest:     "level",
+	PhysicalTestCooper:       "m",
+	PhysicalTestVerticalJump: "cm",
+}
+
+// Where a physical test result comes from, from least to most trustworthy.
+const (
+	TestSourceSelfReported = "self_reported"
+	TestSourceCoach        = "coach"
+	TestSourceClub         = "club"
+	TestSourceCombine      = "combine"
+)
+
+var PhysicalTestSources = []string{TestSourceSelfReported, TestSourceCoach, TestSourceClub, TestSourceCombine}
+
+type PhysicalTest struct {
+	gorm.Model
+
+	ProfileID    uint       `gorm:"not null;index" json:"profile_id"`
+	TestType     string     `gorm:"size:20;not null" json:"test_type"`
+	Value        float64    `gorm:"not null" json:"value"`
+	Unit         string     `gorm:"size:10" json:"unit"`
+	TestDate     *time.Time `json:"test_date"`
+	Source       string     `gorm:"size:20;default:'self_reported'" json:"source"`
+	SourceDetail string     `gorm:"size:200" json:"source_detail"`
+}
+
+type AddPhysicalTest struct {
+	ProfileID    uint       `json:"profile_id" binding:"required"`
+	TestType     string     `json:"test_type" binding:"required,physical_test"`
+	Value        float64    `json:"value" binding:"required,gt=0"`
+	TestDate     *time.Time `json:"test_date" binding:"required,notfuture"`
+	Source       string     `json:"source" binding:"omitempty,test_source"`
+	SourceDetail string     `json:"source_detail" binding:"max=200"`
+}
+
+// UpdateProfileAttributes changes the football attributes of a profile. Only
+// the fields that are present are updated.
+type UpdateProfileAttributes struct {
+	ProfileID      uint     `json:"profile_id" binding:"required"`
+	PreferredFoot  *string  `json:"preferred_foot" binding:"omitempty,oneof=left right both"`
+	WeakFootRating *int     `json:"weak_foot_rating" binding:"omitempty,gte=1,lte=5"`
+	PlayingStyles  []string `json:"playing_styles" binding:"omitempty,max=5,dive,playing_style"`
+	Height         *float64 `json:"height" binding:"omitempty,gt=0"`
+	HeightUnit     string   `json:"height_unit" binding:"omitempty,oneof=cm in"`
+	Weight         *float64 `json:"weight" binding:"omitempty,gt=0"`
+	WeightUnit     string   `json:"weight_unit" binding:"omitempty,oneof=kg lb"`
+	TransferStatus *string  `json:"transfer_status" binding:"omitempty,oneof=not_available available_for_transfer free_agent open_to_trials"`
+	Location       *string  `json:"location" binding:"omitempty,max=100"`
+
+	LocationInput
+}
+
+// UnitsQuery selects the unit system used for heights and weights in output,
+// and the language of country names.
+type UnitsQuery struct {
+	Units string `form:"units" json:"units,omitempty" binding:"omitempty,oneof=metric imperial"`
+	Lang  string `form:"lang" json:"lang,omitempty" binding:"max=35"`
+}
+
+// AfterFind marks stored heights and weights with their canonical units and
+// derives the player's age from Dob.
+func (p *Profile) AfterFind(tx *gorm.DB) error {
+	p.HeightUnit = utils.UnitCentimetre
+	p.WeightUnit = utils.UnitKilogram
+	if !p.Dob.IsZero() {
+		p.Age = utils.AgeOn(p.Dob, time.Now())
+		p.IsMinor = p.Age < AdultAge
+	}
+	return nil
+}
+
+// AfterCreate mirrors AfterFind so freshly created profiles render the same way.
+func (p *Profile) AfterCreate(tx *gorm.DB) error {
+	return p.AfterFind(tx)
+}
+
+// ConvertUnits rewrites Height and Weight into system ("metric" or
+// "imperial") for output. The profile must not be saved afterwards.
+func (p *Profile) ConvertUnits(system string) {
+	p.Height, p.HeightUnit = utils.HeightFromCm(p.Height, system)
+	p.Weight, p.WeightUnit = utils.WeightFromKg(p.Weight, system)
+}
+
+func AddPhysicalTestToProfile(db *gorm.DB, test *PhysicalTest) error {
+	result := db.Create(test)
+	return result.Error
+}
+
+func (h *DBHandler) AddPhysicalTestToProfileGinHandler(c *gin.Context) {
+	var input AddPhysicalTest
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	profile, err := requireOwnProfile(c, h.DB, input.ProfileID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	source := input.Source
+	if source == "" {
+		source = TestSourceSelfReported
+	}
+	test := PhysicalTest{
+		ProfileID:    profile.ID,
+		TestType:     input.TestType,
+		Value:        input.Value,
+		Unit:         PhysicalTestUnits[input.TestType],
+		TestDate:     input.TestDate,
+		Source:       source,
+		SourceDetail: input.SourceDetail,
+	}
+	if err := AddPhysicalTestToProfile(h.DB, &test); err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to add physical test.", err))
+		return
+	}
+	notifyProfileWebhooks(h.DB, WebhookProfileUpdated, test.ProfileID)
+	c.JSON(http.StatusCreated, test)
+}
+
+func (h *DBHandler) UpdateProfileAttributesGinHandler(c *gin.Context) {
+	var input UpdateProfileAttributes
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	profile, err := requireOwnProfile(c, h.DB, input.ProfileID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	updates := map[string]any{}
+	if input.PreferredFoot != nil {
+		updates["preferred_foot"] = *input.PreferredFoot
+	}
+	if input.WeakFootRating != nil {
+		updates["weak_foot_rating"] = *input.WeakFootRating
+	}
+	if input.PlayingStyles != nil {
+		updates["playing_styles"] = pq.StringArray(input.PlayingStyles)
+	}
+	if input.TransferStatus != nil {
+		updates["transfer_status"] = *input.TransferStatus
+	}
+	if input.Height != nil {
+		updates["height"] = utils.Round(utils.HeightToCm(*input.Height, input.HeightUnit), 1)
+	}
+	if input.Weight != nil {
+		updates["weight"] = utils.Round(utils.WeightToKg(*input.Weight, input.WeightUnit), 1)
+	}
+	// A new location replaces the old one entirely, free text included.
+	if input.Location != nil || input.LocationInput != (LocationInput{}) {
+		text := ""
+		if input.Location != nil {
+			text = *input.Location
+		}
+		location, text, err := ResolveLocation(text, input.LocationInput)
+		if err != nil {
+			api_errors.Abort(c, api_errors.Internal("Could not geocode the location.", err))
+			return
+		}
+		updates["location"] = text
+		updates["city"] = location.City
+		updates["region"] = location.Region
+		updates["country_code"] = location.CountryCode
+		updates["latitude"] = location.Latitude
+		updates["longitude"] = location.Longitude
+	}
+
+	if len(updates) > 0 {
+		if err := h.DB.Model(&profile).Updates(updates).Error; err != nil {
+			api_errors.Abort(c, api_errors.Internal("Failed to update profile attributes.", err))
+			return
+		}
+		notifyProfileWebhooks(h.DB, WebhookProfileUpdated, profile.ID)
+	}
+	if err := h.DB.First(&profile, profile.ID).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve profile.", err))
+		return
+	}
+	profile.RedactMinor()
+	c.JSON(http.StatusOK, profile)
+}
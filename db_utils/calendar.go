@@ -0,0 +1,251 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/utils"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// calendarRefresh is how often calendar apps are asked to poll a feed.
+	calendarRefresh = time.Hour
+	// calendarHistory is how far back played matches stay in a feed.
+	calendarHistory = 180 * 24 * time.Hour
+	// defaultMatchLength is used for a match's end time in calendars.
+	defaultMatchLength = 105 * time.Minute
+)
+
+// CalendarFeed is a user's private iCalendar subscription. The feed URL embeds
+// a secret token of which only the hash is stored; Token and URL are only
+// returned when the token is (re)generated.
+type CalendarFeed struct {
+	gorm.Model
+
+	UserID        uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	TokenHash     string     `gorm:"not null;uniqueIndex" json:"-"`
+	TimeZone      string     `gorm:"size:64" json:"time_zone"`
+	RotatedAt     time.Time  `json:"rotated_at"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	Token         string     `gorm:"-" json:"token,omitempty"`
+	URL           string     `gorm:"-" json:"url,omitempty"`
+}
+
+type CreateCalendarFeed struct {
+	TimeZone string `json:"time_zone" binding:"omitempty,timezone"`
+}
+
+// localTime formats t in zone for descriptions, e.g. "Sat 3 May 15:00
+// (Europe/London)". Unknown or empty zones fall back to UTC.
+func localTime(t time.Time, zone string) string {
+	loc, err := time.LoadLocation(zone)
+	if zone == "" || err != nil {
+		loc, zone = time.UTC, "UTC"
+	}
+	return t.In(loc).Format("Mon 2 Jan 2006 15:04") + " (" + zone + ")"
+}
+
+// MatchCalendarEvents turns logged matches into calendar events.
+func MatchCalendarEvents(matches []Match, zone string) []utils.CalendarEvent {
+	events := make([]utils.CalendarEvent, 0, len(matches))
+	for _, match := range matches {
+		team := match.ClubName
+		if team == "" {
+			team = "My team"
+		}
+		summary := team + " vs " + match.Opponent
+		if match.HomeAway == MatchAway {
+			summary = match.Opponent + " vs " + team
+		}
+
+		var description []string
+		if match.Competition != "" {
+			description = append(description, match.Competition)
+		}
+		matchZone := match.TimeZone
+		if matchZone == "" {
+			matchZone = zone
+		}
+		description = append(description, "Kick-off: "+localTime(match.KickoffAt, matchZone))
+		if match.GoalsFor != nil && match.GoalsAgainst != nil {
+			description = append(description, fmt.Sprintf("Result: %d-%d", *match.GoalsFor, *match.GoalsAgainst))
+		}
+
+		events = append(events, utils.CalendarEvent{
+			UID:         fmt.Sprintf("match-%d@ballerbio", match.ID),
+			Summary:     summary,
+			Description: strings.Join(description, "\n"),
+			Location:    match.Venue,
+			Start:       match.KickoffAt,
+			End:         match.KickoffAt.Add(defaultMatchLength),
+			Status:      utils.EventConfirmed,
+			Updated:     match.UpdatedAt,
+		})
+	}
+	return events
+}
+
+// InjuryCalendarEvents adds an all-day return-to-play event for every injury
+// with an expected or actual end date.
+func InjuryCalendarEvents(injuries []Injury) []utils.CalendarEvent {
+	events := make([]utils.CalendarEvent, 0, len(injuries))
+	for _, injury := range injuries {
+		if injury.EndDate == nil {
+			continue
+		}
+		summary := "Return to play"
+		if injury.InjuryType != "" {
+			summary += ": " + injury.InjuryType
+		}
+		day := injury.EndDate.UTC()
+		events = append(events, utils.CalendarEvent{
+			UID:         fmt.Sprintf("injury-%d-return@ballerbio", injury.ID),
+			Summary:     summary,
+			Description: injury.Description,
+			Start:       day,
+			End:         day.AddDate(0, 0, 1),
+			AllDay:      true,
+			Status:      utils.EventConfirmed,
+			Updated:     injury.UpdatedAt,
+		})
+	}
+	return events
+}
+
+// PlayerCalendar builds the calendar of userID: the trials they applied to,
+// their logged matches and the return dates of their injuries.
+func PlayerCalendar(db *gorm.DB, userID uint, zone string) (utils.Calendar, error) {
+	calendar := utils.Calendar{Name: "ballerbio", TimeZone: zone, Refresh: calendarRefresh}
+
+	applications, err := PlayerTrialApplications(db, userID)
+	if err != nil {
+		return calendar, err
+	}
+	calendar.Events = append(calendar.Events, TrialCalendarEvents(applications)...)
+
+	profileIDs := db.Model(&Profile{}).Select("id").Where("user_id = ?", userID)
+	var matches []Match
+	if err := db.Where("profile_id IN (?) AND kickoff_at >= ?", profileIDs, time.Now().Add(-calendarHistory)).
+		Order("kickoff_at").Find(&matches).Error; err != nil {
+		return calendar, err
+	}
+	calendar.Events = append(calendar.Events, MatchCalendarEvents(matches, zone)...)
+
+	var injuries []Injury
+	if err := db.Where("profile_id IN (?) AND end_date IS NOT NULL", profileIDs).Order("end_date").Find(&injuries).Error; err != nil {
+		return calendar, err
+	}
+	calendar.Events = append(calendar.Events, InjuryCalendarEvents(injuries)...)
+	return calendar, nil
+}
+
+// feedURL is the absolute URL a feed token is served at, next to the route
+// that issued it.
+func feedURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	base := strings.TrimSuffix(c.FullPath(), "/feed")
+	return scheme + "://" + c.Request.Host + base + "/feeds/" + token + ".ics"
+}
+
+// RotateCalendarFeedGinHandler creates the user's feed or replaces its token,
+// which stops the old URL from working. The new URL is only shown once.
+func (h *DBHandler) RotateCalendarFeedGinHandler(c *gin.Context) {
+	var input CreateCalendarFeed
+
+	// 1. Bind JSON data to the input struct and validate required fields. The
+	// body is optional: a bare POST creates or rotates the feed.
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	token, hash, err := utils.NewToken()
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not create feed token.", err))
+		return
+	}
+
+	userID := c.GetUint("userID")
+	var feed CalendarFeed
+	err = h.DB.Where("user_id = ?", userID).First(&feed).Error
+	status := http.StatusOK
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		status = http.StatusCreated
+		feed = CalendarFeed{UserID: userID}
+	} else if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve calendar feed.", err))
+		return
+	}
+	feed.TokenHash = hash
+	feed.RotatedAt = time.Now()
+	if input.TimeZone != "" || status == http.StatusCreated {
+		feed.TimeZone = input.TimeZone
+	}
+	if err := h.DB.Save(&feed).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to save calendar feed.", err))
+		return
+	}
+	feed.Token = token
+	feed.URL = feedURL(c, token)
+	c.JSON(status, feed)
+}
+
+func (h *DBHandler) GetCalendarFeedGinHandler(c *gin.Context) {
+	var feed CalendarFeed
+	err := h.DB.Where("user_id = ?", c.GetUint("userID")).First(&feed).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "You have no calendar feed."))
+		return
+	}
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve calendar feed.", err))
+		return
+	}
+	c.JSON(http.StatusOK, feed)
+}
+
+func (h *DBHandler) DeleteCalendarFeedGinHandler(c *gin.Context) {
+	if err := h.DB.Unscoped().Where("user_id = ?", c.GetUint("userID")).Delete(&CalendarFeed{}).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to delete calendar feed.", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ServeCalendarFeedGinHandler serves a feed to calendar apps. It needs no
+// login: the secret token in the file name identifies the user.
+func (h *DBHandler) ServeCalendarFeedGinHandler(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("file"), ".ics")
+	var feed CalendarFeed
+	err := h.DB.Where("token_hash = ?", utils.HashToken(token)).First(&feed).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "Calendar feed not found."))
+		return
+	}
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve calendar feed.", err))
+		return
+	}
+
+	calendar, err := PlayerCalendar(h.DB, feed.UserID, feed.TimeZone)
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not build calendar.", err))
+		return
+	}
+	if err := h.DB.Model(&feed).Update("last_fetched_at", time.Now()).Error; err != nil {
+		log.Printf("Could not record fetch of calendar feed %d: %v", feed.ID, err)
+	}
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(calendarRefresh.Seconds())))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(calendar.String()))
+}
@@ -0,0 +1,450 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// careerGapThreshold is the shortest break between two spells reported as a
+// gap; shorter ones are ordinary summer moves.
+const careerGapThreshold = 90 * 24 * time.Hour
+
+// Career event types, in the order they sort on the same day.
+const (
+	CareerEventLeft        = "left"
+	CareerEventLoanReturn  = "loan_return"
+	CareerEventJoined      = "joined"
+	CareerEventTransfer    = "transfer"
+	CareerEventLoan        = "loan"
+	CareerEventInjury      = "injury"
+	CareerEventAchievement = "achievement"
+)
+
+var careerEventOrder = []string{
+	CareerEventLeft, CareerEventLoanReturn, CareerEventJoined, CareerEventTransfer,
+	CareerEventLoan, CareerEventInjury, CareerEventAchievement,
+}
+
+// CareerSpell is one ClubProfile on the timeline. End is nil for the present
+// club and for spells whose end was never recorded (OpenEnded).
+type CareerSpell struct {
+	ClubProfileID uint       `json:"club_profile_id"`
+	ClubID        *uint      `json:"club_id"`
+	ClubName      string     `json:"club_name"`
+	ClubLeague    string     `json:"club_league"`
+	ClubCountry   string     `json:"club_country"`
+	ContractType  string     `json:"contract_type"`
+	IsLoan        bool       `json:"is_loan"`
+	LoanFromID    *uint      `json:"loan_from_id,omitempty"`
+	LoanFromClub  string     `json:"loan_from_club,omitempty"`
+	Start         time.Time  `json:"start"`
+	End           *time.Time `json:"end"`
+	IsPresentClub bool       `json:"is_present_club"`
+	OpenEnded     bool       `json:"open_ended"`
+	TenureDays    *int       `json:"tenure_days"`
+	Appearances   *int32     `json:"appearances"`
+	Goals         *int32     `json:"goals"`
+	Assists       *int32     `json:"assists"`
+	IsVerified    bool       `json:"is_verified"`
+}
+
+// CareerEvent is a dated point on the timeline. Only the ID matching Type is
+// set.
+type CareerEvent struct {
+	Date          time.Time  `json:"date"`
+	Type          string     `json:"type"`
+	Title         string     `json:"title"`
+	ClubName      string     `json:"club_name,omitempty"`
+	FromClub      string     `json:"from_club,omitempty"`
+	EndDate       *time.Time `json:"end_date,omitempty"`
+	ClubProfileID *uint      `json:"club_profile_id,omitempty"`
+	AchievementID *uint      `json:"achievement_id,omitempty"`
+	InjuryID      *uint      `json:"injury_id,omitempty"`
+}
+
+// CareerGap is a period without any club.
+type CareerGap struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Days  int       `json:"days"`
+}
+
+// CareerOverlap flags two spells that run at the same time. Loans inside a
+// spell at the parent club are expected and not reported.
+type CareerOverlap struct {
+	ClubProfileIDs [2]uint   `json:"club_profile_ids"`
+	Clubs          [2]string `json:"clubs"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	Days           int       `json:"days"`
+}
+
+// CareerClub is a player's total output for one club over all their spells
+// there. Figures come from season stats when there are any, otherwise from
+// the spells' own totals.
+type CareerClub struct {
+	ClubID        *uint    `json:"club_id"`
+	ClubName      string   `json:"club_name"`
+	Spells        int      `json:"spells"`
+	TenureDays    int      `json:"tenure_days"`
+	Appearances   int      `json:"appearances"`
+	Goals         int      `json:"goals"`
+	Assists       int      `json:"assists"`
+	MinutesPlayed int      `json:"minutes_played"`
+	GoalsPerGame  *float64 `json:"goals_per_game"`
+	Source        string   `json:"source"`
+}
+
+const (
+	CareerSourceSeasonStats = "season_stats"
+	CareerSourceClubProfile = "club_profile"
+)
+
+type Career struct {
+	ProfileID  uint            `json:"profile_id"`
+	Start      *time.Time      `json:"start"`
+	TenureDays int             `json:"tenure_days"`
+	Spells     []CareerSpell   `json:"spells"`
+	Events     []CareerEvent   `json:"events"`
+	Gaps       []CareerGap     `json:"gaps"`
+	Overlaps   []CareerOverlap `json:"overlaps"`
+	Clubs      []CareerClub    `json:"clubs"`
+}
+
+// clubKey groups rows of the same club, by directory ID when linked.
+func clubKey(clubID *uint, name string) string {
+	if clubID != nil {
+		return "#" + strconv.FormatUint(uint64(*clubID), 10)
+	}
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// spellEnd is when a spell ended, now for the present club, or nil when
+// unknown.
+func spellEnd(cp ClubProfile, now time.Time) *time.Time {
+	if cp.EndYear != nil {
+		return cp.EndYear
+	}
+	if cp.IsPresentClub {
+		return &now
+	}
+	return nil
+}
+
+func within(start time.Time, end *time.Time, t time.Time) bool {
+	return !t.Before(start) && (end == nil || !t.After(*end))
+}
+
+// BuildCareer merges a player's club spells, achievements, injuries and
+// season stats into a chronological career.
+func BuildCareer(profileID uint, clubProfiles []ClubProfile, achievements []Achievement, injuries []Injury, stats []SeasonStat, now time.Time) Career {
+	career := Career{
+		ProfileID: profileID,
+		Spells:    []CareerSpell{},
+		Events:    []CareerEvent{},
+		Gaps:      []CareerGap{},
+		Overlaps:  []CareerOverlap{},
+		Clubs:     []CareerClub{},
+	}
+
+	// Spells without a start date cannot be placed on the timeline.
+	clubProfiles = slices.DeleteFunc(slices.Clone(clubProfiles), func(cp ClubProfile) bool { return cp.StartYear == nil })
+	slices.SortStableFunc(clubProfiles, func(a, b ClubProfile) int {
+		return a.StartYear.Compare(*b.StartYear)
+	})
+	var permanent []int
+	for _, cp := range clubProfiles {
+		spell := CareerSpell{
+			ClubProfileID: cp.ID,
+			ClubID:        cp.ClubID,
+			ClubName:      cp.ClubName,
+			ClubLeague:    cp.ClubLeague,
+			ClubCountry:   cp.ClubCountry,
+			ContractType:  cp.ContractType,
+			IsLoan:        cp.ContractType == ContractTypeLoan,
+			Start:         *cp.StartYear,
+			End:           cp.EndYear,
+			IsPresentClub: cp.IsPresentClub,
+			OpenEnded:     cp.EndYear == nil && !cp.IsPresentClub,
+			Appearances:   cp.ClubAppearances,
+			Goals:         cp.ClubGoals,
+			Assists:       cp.ClubAssists,
+			IsVerified:    cp.IsVerified,
+		}
+		if end := spellEnd(cp, now); end != nil {
+			days := daysBetween(spell.Start, *end)
+			spell.TenureDays = &days
+		}
+		if !spell.IsLoan {
+			permanent = append(permanent, len(career.Spells))
+		}
+		career.Spells = append(career.Spells, spell)
+	}
+	if len(career.Spells) > 0 {
+		career.Start = &career.Spells[0].Start
+	}
+
+	// A loan belongs to the permanent spell it started during.
+	for i := range career.Spells {
+		loan := &career.Spells[i]
+		if !loan.IsLoan {
+			continue
+		}
+		for _, j := range slices.Backward(permanent) {
+			parent := career.Spells[j]
+			// Present and open-ended spells have no end to check against.
+			if within(parent.Start, parent.End, loan.Start) {
+				loan.LoanFromID = &parent.ClubProfileID
+				loan.LoanFromClub = parent.ClubName
+				break
+			}
+		}
+	}
+
+	career.Overlaps = careerOverlaps(career.Spells, now)
+	career.Gaps, career.TenureDays = careerGaps(career.Spells, now)
+	career.Events = careerEvents(career.Spells, achievements, injuries)
+	career.Clubs = careerClubs(career.Spells, stats)
+	return career
+}
+
+// spellRange is the known extent of a spell; open-ended spells end where they
+// start.
+func spellRange(s CareerSpell, now time.Time) (time.Time, time.Time) {
+	switch {
+	case s.End != nil:
+		return s.Start, *s.End
+	case s.IsPresentClub:
+		return s.Start, now
+	}
+	return s.Start, s.Start
+}
+
+func careerOverlaps(spells []CareerSpell, now time.Time) []CareerOverlap {
+	overlaps := []CareerOverlap{}
+	for i, a := range spells {
+		for _, b := range spells[i+1:] {
+			// A loan overlaps its parent spell by design, and any other
+			// overlap with it is already reported for the parent.
+			if (a.LoanFromID != nil && !b.IsLoan) || (b.LoanFromID != nil && !a.IsLoan) {
+				continue
+			}
+			aStart, aEnd := spellRange(a, now)
+			bStart, bEnd := spellRange(b, now)
+			start, end := later(aStart, bStart), earlier(aEnd, bEnd)
+			if days := daysBetween(start, end); days > 0 {
+				overlaps = append(overlaps, CareerOverlap{
+					ClubProfileIDs: [2]uint{a.ClubProfileID, b.ClubProfileID},
+					Clubs:          [2]string{a.ClubName, b.ClubName},
+					Start:          start,
+					End:            end,
+					Days:           days,
+				})
+			}
+		}
+	}
+	return overlaps
+}
+
+// careerGaps walks the spells in start order and reports breaks longer than
+// careerGapThreshold. It also returns the days spent at clubs, counting
+// overlapping spells once. Open-ended spells hide any gap after them.
+func careerGaps(spells []CareerSpell, now time.Time) ([]CareerGap, int) {
+	gaps := []CareerGap{}
+	tenure := 0
+	var coveredUntil *time.Time
+	for _, s := range spells {
+		start, end := spellRange(s, now)
+		if s.OpenEnded {
+			coveredUntil = nil
+			continue
+		}
+		switch {
+		case coveredUntil == nil:
+			tenure += daysBetween(start, end)
+		case start.After(*coveredUntil):
+			if start.Sub(*coveredUntil) >= careerGapThreshold {
+				gaps = append(gaps, CareerGap{Start: *coveredUntil, End: start, Days: daysBetween(*coveredUntil, start)})
+			}
+			tenure += daysBetween(start, end)
+		case end.After(*coveredUntil):
+			tenure += daysBetween(*coveredUntil, end)
+		}
+		if coveredUntil == nil || end.After(*coveredUntil) {
+			coveredUntil = &end
+		}
+	}
+	return gaps, tenure
+}
+
+func careerEvents(spells []CareerSpell, achievements []Achievement, injuries []Injury) []CareerEvent {
+	events := []CareerEvent{}
+	var previous *CareerSpell
+	for i := range spells {
+		s := &spells[i]
+		id := s.ClubProfileID
+		event := CareerEvent{Date: s.Start, Type: CareerEventJoined, Title: "Joined " + s.ClubName, ClubName: s.ClubName, ClubProfileID: &id}
+		switch {
+		case s.IsLoan:
+			event.Type, event.Title, event.FromClub = CareerEventLoan, "Loaned to "+s.ClubName, s.LoanFromClub
+		case previous != nil && previous.End != nil && s.Start.Sub(*previous.End) < careerGapThreshold:
+			event.Type, event.Title, event.FromClub = CareerEventTransfer, "Moved from "+previous.ClubName+" to "+s.ClubName, previous.ClubName
+		}
+		events = append(events, event)
+
+		if s.End != nil && !s.IsPresentClub {
+			end := CareerEvent{Date: *s.End, Type: CareerEventLeft, Title: "Left " + s.ClubName, ClubName: s.ClubName, ClubProfileID: &id}
+			if s.IsLoan {
+				end.Type, end.Title = CareerEventLoanReturn, "Returned from loan at "+s.ClubName
+			}
+			events = append(events, end)
+		}
+		if !s.IsLoan {
+			previous = s
+		}
+	}
+	for _, a := range achievements {
+		if a.DateAchieved == nil {
+			continue
+		}
+		id := a.ID
+		events = append(events, CareerEvent{Date: *a.DateAchieved, Type: CareerEventAchievement, Title: a.Title, AchievementID: &id})
+	}
+	for _, injury := range injuries {
+		if injury.StartDate == nil {
+			continue
+		}
+		id := injury.ID
+		title := injury.InjuryType
+		if title == "" {
+			title = "Injury"
+		}
+		events = append(events, CareerEvent{Date: *injury.StartDate, Type: CareerEventInjury, Title: title, EndDate: injury.EndDate, InjuryID: &id})
+	}
+
+	slices.SortStableFunc(events, func(a, b CareerEvent) int {
+		if c := a.Date.Compare(b.Date); c != 0 {
+			return c
+		}
+		return cmp.Compare(slices.Index(careerEventOrder, a.Type), slices.Index(careerEventOrder, b.Type))
+	})
+	return events
+}
+
+func careerClubs(spells []CareerSpell, stats []SeasonStat) []CareerClub {
+	var order []string
+	clubs := map[string]*CareerClub{}
+	club := func(clubID *uint, name string) *CareerClub {
+		key := clubKey(clubID, name)
+		c, ok := clubs[key]
+		if !ok {
+			c = &CareerClub{ClubID: clubID, ClubName: name, Source: CareerSourceClubProfile}
+			clubs[key] = c
+			order = append(order, key)
+		}
+		return c
+	}
+
+	for _, s := range spells {
+		c := club(s.ClubID, s.ClubName)
+		c.Spells++
+		if s.TenureDays != nil {
+			c.TenureDays += *s.TenureDays
+		}
+		c.Appearances += int(derefInt32(s.Appearances))
+		c.Goals += int(derefInt32(s.Goals))
+		c.Assists += int(derefInt32(s.Assists))
+	}
+
+	// Season stats replace the spell totals of the clubs they cover.
+	fromStats := map[string]bool{}
+	for _, stat := range stats {
+		c := club(stat.ClubID, stat.ClubName)
+		if !fromStats[clubKey(stat.ClubID, stat.ClubName)] {
+			fromStats[clubKey(stat.ClubID, stat.ClubName)] = true
+			c.Appearances, c.Goals, c.Assists, c.Source = 0, 0, 0, CareerSourceSeasonStats
+		}
+		c.Appearances += int(derefInt32(stat.Appearances))
+		c.Goals += int(derefInt32(stat.Goals))
+		c.Assists += int(derefInt32(stat.Assists))
+		c.MinutesPlayed += int(derefInt32(stat.MinutesPlayed))
+	}
+
+	result := make([]CareerClub, 0, len(order))
+	for _, key := range order {
+		c := clubs[key]
+		if c.Appearances > 0 {
+			rate := float64(c.Goals) / float64(c.Appearances)
+			c.GoalsPerGame = &rate
+		}
+		result = append(result, *c)
+	}
+	return result
+}
+
+func derefInt32(v *int32) int32 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func earlier(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// GetCareerGinHandler returns a player's career timeline: club spells with
+// loans attached to their parent club, transfers, achievements and injuries
+// as dated events, gaps and overlaps, and output per club.
+func (h *DBHandler) GetCareerGinHandler(c *gin.Context) {
+	profileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+	profile, err := requirePublicProfile(h.DB, uint(profileID))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	var clubProfiles []ClubProfile
+	var achievements []Achievement
+	var injuries []Injury
+	var stats []SeasonStat
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("profile_id = ?", profile.ID).Find(&clubProfiles).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("profile_id = ?", profile.ID).Find(&achievements).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("profile_id = ?", profile.ID).Find(&injuries).Error; err != nil {
+			return err
+		}
+		return tx.Where("profile_id = ?", profile.ID).Order("season").Find(&stats).Error
+	})
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve career.", err))
+		return
+	}
+	c.JSON(http.StatusOK, BuildCareer(profile.ID, clubProfiles, achievements, injuries, stats, time.Now()))
+}
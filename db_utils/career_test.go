@@ -0,0 +1,274 @@
+package db_utils
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var careerNow = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func day(year int, month time.Month, d int) *time.Time {
+	t := time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+// spell is a club spell; a nil end with present false is open-ended.
+func spell(id uint, club string, start, end *time.Time, present bool, contract string) ClubProfile {
+	return ClubProfile{
+		Model:         gorm.Model{ID: id},
+		ClubName:      club,
+		StartYear:     start,
+		EndYear:       end,
+		IsPresentClub: present,
+		ContractType:  contract,
+	}
+}
+
+func idPtr(id uint) *uint { return &id }
+
+func spellByID(t *testing.T, career Career, id uint) CareerSpell {
+	t.Helper()
+	i := slices.IndexFunc(career.Spells, func(s CareerSpell) bool { return s.ClubProfileID == id })
+	if i < 0 {
+		t.Fatalf("spell %d missing from career", id)
+	}
+	return career.Spells[i]
+}
+
+func TestBuildCareerLoanParent(t *testing.T) {
+	tests := []struct {
+		name       string
+		spells     []ClubProfile
+		loan       uint
+		wantParent *uint
+	}{
+		{
+			name: "loan during a finished spell",
+			spells: []ClubProfile{
+				spell(1, "Rovers", day(2019, 7, 1), day(2023, 6, 30), false, ContractTypePermanent),
+				spell(2, "Town", day(2021, 1, 15), day(2021, 5, 31), false, ContractTypeLoan),
+			},
+			loan:       2,
+			wantParent: idPtr(1),
+		},
+		{
+			name: "loan during the present club",
+			spells: []ClubProfile{
+				spell(1, "Rovers", day(2019, 7, 1), day(2021, 6, 30), false, ContractTypePermanent),
+				spell(2, "City", day(2021, 7, 1), nil, true, ContractTypePermanent),
+				spell(3, "Town", day(2024, 8, 1), day(2025, 1, 1), false, ContractTypeLoan),
+			},
+			loan:       3,
+			wantParent: idPtr(2),
+		},
+		{
+			name: "latest permanent spell wins when spells overlap",
+			spells: []ClubProfile{
+				spell(1, "Rovers", day(2019, 7, 1), day(2023, 6, 30), false, ContractTypePermanent),
+				spell(2, "City", day(2020, 7, 1), day(2023, 6, 30), false, ContractTypePermanent),
+				spell(3, "Town", day(2021, 1, 1), day(2021, 5, 1), false, ContractTypeLoan),
+			},
+			loan:       3,
+			wantParent: idPtr(2),
+		},
+		{
+			name: "loan outside every spell has no parent",
+			spells: []ClubProfile{
+				spell(1, "Rovers", day(2019, 7, 1), day(2020, 6, 30), false, ContractTypePermanent),
+				spell(2, "Town", day(2021, 1, 15), day(2021, 5, 31), false, ContractTypeLoan),
+			},
+			loan: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			career := BuildCareer(1, tt.spells, nil, nil, nil, careerNow)
+			got := spellByID(t, career, tt.loan).LoanFromID
+			switch {
+			case tt.wantParent == nil && got != nil:
+				t.Errorf("loan parent = %d, want none", *got)
+			case tt.wantParent != nil && (got == nil || *got != *tt.wantParent):
+				t.Errorf("loan parent = %v, want %d", got, *tt.wantParent)
+			}
+		})
+	}
+}
+
+func TestBuildCareerSkipsUndatedSpells(t *testing.T) {
+	career := BuildCareer(1, []ClubProfile{
+		spell(1, "Rovers", nil, day(2020, 6, 30), false, ContractTypePermanent),
+		spell(2, "City", day(2021, 7, 1), nil, true, ContractTypePermanent),
+	}, nil, nil, nil, careerNow)
+	if len(career.Spells) != 1 || career.Spells[0].ClubProfileID != 2 {
+		t.Fatalf("spells = %+v, want only spell 2", career.Spells)
+	}
+	if career.Start == nil || !career.Start.Equal(*day(2021, 7, 1)) {
+		t.Errorf("start = %v, want 2021-07-01", career.Start)
+	}
+}
+
+func TestCareerOverlaps(t *testing.T) {
+	tests := []struct {
+		name   string
+		spells []ClubProfile
+		want   [][2]uint
+	}{
+		{
+			name: "back to back spells",
+			spells: []ClubProfile{
+				spell(1, "Rovers", day(2019, 7, 1), day(2021, 6, 30), false, ContractTypePermanent),
+				spell(2, "City", day(2021, 6, 30), nil, true, ContractTypePermanent),
+			},
+		},
+		{
+			name: "two permanent spells at once",
+			spells: []ClubProfile{
+				spell(1, "Rovers", day(2019, 7, 1), day(2021, 6, 30), false, ContractTypePermanent),
+				spell(2, "City", day(2021, 1, 1), nil, true, ContractTypePermanent),
+			},
+			want: [][2]uint{{1, 2}},
+		},
+		{
+			name: "loan inside its parent spell",
+			spells: []ClubProfile{
+				spell(1, "Rovers", day(2019, 7, 1), day(2023, 6, 30), false, ContractTypePermanent),
+				spell(2, "Town", day(2021, 1, 15), day(2021, 5, 31), false, ContractTypeLoan),
+			},
+		},
+		{
+			name: "two loans at once",
+			spells: []ClubProfile{
+				spell(1, "Rovers", day(2019, 7, 1), day(2023, 6, 30), false, ContractTypePermanent),
+				spell(2, "Town", day(2021, 1, 1), day(2021, 5, 31), false, ContractTypeLoan),
+				spell(3, "United", day(2021, 3, 1), day(2021, 6, 30), false, ContractTypeLoan),
+			},
+			want: [][2]uint{{2, 3}},
+		},
+		{
+			name: "open-ended spell has no extent",
+			spells: []ClubProfile{
+				spell(1, "Rovers", day(2019, 7, 1), nil, false, ContractTypePermanent),
+				spell(2, "City", day(2019, 8, 1), nil, true, ContractTypePermanent),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			career := BuildCareer(1, tt.spells, nil, nil, nil, careerNow)
+			var got [][2]uint
+			for _, o := range career.Overlaps {
+				got = append(got, o.ClubProfileIDs)
+				if o.Days <= 0 || o.End.Before(o.Start) {
+					t.Errorf("overlap %v spans %v to %v, %d days", o.ClubProfileIDs, o.Start, o.End, o.Days)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("overlaps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCareerGaps(t *testing.T) {
+	tests := []struct {
+		name       string
+		spells     []CareerSpell
+		wantGaps   []CareerGap
+		wantTenure int
+	}{
+		{
+			name: "summer move is not a gap",
+			spells: []CareerSpell{
+				{Start: *day(2020, 1, 1), End: day(2020, 6, 1)},
+				{Start: *day(2020, 7, 1), End: day(2021, 1, 1)},
+			},
+			wantGaps:   []CareerGap{},
+			wantTenure: 152 + 184,
+		},
+		{
+			name: "break at the threshold is a gap",
+			spells: []CareerSpell{
+				{Start: *day(2020, 1, 1), End: day(2020, 6, 1)},
+				{Start: *day(2020, 8, 30), End: day(2021, 1, 1)},
+			},
+			wantGaps:   []CareerGap{{Start: *day(2020, 6, 1), End: *day(2020, 8, 30), Days: 90}},
+			wantTenure: 152 + 124,
+		},
+		{
+			name: "overlapping spells count once",
+			spells: []CareerSpell{
+				{Start: *day(2020, 1, 1), End: day(2020, 12, 31)},
+				{Start: *day(2020, 7, 1), End: day(2021, 6, 30)},
+			},
+			wantGaps:   []CareerGap{},
+			wantTenure: 546,
+		},
+		{
+			name: "spell inside another adds nothing",
+			spells: []CareerSpell{
+				{Start: *day(2020, 1, 1), End: day(2020, 12, 31)},
+				{Start: *day(2020, 3, 1), End: day(2020, 5, 1)},
+			},
+			wantGaps:   []CareerGap{},
+			wantTenure: 365,
+		},
+		{
+			name: "open-ended spell hides the gap after it",
+			spells: []CareerSpell{
+				{Start: *day(2018, 1, 1), End: day(2018, 6, 1)},
+				{Start: *day(2018, 7, 1), OpenEnded: true},
+				{Start: *day(2020, 1, 1), End: day(2020, 6, 1)},
+			},
+			wantGaps:   []CareerGap{},
+			wantTenure: 151 + 152,
+		},
+		{
+			name: "present club runs until now",
+			spells: []CareerSpell{
+				{Start: *day(2024, 1, 1), End: day(2024, 6, 1)},
+				{Start: *day(2025, 1, 1), IsPresentClub: true},
+			},
+			wantGaps:   []CareerGap{{Start: *day(2024, 6, 1), End: *day(2025, 1, 1), Days: 214}},
+			wantTenure: 152 + 151,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gaps, tenure := careerGaps(tt.spells, careerNow)
+			if !slices.Equal(gaps, tt.wantGaps) {
+				t.Errorf("gaps = %+v, want %+v", gaps, tt.wantGaps)
+			}
+			if tenure != tt.wantTenure {
+				t.Errorf("tenure = %d, want %d", tenure, tt.wantTenure)
+			}
+		})
+	}
+}
+
+func TestCareerEventsOrderAndTypes(t *testing.T) {
+	career := BuildCareer(1, []ClubProfile{
+		spell(1, "Rovers", day(2019, 7, 1), day(2021, 6, 30), false, ContractTypePermanent),
+		spell(2, "City", day(2021, 6, 30), nil, true, ContractTypePermanent),
+		spell(3, "Town", day(2022, 1, 1), day(2022, 5, 31), false, ContractTypeLoan),
+	}, []Achievement{{Model: gorm.Model{ID: 9}, Title: "Cup winner", DateAchieved: day(2021, 6, 30)}}, nil, nil, careerNow)
+
+	var got []string
+	for _, e := range career.Events {
+		got = append(got, e.Type)
+	}
+	want := []string{
+		CareerEventJoined,
+		CareerEventLeft, CareerEventTransfer, CareerEventAchievement,
+		CareerEventLoan,
+		CareerEventLoanReturn,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("event types = %v, want %v", got, want)
+	}
+	if loan := career.Events[4]; loan.FromClub != "City" {
+		t.Errorf("loan from club = %q, want City", loan.FromClub)
+	}
+}
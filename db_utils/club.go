@@ -0,0 +1,709 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/utils"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Directory entity types, as stored in DirectoryAlias.EntityType.
+const (
+	DirectoryCountry = "country"
+	DirectoryLeague  = "league"
+	DirectoryClub    = "club"
+)
+
+// Country is a football nation: an ISO 3166-1 country or one of the home
+// nations (GB-ENG, GB-SCT, ...).
+type Country struct {
+	gorm.Model
+
+	Code           string           `gorm:"size:6;not null;uniqueIndex" json:"code"`
+	Name           string           `gorm:"size:100;not null" json:"name"`
+	NormalizedName string           `gorm:"size:100;index" json:"-"`
+	Aliases        []DirectoryAlias `gorm:"polymorphic:Entity;polymorphicValue:country" json:"aliases,omitempty"`
+}
+
+type League struct {
+	gorm.Model
+
+	Name           string           `gorm:"size:100;not null" json:"name"`
+	NormalizedName string           `gorm:"size:100;index" json:"-"`
+	Tier           *int             `json:"tier"`
+	CountryID      *uint            `json:"country_id"`
+	Country        *Country         `json:"country,omitempty"`
+	Aliases        []DirectoryAlias `gorm:"polymorphic:Entity;polymorphicValue:league" json:"aliases,omitempty"`
+}
+
+// Club is the canonical record of a club. Clubs merged into another one are
+// soft-deleted and keep MergedIntoID for reference.
+type Club struct {
+	gorm.Model
+
+	Name           string           `gorm:"size:100;not null" json:"name"`
+	NormalizedName string           `gorm:"size:100;index" json:"-"`
+	CountryID      *uint            `json:"country_id"`
+	Country        *Country         `json:"country,omitempty"`
+	LeagueID       *uint            `json:"league_id"`
+	League         *League          `json:"league,omitempty"`
+	MergedIntoID   *uint            `json:"merged_into_id,omitempty"`
+	Aliases        []DirectoryAlias `gorm:"polymorphic:Entity;polymorphicValue:club" json:"aliases,omitempty"`
+}
+
+// DirectoryAlias is an alternative spelling of a country, league or club
+// ("Man Utd" for Manchester United).
+type DirectoryAlias struct {
+	gorm.Model
+
+	EntityType string `gorm:"size:10;not null;uniqueIndex:idx_directory_alias" json:"-"`
+	EntityID   uint   `gorm:"not null;uniqueIndex:idx_directory_alias" json:"-"`
+	Name       string `gorm:"size:100;not null" json:"name"`
+	Normalized string `gorm:"size:100;not null;uniqueIndex:idx_directory_alias;index" json:"-"`
+}
+
+func (c *Country) BeforeSave(tx *gorm.DB) error {
+	c.NormalizedName = utils.NormalizeName(c.Name)
+	return nil
+}
+
+func (l *League) BeforeSave(tx *gorm.DB) error {
+	l.NormalizedName = utils.NormalizeName(l.Name)
+	return nil
+}
+
+func (c *Club) BeforeSave(tx *gorm.DB) error {
+	c.NormalizedName = utils.NormalizeName(c.Name)
+	return nil
+}
+
+func (a *DirectoryAlias) BeforeSave(tx *gorm.DB) error {
+	a.Normalized = utils.NormalizeName(a.Name)
+	return nil
+}
+
+// countryAliases are the informal country names seeded as aliases.
+var countryAliases = map[string][]string{
+	"US":     {"USA", "United States of America", "America"},
+	"GB":     {"UK", "Great Britain", "Britain"},
+	"NL":     {"Holland"},
+	"CI":     {"Ivory Coast"},
+	"KR":     {"South Korea", "Korea Republic"},
+	"KP":     {"North Korea", "Korea DPR"},
+	"CZ":     {"Czech Republic"},
+	"CD":     {"DR Congo", "Congo DR"},
+	"CV":     {"Cape Verde"},
+	"IR":     {"Iran"},
+	"RU":     {"Russia"},
+	"TR":     {"Turkey"},
+	"MK":     {"Macedonia"},
+	"GB-ENG": {"ENG"},
+	"GB-SCT": {"SCO"},
+	"GB-WLS": {"WAL"},
+	"GB-NIR": {"NIR"},
+}
+
+// SeedCountries makes sure every country, home nation and country alias
+// exists. Demonyms are seeded as aliases too, so "Irish" resolves to Ireland.
+// Existing rows are left alone.
+func SeedCountries(db *gorm.DB) error {
+	codes := append([]string{}, utils.ISOCountryCodes...)
+	for code := range utils.FootballNations {
+		codes = append(codes, code)
+	}
+	countries := make([]Country, 0, len(codes))
+	for _, code := range codes {
+		countries = append(countries, Country{Code: code, Name: utils.CountryName(code, "en")})
+	}
+	if err := db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "code"}}, DoNothing: true}).
+		CreateInBatches(&countries, 100).Error; err != nil {
+		return err
+	}
+
+	for _, table := range []map[string][]string{countryAliases, utils.Demonyms} {
+		if err := seedCountryAliases(db, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func seedCountryAliases(db *gorm.DB, aliasesByCode map[string][]string) error {
+	for code, aliases := range aliasesByCode {
+		var country Country
+		if err := db.Where("code = ?", code).First(&country).Error; err != nil {
+			return err
+		}
+		for _, alias := range aliases {
+			if err := addDirectoryAlias(db, DirectoryCountry, country.ID, alias); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addDirectoryAlias records alias for an entity unless it already has it.
+func addDirectoryAlias(db *gorm.DB, entityType string, entityID uint, alias string) error {
+	entry := DirectoryAlias{EntityType: entityType, EntityID: entityID, Name: alias}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error
+}
+
+// findDirectoryEntry loads into dest the entity of table whose name or alias
+// normalizes to normalized. With countryID, entities of that country win.
+func findDirectoryEntry(db *gorm.DB, dest any, entityType, table, normalized string, countryID *uint) error {
+	query := db.Where(
+		table+".normalized_name = ? OR EXISTS (SELECT 1 FROM directory_aliases da WHERE da.entity_type = ? AND da.entity_id = "+table+".id AND da.normalized = ? AND da.deleted_at IS NULL)",
+		normalized, entityType, normalized,
+	)
+	if countryID != nil {
+		query = query.Where(table+".country_id = ? OR "+table+".country_id IS NULL", *countryID).
+			Order(clause.OrderBy{Expression: clause.Expr{SQL: table + ".country_id IS NULL"}})
+	}
+	return query.Order(table + ".id").First(dest).Error
+}
+
+// ResolveCountry maps an ISO code, a name or an alias onto a Country. It
+// returns nil when the text matches no country.
+func ResolveCountry(db *gorm.DB, text string) (*Country, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+	var country Country
+	err := db.Where("code = ?", strings.ToUpper(text)).First(&country).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = findDirectoryEntry(db, &country, DirectoryCountry, "countries", utils.NormalizeName(text), nil)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &country, nil
+}
+
+// ResolveLeague finds the league called name, creating it when it is not in
+// the directory yet.
+func ResolveLeague(db *gorm.DB, name string, countryID *uint) (*League, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+	var league League
+	err := findDirectoryEntry(db, &league, DirectoryLeague, "leagues", utils.NormalizeName(name), countryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		league = League{Name: name, CountryID: countryID}
+		err = db.Create(&league).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &league, nil
+}
+
+// ResolveClub finds the club called name, creating it when it is not in the
+// directory yet. Duplicates created this way are consolidated with MergeClubs.
+func ResolveClub(db *gorm.DB, name string, countryID, leagueID *uint) (*Club, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+	var club Club
+	err := findDirectoryEntry(db, &club, DirectoryClub, "clubs", utils.NormalizeName(name), countryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		club = Club{Name: name, CountryID: countryID, LeagueID: leagueID}
+		err = db.Create(&club).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &club, nil
+}
+
+func requireClub(db *gorm.DB, clubID uint) (*Club, error) {
+	var club Club
+	err := db.Preload("Country").Preload("League").Preload("Aliases").First(&club, clubID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, api_errors.NotFound(api_errors.CodeClubNotFound, "Club not found.")
+	}
+	if err != nil {
+		return nil, api_errors.Internal("Could not retrieve club.", err)
+	}
+	return &club, nil
+}
+
+// clubPlacement is the club, league and country a club spell or season stat
+// refers to, once resolved against the directory.
+type clubPlacement struct {
+	Club    *Club
+	League  *League
+	Country *Country
+}
+
+func (p clubPlacement) clubID() *uint {
+	if p.Club == nil {
+		return nil
+	}
+	return &p.Club.ID
+}
+
+func (p clubPlacement) leagueID() *uint {
+	if p.League == nil {
+		return nil
+	}
+	return &p.League.ID
+}
+
+// leagueName is the canonical league name, or fallback when there is none.
+func (p clubPlacement) leagueName(fallback string) string {
+	if p.League == nil {
+		return fallback
+	}
+	return p.League.Name
+}
+
+// countryName is the canonical country name, or fallback when the country
+// text matched no directory entry.
+func (p clubPlacement) countryName(fallback string) string {
+	if p.Country == nil {
+		return fallback
+	}
+	return p.Country.Name
+}
+
+// resolveClubPlacement resolves the club of a ClubProfile or SeasonStat,
+// either from an explicit club ID or from the free-text names. Errors are
+// API errors.
+func resolveClubPlacement(db *gorm.DB, clubID *uint, clubName, leagueName, countryName string) (clubPlacement, error) {
+	placement, err := lookupClubPlacement(db, clubID, clubName, leagueName, countryName)
+	var apiErr *api_errors.Error
+	if err != nil && !errors.As(err, &apiErr) {
+		err = api_errors.Internal("Could not resolve club.", err)
+	}
+	return placement, err
+}
+
+func lookupClubPlacement(db *gorm.DB, clubID *uint, clubName, leagueName, countryName string) (clubPlacement, error) {
+	var placement clubPlacement
+	var err error
+	if clubID != nil {
+		if placement.Club, err = requireClub(db, *clubID); err != nil {
+			return placement, err
+		}
+		placement.Country = placement.Club.Country
+		placement.League = placement.Club.League
+		if leagueName != "" {
+			placement.League, err = ResolveLeague(db, leagueName, placement.Club.CountryID)
+		}
+		return placement, err
+	}
+
+	if placement.Country, err = ResolveCountry(db, countryName); err != nil {
+		return placement, err
+	}
+	var countryID *uint
+	if placement.Country != nil {
+		countryID = &placement.Country.ID
+	}
+	if placement.League, err = ResolveLeague(db, leagueName, countryID); err != nil {
+		return placement, err
+	}
+	placement.Club, err = ResolveClub(db, clubName, countryID, placement.leagueID())
+	if err == nil && placement.Club == nil {
+		err = api_errors.Invalid(api_errors.FieldErrorFor("club_name", "required", ""))
+	}
+	return placement, err
+}
+
+// clubReference is a table with a club_id column, the column holding a
+// denormalized copy of the club name if it has one, and the column naming
+// the club that verified the record if it can be verified.
+type clubReference struct {
+	Table          string
+	NameColumn     string
+	VerifiedColumn string
+}
+
+// clubReferences lists every table pointing at clubs. MergeClubs repoints
+// every one of them.
+var clubReferences = []clubReference{
+	{Table: "club_profiles", NameColumn: "club_name", VerifiedColumn: "verified_club_id"},
+	{Table: "season_stats", NameColumn: "club_name", VerifiedColumn: "verified_club_id"},
+	{Table: "club_admins"},
+	{Table: "verification_requests"},
+	{Table: "trial_events"},
+	{Table: "matches", NameColumn: "club_name"},
+}
+
+// MergeClubs folds source into target: references and aliases move over,
+// source's name becomes an alias, and source is soft-deleted.
+func MergeClubs(db *gorm.DB, sourceID, targetID uint) (*Club, error) {
+	if sourceID == targetID {
+		return nil, api_errors.Invalid(api_errors.FieldErrorFor("target_id", "nefield", "source_id"))
+	}
+	source, err := requireClub(db, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := requireClub(db, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Admins of both clubs would otherwise end up linked twice.
+		if err := tx.Unscoped().
+			Where("club_id = ? AND user_id IN (SELECT user_id FROM club_admins WHERE club_id = ?)", source.ID, target.ID).
+			Delete(&ClubAdmin{}).Error; err != nil {
+			return err
+		}
+		for _, ref := range clubReferences {
+			updates := map[string]any{"club_id": target.ID}
+			if ref.NameColumn != "" {
+				updates[ref.NameColumn] = target.Name
+			}
+			if err := tx.Table(ref.Table).Where("club_id = ?", source.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+			if ref.VerifiedColumn != "" {
+				if err := tx.Table(ref.Table).Where(ref.VerifiedColumn+" = ?", source.ID).Update(ref.VerifiedColumn, target.ID).Error; err != nil {
+					return err
+				}
+			}
+		}
+		for _, alias := range append(source.Aliases, DirectoryAlias{Name: source.Name}) {
+			if utils.NormalizeName(alias.Name) == target.NormalizedName {
+				continue
+			}
+			if err := addDirectoryAlias(tx, DirectoryClub, target.ID, alias.Name); err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Where("entity_type = ? AND entity_id = ?", DirectoryClub, source.ID).Delete(&DirectoryAlias{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(source).Update("merged_into_id", target.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(source).Error
+	})
+	if err != nil {
+		return nil, api_errors.Internal("Failed to merge clubs.", err)
+	}
+	return requireClub(db, target.ID)
+}
+
+// MigrateClubDirectory seeds the countries and links existing club spells
+// and season stats to directory entries built from their free-text names.
+func MigrateClubDirectory(db *gorm.DB) error {
+	if err := SeedCountries(db); err != nil {
+		return err
+	}
+
+	var clubProfiles []ClubProfile
+	err := db.Where("club_id IS NULL AND club_name <> ''").FindInBatches(&clubProfiles, 200, func(tx *gorm.DB, batch int) error {
+		for _, cp := range clubProfiles {
+			placement, err := resolveClubPlacement(db, nil, cp.ClubName, cp.ClubLeague, cp.ClubCountry)
+			if err != nil {
+				return err
+			}
+			if err := db.Model(&ClubProfile{}).Where("id = ?", cp.ID).
+				Updates(map[string]any{"club_id": placement.clubID(), "league_id": placement.leagueID()}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	var seasonStats []SeasonStat
+	return db.Where("club_id IS NULL AND club_name <> ''").FindInBatches(&seasonStats, 200, func(tx *gorm.DB, batch int) error {
+		for _, stat := range seasonStats {
+			placement, err := resolveClubPlacement(db, nil, stat.ClubName, stat.LeagueName, "")
+			if err != nil {
+				return err
+			}
+			if err := db.Model(&SeasonStat{}).Where("id = ?", stat.ID).
+				Updates(map[string]any{"club_id": placement.clubID(), "league_id": placement.leagueID()}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// DirectorySearchQuery is the query string of the directory autocomplete.
+type DirectorySearchQuery struct {
+	Q       string `form:"q" json:"q" binding:"required,min=2"`
+	Type    string `form:"type" json:"type,omitempty" binding:"omitempty,oneof=club league country"`
+	Country string `form:"country" json:"country,omitempty"`
+	Limit   int    `form:"limit" json:"limit,omitempty" binding:"omitempty,gte=1,lte=50"`
+}
+
+type DirectoryMatch struct {
+	Type        string  `json:"type"`
+	ID          uint    `json:"id"`
+	Name        string  `json:"name"`
+	MatchedName string  `json:"matched_name,omitempty"`
+	CountryID   *uint   `json:"country_id,omitempty"`
+	Score       float64 `json:"score"`
+}
+
+type MergeClubsInput struct {
+	SourceID uint `json:"source_id" binding:"required"`
+	TargetID uint `json:"target_id" binding:"required"`
+}
+
+type AddDirectoryAliasInput struct {
+	Type     string `json:"type" binding:"required,oneof=club league country"`
+	EntityID uint   `json:"entity_id" binding:"required"`
+	Alias    string `json:"alias" binding:"required,max=100"`
+}
+
+// Candidates are narrowed in SQL on the first letters of each query word
+// before being scored; searchCandidateLimit bounds that first pass.
+const (
+	searchPrefixLength   = 3
+	searchCandidateLimit = 200
+	searchMinScore       = 0.3
+)
+
+var directoryTables = map[string]string{
+	DirectoryCountry: "countries",
+	DirectoryLeague:  "leagues",
+	DirectoryClub:    "clubs",
+}
+
+type directoryRow struct {
+	ID        uint
+	Name      string
+	Match     string
+	CountryID *uint
+}
+
+// SearchDirectory ranks the entities of entityType matching query, by name
+// and by alias. countryID restricts leagues and clubs to one country.
+func SearchDirectory(db *gorm.DB, entityType, query string, countryID *uint, limit int) ([]DirectoryMatch, error) {
+	table := directoryTables[entityType]
+	normalized := utils.NormalizeName(query)
+	prefixes := utils.SearchPrefixes(normalized, searchPrefixLength)
+	if len(prefixes) == 0 {
+		return []DirectoryMatch{}, nil
+	}
+
+	countryColumn := "NULL"
+	if entityType != DirectoryCountry {
+		countryColumn = "t.country_id"
+	}
+	var nameLike, aliasLike []string
+	var args []any
+	for _, p := range prefixes {
+		nameLike = append(nameLike, "t.normalized_name LIKE ?")
+		aliasLike = append(aliasLike, "da.normalized LIKE ?")
+		args = append(args, "%"+p+"%")
+	}
+	countryFilter := ""
+	if countryID != nil && entityType != DirectoryCountry {
+		countryFilter = " AND t.country_id = " + strconv.FormatUint(uint64(*countryID), 10)
+	}
+
+	var rows []directoryRow
+	err := db.Raw(
+		"SELECT t.id, t.name, t.normalized_name AS match, "+countryColumn+" AS country_id FROM "+table+" t "+
+			"WHERE t.deleted_at IS NULL AND ("+strings.Join(nameLike, " OR ")+")"+countryFilter+
+			" UNION ALL "+
+			"SELECT t.id, t.name, da.normalized AS match, "+countryColumn+" AS country_id FROM directory_aliases da "+
+			"JOIN "+table+" t ON t.id = da.entity_id AND t.deleted_at IS NULL "+
+			"WHERE da.entity_type = ? AND da.deleted_at IS NULL AND ("+strings.Join(aliasLike, " OR ")+")"+countryFilter+
+			" LIMIT ?",
+		append(append(append(args, entityType), args...), searchCandidateLimit)...,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	best := map[uint]*DirectoryMatch{}
+	for _, row := range rows {
+		score := utils.MatchScore(normalized, row.Match)
+		if score < searchMinScore {
+			continue
+		}
+		if match, ok := best[row.ID]; ok && match.Score >= score {
+			continue
+		}
+		match := &DirectoryMatch{Type: entityType, ID: row.ID, Name: row.Name, CountryID: row.CountryID, Score: utils.Round(score, 3)}
+		if row.Match != utils.NormalizeName(row.Name) {
+			match.MatchedName = row.Match
+		}
+		best[row.ID] = match
+	}
+
+	matches := make([]DirectoryMatch, 0, len(best))
+	for _, match := range best {
+		matches = append(matches, *match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Name < matches[j].Name
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// SearchDirectoryGinHandler is the club, league and country autocomplete.
+// Without ?type= it searches all three.
+func (h *DBHandler) SearchDirectoryGinHandler(c *gin.Context) {
+	var query DirectorySearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	limit := query.Limit
+	if limit == 0 {
+		limit = 10
+	}
+	country, err := ResolveCountry(h.DB, query.Country)
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not resolve country.", err))
+		return
+	}
+	if query.Country != "" && country == nil {
+		api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("country", "country", "")))
+		return
+	}
+	var countryID *uint
+	if country != nil {
+		countryID = &country.ID
+	}
+
+	types := []string{DirectoryClub, DirectoryLeague, DirectoryCountry}
+	if query.Type != "" {
+		types = []string{query.Type}
+	}
+	results := []DirectoryMatch{}
+	for _, entityType := range types {
+		matches, err := SearchDirectory(h.DB, entityType, query.Q, countryID, limit)
+		if err != nil {
+			api_errors.Abort(c, api_errors.Internal("Could not search the directory.", err))
+			return
+		}
+		results = append(results, matches...)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// GetCountriesGinHandler lists the countries with names in the language
+// requested through ?lang= (English by default), in that language's order.
+func (h *DBHandler) GetCountriesGinHandler(c *gin.Context) {
+	var countries []Country
+	if err := h.DB.Order("name").Find(&countries).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve countries.", err))
+		return
+	}
+	lang := c.DefaultQuery("lang", "en")
+	tag, err := language.Parse(lang)
+	if err != nil {
+		tag = language.English
+	}
+	for i := range countries {
+		countries[i].Name = utils.CountryName(countries[i].Code, lang)
+	}
+	collator := collate.New(tag)
+	sort.SliceStable(countries, func(i, j int) bool { return collator.CompareString(countries[i].Name, countries[j].Name) < 0 })
+	c.JSON(http.StatusOK, countries)
+}
+
+func (h *DBHandler) GetClubGinHandler(c *gin.Context) {
+	clubID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+	club, err := requireClub(h.DB, uint(clubID))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, club)
+}
+
+// MergeClubsGinHandler lets an admin consolidate a duplicate club into the
+// canonical one.
+func (h *DBHandler) MergeClubsGinHandler(c *gin.Context) {
+	var input MergeClubsInput
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	if _, err := requireRole(c, h.DB, RoleAdmin); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	club, err := MergeClubs(h.DB, input.SourceID, input.TargetID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, club)
+}
+
+func (h *DBHandler) AddDirectoryAliasGinHandler(c *gin.Context) {
+	var input AddDirectoryAliasInput
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	if _, err := requireRole(c, h.DB, RoleAdmin); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	var count int64
+	if err := h.DB.Table(directoryTables[input.Type]).Where("id = ? AND deleted_at IS NULL", input.EntityID).Count(&count).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not verify directory entry.", err))
+		return
+	}
+	if count == 0 {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "Directory entry not found."))
+		return
+	}
+	if err := addDirectoryAlias(h.DB, input.Type, input.EntityID, input.Alias); err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to add alias.", err))
+		return
+	}
+
+	var aliases []DirectoryAlias
+	if err := h.DB.Where("entity_type = ? AND entity_id = ?", input.Type, input.EntityID).Find(&aliases).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve aliases.", err))
+		return
+	}
+	c.JSON(http.StatusCreated, aliases)
+}
@@ -0,0 +1,476 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/utils"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Comparison metrics that players are ranked on within their cohort.
+const (
+	MetricGoalsPer90         = "goals_per90"
+	MetricAssistsPer90       = "assists_per90"
+	MetricContributionsPer90 = "goal_contributions_per90"
+	MetricMinutesPlayed      = "minutes_played"
+	MetricHeight             = "height"
+	MetricSprint30m          = "sprint_30m"
+)
+
+// CompareMetrics lists the ranked metrics; lowerIsBetter the ones where a
+// smaller value ranks higher.
+var (
+	CompareMetrics = []string{MetricGoalsPer90, MetricAssistsPer90, MetricContributionsPer90, MetricMinutesPlayed, MetricHeight, MetricSprint30m}
+	lowerIsBetter  = []string{MetricSprint30m}
+)
+
+const (
+	// MinMinutesPer90 is the playing time below which per-90 figures are
+	// too noisy to report.
+	MinMinutesPer90 = 450
+	// MinCohortSize is the smallest cohort percentiles are computed against.
+	MinCohortSize = 5
+)
+
+// AgeBracket is a range of ages, both inclusive, players are compared within.
+type AgeBracket struct {
+	Name   string
+	MinAge int
+	MaxAge int
+}
+
+var AgeBrackets = []AgeBracket{
+	{Name: "U17", MinAge: 0, MaxAge: 16},
+	{Name: "U19", MinAge: 17, MaxAge: 18},
+	{Name: "U21", MinAge: 19, MaxAge: 20},
+	{Name: "U23", MinAge: 21, MaxAge: 22},
+	{Name: "senior", MinAge: 23, MaxAge: 34},
+	{Name: "veteran", MinAge: 35, MaxAge: MaxPlayerAge},
+}
+
+// AgeBracketOf returns the bracket of a player aged age.
+func AgeBracketOf(age int) AgeBracket {
+	for _, b := range AgeBrackets {
+		if age <= b.MaxAge {
+			return b
+		}
+	}
+	return AgeBrackets[len(AgeBrackets)-1]
+}
+
+type CompareQuery struct {
+	IDs   []uint `form:"ids" collection_format:"csv" json:"ids" binding:"required,min=2,max=3,unique,dive,gt=0"`
+	Units string `form:"units" json:"units,omitempty" binding:"omitempty,oneof=metric imperial"`
+}
+
+// CareerTotals sums a player's season stats. Totals are nil when no season
+// recorded them.
+type CareerTotals struct {
+	Seasons       int  `json:"seasons"`
+	Clubs         int  `json:"clubs"`
+	Appearances   *int `json:"appearances"`
+	Goals         *int `json:"goals"`
+	Assists       *int `json:"assists"`
+	MinutesPlayed *int `json:"minutes_played"`
+	YellowCards   *int `json:"yellow_cards"`
+	RedCards      *int `json:"red_cards"`
+}
+
+// Per90Metrics are computed over the seasons with minutes recorded, and are
+// nil below MinMinutesPer90.
+type Per90Metrics struct {
+	Minutes           int      `json:"minutes"`
+	Goals             *float64 `json:"goals"`
+	Assists           *float64 `json:"assists"`
+	GoalContributions *float64 `json:"goal_contributions"`
+	Cards             *float64 `json:"cards"`
+}
+
+// PercentileRank places a value within the player's cohort: 90 means better
+// than 90% of players of the same position and age bracket. Values are in
+// metric units whatever units were asked for.
+type PercentileRank struct {
+	Value      float64 `json:"value"`
+	Percentile float64 `json:"percentile"`
+	CohortSize int     `json:"cohort_size"`
+}
+
+// ComparedPlayer is one column of a comparison. Every player has the same
+// keys; values that are not known are null and listed in Missing, never
+// zero-filled.
+type ComparedPlayer struct {
+	ProfileID      uint                       `json:"profile_id"`
+	Name           string                     `json:"name"`
+	Slug           string                     `json:"slug"`
+	Age            *int                       `json:"age"`
+	AgeBracket     string                     `json:"age_bracket"`
+	Position       string                     `json:"position"`
+	PositionGroup  string                     `json:"position_group"`
+	Positions      []string                   `json:"positions"`
+	PreferredFoot  *string                    `json:"preferred_foot"`
+	WeakFootRating *int                       `json:"weak_foot_rating"`
+	Height         *float64                   `json:"height"`
+	HeightUnit     string                     `json:"height_unit"`
+	Weight         *float64                   `json:"weight"`
+	WeightUnit     string                     `json:"weight_unit"`
+	PhysicalTests  map[string]*float64        `json:"physical_tests"`
+	Career         CareerTotals               `json:"career"`
+	Per90          Per90Metrics               `json:"per90"`
+	Percentiles    map[string]*PercentileRank `json:"percentiles"`
+	Missing        []string                   `json:"missing"`
+}
+
+type Comparison struct {
+	Players         []ComparedPlayer `json:"players"`
+	MinMinutesPer90 int              `json:"min_minutes_per90"`
+	MinCohortSize   int              `json:"min_cohort_size"`
+}
+
+// sumStat adds up one column of season stats, or returns nil when no season
+// recorded it.
+func sumStat(stats []SeasonStat, column func(SeasonStat) *int32) *int {
+	var total *int
+	for _, s := range stats {
+		if v := column(s); v != nil {
+			if total == nil {
+				total = new(int)
+			}
+			*total += int(*v)
+		}
+	}
+	return total
+}
+
+func per90(value *int, minutes int) *float64 {
+	if value == nil || minutes < MinMinutesPer90 {
+		return nil
+	}
+	rate := utils.Round(float64(*value)*90/float64(minutes), 2)
+	return &rate
+}
+
+func addInts(a, b *int) *int {
+	if a == nil || b == nil {
+		if a != nil {
+			return a
+		}
+		return b
+	}
+	sum := *a + *b
+	return &sum
+}
+
+// bestPhysicalTests keeps each player's best result per test: the fastest
+// sprint, the highest everything else.
+func bestPhysicalTests(tests []PhysicalTest) map[string]float64 {
+	best := map[string]float64{}
+	for _, t := range tests {
+		current, ok := best[t.TestType]
+		lower := PhysicalTestUnits[t.TestType] == "s"
+		if !ok || (lower && t.Value < current) || (!lower && t.Value > current) {
+			best[t.TestType] = t.Value
+		}
+	}
+	return best
+}
+
+// metricValues are the raw, metric-unit values a player is ranked on.
+func metricValues(height float64, sprint *float64, goals, assists *int, minutes int) map[string]*float64 {
+	values := map[string]*float64{
+		MetricGoalsPer90:         per90(goals, minutes),
+		MetricAssistsPer90:       per90(assists, minutes),
+		MetricContributionsPer90: per90(addInts(goals, assists), minutes),
+		MetricSprint30m:          sprint,
+	}
+	if minutes > 0 {
+		m := float64(minutes)
+		values[MetricMinutesPlayed] = &m
+	}
+	if height > 0 {
+		values[MetricHeight] = &height
+	}
+	return values
+}
+
+// percentileOf ranks value within cohort, counting ties as half.
+func percentileOf(value float64, cohort []float64, lowerBetter bool) float64 {
+	below, equal := 0, 0
+	for _, v := range cohort {
+		switch {
+		case v == value:
+			equal++
+		case (v < value) != lowerBetter:
+			below++
+		}
+	}
+	return utils.Round((float64(below)+float64(equal)/2)/float64(len(cohort))*100, 1)
+}
+
+// cohortMetrics collects every public player's metric values for a position
+// and age bracket, keyed by metric.
+func cohortMetrics(db *gorm.DB, position string, bracket AgeBracket, now time.Time) (map[string][]float64, error) {
+	ids := db.Model(&Profile{}).Scopes(PublicProfiles).Select("profiles.id").Where(
+		"profiles.position = ? AND profiles.dob <= ? AND profiles.dob > ?",
+		position, now.AddDate(-bracket.MinAge, 0, 0), now.AddDate(-bracket.MaxAge-1, 0, 0),
+	)
+
+	var heights []struct {
+		ID     uint
+		Height float64
+	}
+	if err := db.Model(&Profile{}).Select("id", "height").Where("id IN (?)", ids).Scan(&heights).Error; err != nil {
+		return nil, err
+	}
+	var sprints []struct {
+		ProfileID uint
+		Best      float64
+	}
+	err := db.Model(&PhysicalTest{}).Select("profile_id, MIN(value) AS best").
+		Where("profile_id IN (?) AND test_type = ?", ids, PhysicalTestSprint30m).
+		Group("profile_id").Scan(&sprints).Error
+	if err != nil {
+		return nil, err
+	}
+	var stats []struct {
+		ProfileID uint
+		Goals     *int
+		Assists   *int
+		Minutes   int
+	}
+	err = db.Model(&SeasonStat{}).Select("profile_id, SUM(goals) AS goals, SUM(assists) AS assists, SUM(minutes_played) AS minutes").
+		Where("profile_id IN (?) AND minutes_played IS NOT NULL", ids).
+		Group("profile_id").Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+
+	cohort := map[string][]float64{}
+	add := func(values map[string]*float64) {
+		for metric, v := range values {
+			if v != nil {
+				cohort[metric] = append(cohort[metric], *v)
+			}
+		}
+	}
+	for _, h := range heights {
+		add(metricValues(h.Height, nil, nil, nil, 0))
+	}
+	for _, s := range sprints {
+		add(map[string]*float64{MetricSprint30m: &s.Best})
+	}
+	for _, s := range stats {
+		add(metricValues(0, nil, s.Goals, s.Assists, s.Minutes))
+	}
+	return cohort, nil
+}
+
+// comparePlayer builds the column of one player, without percentiles.
+func comparePlayer(p Profile, units string) (ComparedPlayer, map[string]*float64) {
+	player := ComparedPlayer{
+		ProfileID:     p.ID,
+		Name:          p.FirstName + " " + p.LastName,
+		Slug:          p.Slug,
+		Position:      p.Position,
+		Positions:     []string{},
+		PhysicalTests: map[string]*float64{},
+		Percentiles:   map[string]*PercentileRank{},
+		Missing:       []string{},
+	}
+	if info, ok := LookupPosition(p.Position); ok {
+		player.PositionGroup = info.Group
+	}
+	for _, pos := range p.Positions {
+		player.Positions = append(player.Positions, pos.Position)
+	}
+	if len(player.Positions) == 0 && p.Position != "" {
+		player.Positions = append(player.Positions, p.Position)
+	}
+	if !p.Dob.IsZero() {
+		age := p.Age
+		player.Age = &age
+		player.AgeBracket = AgeBracketOf(age).Name
+	}
+	if p.PreferredFoot != "" {
+		player.PreferredFoot = &p.PreferredFoot
+	}
+	player.WeakFootRating = p.WeakFootRating
+	height, heightUnit := utils.HeightFromCm(p.Height, units)
+	weight, weightUnit := utils.WeightFromKg(p.Weight, units)
+	player.HeightUnit, player.WeightUnit = heightUnit, weightUnit
+	if p.Height > 0 {
+		player.Height = &height
+	}
+	if p.Weight > 0 {
+		player.Weight = &weight
+	}
+	best := bestPhysicalTests(p.PhysicalTests)
+	for testType, value := range best {
+		player.PhysicalTests[testType] = &value
+	}
+
+	seasons := map[string]bool{}
+	for _, s := range p.SeasonStats {
+		seasons[s.Season] = true
+	}
+	clubs := map[string]bool{}
+	for _, cp := range p.ClubProfiles {
+		clubs[clubKey(cp.ClubID, cp.ClubName)] = true
+	}
+	player.Career = CareerTotals{
+		Seasons:       len(seasons),
+		Clubs:         len(clubs),
+		Appearances:   sumStat(p.SeasonStats, func(s SeasonStat) *int32 { return s.Appearances }),
+		Goals:         sumStat(p.SeasonStats, func(s SeasonStat) *int32 { return s.Goals }),
+		Assists:       sumStat(p.SeasonStats, func(s SeasonStat) *int32 { return s.Assists }),
+		MinutesPlayed: sumStat(p.SeasonStats, func(s SeasonStat) *int32 { return s.MinutesPlayed }),
+		YellowCards:   sumStat(p.SeasonStats, func(s SeasonStat) *int32 { return s.YellowCards }),
+		RedCards:      sumStat(p.SeasonStats, func(s SeasonStat) *int32 { return s.RedCards }),
+	}
+
+	// Per-90 figures only use seasons that recorded minutes.
+	timed := slices.DeleteFunc(slices.Clone(p.SeasonStats), func(s SeasonStat) bool { return s.MinutesPlayed == nil })
+	minutes := derefInt(sumStat(timed, func(s SeasonStat) *int32 { return s.MinutesPlayed }))
+	goals := sumStat(timed, func(s SeasonStat) *int32 { return s.Goals })
+	assists := sumStat(timed, func(s SeasonStat) *int32 { return s.Assists })
+	cards := addInts(
+		sumStat(timed, func(s SeasonStat) *int32 { return s.YellowCards }),
+		sumStat(timed, func(s SeasonStat) *int32 { return s.RedCards }),
+	)
+	player.Per90 = Per90Metrics{
+		Minutes:           minutes,
+		Goals:             per90(goals, minutes),
+		Assists:           per90(assists, minutes),
+		GoalContributions: per90(addInts(goals, assists), minutes),
+		Cards:             per90(cards, minutes),
+	}
+
+	var sprint *float64
+	if v, ok := best[PhysicalTestSprint30m]; ok {
+		sprint = &v
+	}
+	return player, metricValues(p.Height, sprint, goals, assists, minutes)
+}
+
+func derefInt(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// alignComparison gives every player the same physical test keys and lists
+// the values each of them is missing.
+func alignComparison(players []ComparedPlayer) {
+	testTypes := map[string]bool{}
+	for _, p := range players {
+		for t := range p.PhysicalTests {
+			testTypes[t] = true
+		}
+	}
+	for i := range players {
+		p := &players[i]
+		for t := range testTypes {
+			if _, ok := p.PhysicalTests[t]; !ok {
+				p.PhysicalTests[t] = nil
+			}
+		}
+
+		missing := map[string]bool{
+			"age":                   p.Age == nil,
+			"preferred_foot":        p.PreferredFoot == nil,
+			"weak_foot_rating":      p.WeakFootRating == nil,
+			"height":                p.Height == nil,
+			"weight":                p.Weight == nil,
+			"career.appearances":    p.Career.Appearances == nil,
+			"career.goals":          p.Career.Goals == nil,
+			"career.assists":        p.Career.Assists == nil,
+			"career.minutes_played": p.Career.MinutesPlayed == nil,
+			"per90.goals":           p.Per90.Goals == nil,
+			"per90.assists":         p.Per90.Assists == nil,
+			"per90.cards":           p.Per90.Cards == nil,
+		}
+		for t, v := range p.PhysicalTests {
+			missing["physical_tests."+t] = v == nil
+		}
+		for metric, rank := range p.Percentiles {
+			missing["percentiles."+metric] = rank == nil
+		}
+		for field, isMissing := range missing {
+			if isMissing {
+				p.Missing = append(p.Missing, field)
+			}
+		}
+		sort.Strings(p.Missing)
+	}
+}
+
+// ComparePlayersGinHandler compares two or three players side by side, with
+// percentile ranks against players of the same position and age bracket.
+func (h *DBHandler) ComparePlayersGinHandler(c *gin.Context) {
+	var query CompareQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+
+	var profiles []Profile
+	err := h.DB.Scopes(PublicProfiles).
+		Preload("Positions", func(db *gorm.DB) *gorm.DB { return db.Order("is_primary DESC, id") }).
+		Preload("PhysicalTests").
+		Preload("ClubProfiles").
+		Preload("SeasonStats").
+		Where("profiles.id IN ?", query.IDs).
+		Find(&profiles).Error
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve profiles.", err))
+		return
+	}
+	if len(profiles) != len(query.IDs) {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeProfileNotFound, "One or more profiles do not exist."))
+		return
+	}
+	// Keep the order the players were asked for.
+	slices.SortFunc(profiles, func(a, b Profile) int {
+		return slices.Index(query.IDs, a.ID) - slices.Index(query.IDs, b.ID)
+	})
+
+	now := time.Now()
+	cohorts := map[string]map[string][]float64{}
+	comparison := Comparison{MinMinutesPer90: MinMinutesPer90, MinCohortSize: MinCohortSize}
+	for _, p := range profiles {
+		player, values := comparePlayer(p, query.Units)
+		for _, metric := range CompareMetrics {
+			player.Percentiles[metric] = nil
+		}
+		if player.Age != nil && p.Position != "" {
+			key := p.Position + "/" + player.AgeBracket
+			cohort, ok := cohorts[key]
+			if !ok {
+				if cohort, err = cohortMetrics(h.DB, p.Position, AgeBracketOf(*player.Age), now); err != nil {
+					api_errors.Abort(c, api_errors.Internal("Could not compute percentiles.", err))
+					return
+				}
+				cohorts[key] = cohort
+			}
+			for _, metric := range CompareMetrics {
+				value := values[metric]
+				if value == nil || len(cohort[metric]) < MinCohortSize {
+					continue
+				}
+				player.Percentiles[metric] = &PercentileRank{
+					Value:      *value,
+					Percentile: percentileOf(*value, cohort[metric], slices.Contains(lowerIsBetter, metric)),
+					CohortSize: len(cohort[metric]),
+				}
+			}
+		}
+		comparison.Players = append(comparison.Players, player)
+	}
+	alignComparison(comparison.Players)
+	c.JSON(http.StatusOK, comparison)
+}
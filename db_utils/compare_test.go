@@ -0,0 +1,29 @@
+package db_utils
+
+import "testing"
+
+func TestPercentileOf(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       float64
+		cohort      []float64
+		lowerBetter bool
+		want        float64
+	}{
+		{"middle of the cohort", 3, []float64{1, 2, 3, 4}, false, 62.5},
+		{"middle when lower is better", 3, []float64{1, 2, 3, 4}, true, 37.5},
+		{"above everyone", 5, []float64{1, 2, 3, 4}, false, 100},
+		{"below everyone", 0, []float64{1, 2, 3, 4}, false, 0},
+		{"fastest sprint", 3.9, []float64{4.1, 4.4, 3.9, 4.8}, true, 87.5},
+		{"everyone tied", 2, []float64{2, 2, 2}, false, 50},
+		{"alone in the cohort", 7, []float64{7}, false, 50},
+		{"rounded to one place", 1, []float64{1, 2, 3}, false, 16.7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentileOf(tt.value, tt.cohort, tt.lowerBetter); got != tt.want {
+				t.Errorf("percentileOf(%v, %v, %v) = %v, want %v", tt.value, tt.cohort, tt.lowerBetter, got, tt.want)
+			}
+		})
+	}
+}
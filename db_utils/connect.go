@@ -35,11 +35,73 @@ func ConnectAndMigrate() (*gorm.DB, error) {
 		&SocialLink{},
 		&ClubProfile{},
 		&SeasonStat{},
+		&ProfilePosition{},
+		&PhysicalTest{},
+		&SkillEndorsement{},
+		&Country{},
+		&League{},
+		&Club{},
+		&DirectoryAlias{},
+		&ClubAdmin{},
+		&VerificationRequest{},
+		&Shortlist{},
+		&ShortlistEntry{},
+		&ShortlistShare{},
+		&Notification{},
+		&SavedSearch{},
+		&SavedSearchMatch{},
+		&Conversation{},
+		&ConversationParticipant{},
+		&Message{},
+		&UserBlock{},
+		&UserReport{},
+		&GuardianLink{},
+		&TrialEvent{},
+		&TrialApplication{},
+		&Match{},
+		&CalendarFeed{},
+		&SimilarPlayer{},
+		&Nationality{},
+		&Highlight{},
+		&Follow{},
+		&ActivityEvent{},
+		&WebhookSubscription{},
+		&WebhookDelivery{},
 	)
 	if err != nil {
 		return nil, err
 	}
+
+	EnableEarthDistance(db)
+	if err := EnforceSinglePresentClub(db); err != nil {
+		return nil, err
+	}
+
+	// 3. Data migrations
+	if err := MigrateProfilePositions(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateSkills(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateClubDirectory(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateInjuries(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateLocations(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateNationalities(db); err != nil {
+		return nil, err
+	}
 	log.Println("Database migration completed successfully!")
 
+	// 4. Callbacks
+	if err := RegisterLeaderboardInvalidation(db); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
\ No newline at end of file
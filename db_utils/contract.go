@@ -0,0 +1,270 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Transfer statuses a player can advertise on their profile. The empty status
+// means they have not said.
+const (
+	TransferStatusNotAvailable = "not_available"
+	TransferStatusAvailable    = "available_for_transfer"
+	TransferStatusFreeAgent    = "free_agent"
+	TransferStatusOpenToTrials = "open_to_trials"
+)
+
+var TransferStatuses = []string{TransferStatusNotAvailable, TransferStatusAvailable, TransferStatusFreeAgent, TransferStatusOpenToTrials}
+
+// defaultContractNoticeDays is how long before a contract ends the player and
+// the scouts shortlisting them are told, unless CONTRACT_EXPIRY_NOTICE_DAYS
+// says otherwise.
+const defaultContractNoticeDays = 60
+
+func contractNotice() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("CONTRACT_EXPIRY_NOTICE_DAYS"))
+	if err != nil || days <= 0 {
+		days = defaultContractNoticeDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+type UpdateContract struct {
+	ContractType  string     `json:"contract_type" binding:"omitempty,contract_type"`
+	ContractStart *time.Time `json:"contract_start"`
+	ContractEnd   *time.Time `json:"contract_end"`
+}
+
+// CurrentContractEnd is when the contract at the player's present club ends,
+// from their preloaded ClubProfiles.
+func (p Profile) CurrentContractEnd() *time.Time {
+	for _, cp := range p.ClubProfiles {
+		if cp.IsPresentClub && cp.ContractEnd != nil {
+			return cp.ContractEnd
+		}
+	}
+	return nil
+}
+
+// FreeAgents narrows a profile query to players without a club: those who say
+// they are free agents, and those with a club history but no present club
+// under contract, unless they said they are not available.
+func FreeAgents(now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(
+			"profiles.transfer_status = ? OR (COALESCE(profiles.transfer_status, '') <> ? "+
+				"AND EXISTS (SELECT 1 FROM club_profiles cp WHERE cp.profile_id = profiles.id AND cp.deleted_at IS NULL) "+
+				"AND NOT EXISTS (SELECT 1 FROM club_profiles cp WHERE cp.profile_id = profiles.id AND cp.deleted_at IS NULL AND cp.is_present_club AND (cp.contract_end IS NULL OR cp.contract_end >= ?)))",
+			TransferStatusFreeAgent, TransferStatusNotAvailable, now,
+		)
+	}
+}
+
+// contractExpiresWithin keeps players whose present contract ends in the next
+// days days.
+func contractExpiresWithin(days int, now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(
+			"EXISTS (SELECT 1 FROM club_profiles cp WHERE cp.profile_id = profiles.id AND cp.deleted_at IS NULL AND cp.is_present_club AND cp.contract_end BETWEEN ? AND ?)",
+			now, now.AddDate(0, 0, days),
+		)
+	}
+}
+
+// contractFollowers are the users following the player or with them on one
+// of their shortlists. Nobody hears about a player who is no longer public.
+func contractFollowers(db *gorm.DB, profileID, playerUserID uint) ([]uint, error) {
+	var public int64
+	if err := db.Model(&Profile{}).Scopes(PublicProfiles).Where("profiles.id = ?", profileID).Count(&public).Error; err != nil {
+		return nil, err
+	}
+	if public == 0 {
+		return nil, nil
+	}
+	shortlisters := db.Model(&Shortlist{}).Select("shortlists.owner_id").
+		Joins("JOIN shortlist_entries e ON e.shortlist_id = shortlists.id AND e.deleted_at IS NULL").
+		Where("e.profile_id = ?", profileID)
+	followers := db.Model(&Follow{}).Select("user_id").Where("profile_id = ?", profileID)
+
+	var userIDs []uint
+	err := db.Raw("SELECT user_id FROM (? UNION ?) AS f (user_id) WHERE user_id <> ?", shortlisters, followers, playerUserID).
+		Scan(&userIDs).Error
+	return userIDs, err
+}
+
+// notifyContractExpiry tells the player and their followers that a
+// contract is about to end. The contract is marked as notified as soon as the
+// player has been told, so failures reaching followers are not retried.
+func notifyContractExpiry(db *gorm.DB, cp ClubProfile) error {
+	var profile Profile
+	if err := db.First(&profile, derefUint(cp.ProfileID)).Error; err != nil {
+		return err
+	}
+	end := cp.ContractEnd.Format("2 January 2006")
+	data := map[string]any{"profile_id": profile.ID, "club_profile_id": cp.ID, "contract_end": cp.ContractEnd}
+
+	err := Notify(db, profile.UserID, Notification{
+		Type:  NotificationContractExpiry,
+		Title: "Your contract is ending soon",
+		Body:  fmt.Sprintf("Your contract with %s ends on %s. Update your transfer status so scouts know whether you are available.", cp.ClubName, end),
+		Data:  data,
+	}, true, true)
+	if err != nil {
+		return err
+	}
+	// Once the player has been told the contract counts as notified: a
+	// follower who cannot be reached must not get the player e-mailed again.
+	if err := db.Model(&cp).Update("expiry_notified_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	followers, err := contractFollowers(db, profile.ID, profile.UserID)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, userID := range followers {
+		err := Notify(db, userID, Notification{
+			Type:  NotificationContractExpiry,
+			Title: fmt.Sprintf("%s %s's contract is ending", profile.FirstName, profile.LastName),
+			Body:  fmt.Sprintf("%s %s's contract with %s ends on %s.", profile.FirstName, profile.LastName, cp.ClubName, end),
+			Data:  data,
+		}, true, true)
+		if err != nil {
+			log.Printf("Contract expiry alert for club profile %d to user %d failed: %v", cp.ID, userID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d follower alert(s) failed", failed, len(followers))
+	}
+	return nil
+}
+
+// RunContractExpiryAlerts notifies every present-club contract ending within
+// the notice period that has not been notified yet.
+func RunContractExpiryAlerts(ctx context.Context, db *gorm.DB) error {
+	now := time.Now()
+	var contracts []ClubProfile
+	err := db.Where("is_present_club AND expiry_notified_at IS NULL AND contract_end BETWEEN ? AND ?", now, now.Add(contractNotice())).
+		Find(&contracts).Error
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, cp := range contracts {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := notifyContractExpiry(db, cp); err != nil {
+			log.Printf("Contract expiry alert for club profile %d failed: %v", cp.ID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d contract expiry alert(s) failed", failed)
+	}
+	return nil
+}
+
+// UpdateContractGinHandler records the dates of a club spell's contract. A
+// new end date is notified again when it comes close, and any change drops the
+// club's verification.
+func (h *DBHandler) UpdateContractGinHandler(c *gin.Context) {
+	var input UpdateContract
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	clubProfileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+	var clubProfile ClubProfile
+	err = h.DB.First(&clubProfile, clubProfileID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "Club profile not found."))
+		return
+	}
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve club profile.", err))
+		return
+	}
+	if _, err := requireOwnProfile(c, h.DB, derefUint(clubProfile.ProfileID)); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	changed := false
+	if input.ContractType != "" && input.ContractType != clubProfile.ContractType {
+		clubProfile.ContractType = input.ContractType
+		changed = true
+	}
+	if input.ContractStart != nil {
+		if clubProfile.ContractStart == nil || !clubProfile.ContractStart.Equal(*input.ContractStart) {
+			changed = true
+		}
+		clubProfile.ContractStart = input.ContractStart
+	}
+	if input.ContractEnd != nil {
+		if clubProfile.ContractEnd == nil || !clubProfile.ContractEnd.Equal(*input.ContractEnd) {
+			clubProfile.ExpiryNotifiedAt = nil
+			changed = true
+		}
+		clubProfile.ContractEnd = input.ContractEnd
+	}
+	// The club confirmed the spell as it was; a changed contract has to be
+	// verified again.
+	if changed {
+		clubProfile.Verification = Verification{}
+	}
+	if clubProfile.ContractStart != nil && clubProfile.ContractEnd != nil && clubProfile.ContractEnd.Before(*clubProfile.ContractStart) {
+		api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("contract_end", "after", "contract_start")))
+		return
+	}
+	if err := h.DB.Omit("Profile", "Club").Save(&clubProfile).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to update contract.", err))
+		return
+	}
+	notifyProfileWebhooks(h.DB, WebhookProfileUpdated, derefUint(clubProfile.ProfileID))
+	c.JSON(http.StatusOK, clubProfile)
+}
+
+// GetFreeAgentsGinHandler lists players without a club, most recently updated
+// first, with the usual profile filters.
+func (h *DBHandler) GetFreeAgentsGinHandler(c *gin.Context) {
+	var filter ProfileFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	if err := filter.Resolve(h.DB); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	profiles, err := GetProfiles(h.DB.Scopes(FreeAgents(time.Now())).Order("profiles.updated_at DESC"), filter)
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve free agents.", err))
+		return
+	}
+	for i := range profiles {
+		profiles[i].ConvertUnits(filter.Units)
+		profiles[i].LocalizeCountries(filter.Lang)
+		profiles[i].RedactMinor()
+	}
+	c.JSON(http.StatusOK, profiles)
+}
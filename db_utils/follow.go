@@ -0,0 +1,246 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Activity types.
+const (
+	ActivitySeasonStat  = "season_stat"
+	ActivityAchievement = "achievement"
+	ActivityClubMove    = "club_move"
+	ActivityHighlight   = "highlight"
+)
+
+// Follow is a user following a player's profile.
+type Follow struct {
+	gorm.Model
+
+	UserID    uint     `gorm:"not null;uniqueIndex:idx_follow" json:"user_id"`
+	ProfileID uint     `gorm:"not null;uniqueIndex:idx_follow;index" json:"profile_id"`
+	Profile   *Profile `json:"profile,omitempty"`
+}
+
+// ActivityEvent records something a player added to their profile. SubjectID
+// is the ID of the season stat, achievement, club spell or highlight; Data
+// carries what a feed needs to render it without loading the subject.
+type ActivityEvent struct {
+	gorm.Model
+
+	ProfileID uint           `gorm:"not null;index" json:"profile_id"`
+	Profile   *Profile       `json:"profile,omitempty"`
+	Type      string         `gorm:"size:30;not null" json:"type"`
+	SubjectID uint           `gorm:"not null" json:"subject_id"`
+	Title     string         `gorm:"size:200;not null" json:"title"`
+	Data      map[string]any `gorm:"serializer:json;type:jsonb" json:"data,omitempty"`
+}
+
+type FeedQuery struct {
+	BeforeID uint `form:"before_id" json:"before_id,omitempty"`
+	Limit    int  `form:"limit" json:"limit,omitempty" binding:"omitempty,gte=1,lte=100"`
+}
+
+// recordActivity stores an event for profileID. It runs from the AfterCreate
+// hooks below, in the transaction that created the subject.
+func recordActivity(tx *gorm.DB, profileID uint, event ActivityEvent) error {
+	if profileID == 0 {
+		return nil
+	}
+	event.ProfileID = profileID
+	return tx.Create(&event).Error
+}
+
+func (s *SeasonStat) AfterCreate(tx *gorm.DB) error {
+	title := fmt.Sprintf("Added the %s season", s.Season)
+	if s.ClubName != "" {
+		title += " at " + s.ClubName
+	}
+	err := recordActivity(tx, s.ProfileID, ActivityEvent{
+		Type:      ActivitySeasonStat,
+		SubjectID: s.ID,
+		Title:     title,
+		Data: map[string]any{
+			"season":      s.Season,
+			"club_name":   s.ClubName,
+			"appearances": s.Appearances,
+			"goals":       s.Goals,
+			"assists":     s.Assists,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	enqueueFromHook(tx, WebhookStatAdded, s.ProfileID, webhookRecord(s))
+	return nil
+}
+
+func (a *Achievement) AfterCreate(tx *gorm.DB) error {
+	err := recordActivity(tx, a.ProfileID, ActivityEvent{
+		Type:      ActivityAchievement,
+		SubjectID: a.ID,
+		Title:     a.Title,
+		Data:      map[string]any{"date_achieved": a.DateAchieved},
+	})
+	if err != nil {
+		return err
+	}
+	enqueueFromHook(tx, WebhookAchievementAdded, a.ProfileID, webhookRecord(a))
+	return nil
+}
+
+// AfterCreate records a move when the spell is at the player's present club.
+// Past spells filled in afterwards are career history, not news, though
+// webhooks still hear about every spell.
+func (cp *ClubProfile) AfterCreate(tx *gorm.DB) error {
+	profileID := derefUint(cp.ProfileID)
+	enqueueFromHook(tx, WebhookClubAdded, profileID, webhookRecord(cp))
+	if !cp.IsPresentClub {
+		return nil
+	}
+	return recordActivity(tx, profileID, ActivityEvent{
+		Type:      ActivityClubMove,
+		SubjectID: cp.ID,
+		Title:     "Joined " + cp.ClubName,
+		Data: map[string]any{
+			"club_id":       cp.ClubID,
+			"club_name":     cp.ClubName,
+			"contract_type": cp.ContractType,
+		},
+	})
+}
+
+func (hl *Highlight) AfterCreate(tx *gorm.DB) error {
+	err := recordActivity(tx, hl.ProfileID, ActivityEvent{
+		Type:      ActivityHighlight,
+		SubjectID: hl.ID,
+		Title:     hl.Title,
+		Data:      map[string]any{"url": hl.URL, "match_date": hl.MatchDate},
+	})
+	if err != nil {
+		return err
+	}
+	enqueueFromHook(tx, WebhookHighlightAdded, hl.ProfileID, webhookRecord(hl))
+	return nil
+}
+
+// followableProfile returns the public profile in the :id parameter, unless
+// its owner and the current user have blocked one another.
+func followableProfile(c *gin.Context, db *gorm.DB) (Profile, error) {
+	profileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return Profile{}, api_errors.InvalidID("id")
+	}
+	profile, err := requirePublicProfile(db, uint(profileID))
+	if err != nil {
+		return profile, err
+	}
+	userID := c.GetUint("userID")
+	if profile.UserID == userID {
+		return profile, api_errors.Invalid(api_errors.FieldErrorFor("id", "not_self", ""))
+	}
+	blocked, err := IsBlocked(db, userID, profile.UserID)
+	if err != nil {
+		return profile, api_errors.Internal("Could not verify profile.", err)
+	}
+	if blocked {
+		return profile, api_errors.Forbidden(api_errors.CodeUserBlocked, "You cannot follow this player.")
+	}
+	return profile, nil
+}
+
+func (h *DBHandler) FollowProfileGinHandler(c *gin.Context) {
+	profile, err := followableProfile(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	userID := c.GetUint("userID")
+	follow := Follow{UserID: userID, ProfileID: profile.ID}
+	if err := h.DB.Where("user_id = ? AND profile_id = ?", userID, profile.ID).FirstOrCreate(&follow).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to follow player.", err))
+		return
+	}
+	c.JSON(http.StatusCreated, follow)
+}
+
+func (h *DBHandler) UnfollowProfileGinHandler(c *gin.Context) {
+	profileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+	result := h.DB.Unscoped().Where("user_id = ? AND profile_id = ?", c.GetUint("userID"), profileID).Delete(&Follow{})
+	if result.Error != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to unfollow player.", result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "You do not follow this player."))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetFollowingGinHandler lists the players the current user follows, most
+// recently followed first.
+func (h *DBHandler) GetFollowingGinHandler(c *gin.Context) {
+	follows := []Follow{}
+	err := h.DB.Preload("Profile").
+		Where("user_id = ? AND profile_id IN (?)", c.GetUint("userID"), h.DB.Model(&Profile{}).Scopes(PublicProfiles).Select("profiles.id")).
+		Order("created_at DESC").Find(&follows).Error
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve followed players.", err))
+		return
+	}
+	for i := range follows {
+		if p := follows[i].Profile; p != nil {
+			p.RedactMinor()
+		}
+	}
+	c.JSON(http.StatusOK, follows)
+}
+
+// GetFeedGinHandler pages through the activity of the players the current
+// user follows, newest first. The feed is assembled on read from the follows,
+// so following or unfollowing a player changes it straight away. Pass the
+// smallest ID received as before_id to fetch older events.
+func (h *DBHandler) GetFeedGinHandler(c *gin.Context) {
+	var query FeedQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = 20
+	}
+	userID := c.GetUint("userID")
+
+	// Players who stopped being public, or whose owner blocked the user (or
+	// was blocked), drop out of the feed.
+	followed := h.DB.Model(&Follow{}).Select("follows.profile_id").
+		Joins("JOIN profiles ON profiles.id = follows.profile_id AND profiles.deleted_at IS NULL").
+		Scopes(PublicProfiles).
+		Where("follows.user_id = ?", userID).
+		Where("NOT EXISTS (SELECT 1 FROM user_blocks b WHERE b.deleted_at IS NULL AND ((b.blocker_id = ? AND b.blocked_id = profiles.user_id) OR (b.blocker_id = profiles.user_id AND b.blocked_id = ?)))", userID, userID)
+	db := h.DB.Preload("Profile").Where("profile_id IN (?)", followed)
+	if query.BeforeID > 0 {
+		db = db.Where("id < ?", query.BeforeID)
+	}
+	events := []ActivityEvent{}
+	if err := db.Order("id DESC").Limit(query.Limit).Find(&events).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve the feed.", err))
+		return
+	}
+	for i := range events {
+		if p := events[i].Profile; p != nil {
+			p.RedactMinor()
+		}
+	}
+	c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,333 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AdultAge is the age from which players manage their profile on their own.
+// Younger players need a guardian's consent before their profile is public.
+const AdultAge = 18
+
+// Guardian consent statuses.
+const (
+	GuardianPending   = "pending"
+	GuardianConsented = "consented"
+)
+
+// guardianInviteTTL is how long a guardian has to act on the consent e-mail.
+const guardianInviteTTL = 7 * 24 * time.Hour
+
+// GuardianLink ties a minor's profile to the account of the parent or
+// guardian who consented to it being public. A profile has at most one link;
+// asking a new guardian replaces the previous one.
+type GuardianLink struct {
+	gorm.Model
+
+	ProfileID      uint       `gorm:"not null;uniqueIndex" json:"profile_id"`
+	GuardianEmail  string     `gorm:"not null" json:"guardian_email"`
+	GuardianUserID *uint      `gorm:"index" json:"guardian_user_id"`
+	Guardian       *User      `json:"guardian,omitempty"`
+	Status         string     `gorm:"size:10;not null;default:'pending'" json:"status"`
+	TokenHash      string     `gorm:"index" json:"-"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	ConsentedAt    *time.Time `json:"consented_at"`
+}
+
+type RequestGuardianConsent struct {
+	ProfileID     uint   `json:"profile_id" binding:"required"`
+	GuardianEmail string `json:"guardian_email" binding:"required,email"`
+}
+
+type GiveGuardianConsent struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// PublicProfiles narrows query down to profiles anyone may see: adults, and
+// minors whose guardian has consented.
+func PublicProfiles(query *gorm.DB) *gorm.DB {
+	return query.Where(
+		"profiles.dob <= ? OR EXISTS (SELECT 1 FROM guardian_links g WHERE g.profile_id = profiles.id AND g.status = ? AND g.deleted_at IS NULL)",
+		time.Now().AddDate(-AdultAge, 0, 0), GuardianConsented,
+	)
+}
+
+// RedactMinor strips what must not be shown publicly about a minor: the exact
+// date of birth, the town they live in and its coordinates, and their e-mail
+// address. The profile must not be saved afterwards.
+func (p *Profile) RedactMinor() {
+	if !p.IsMinor {
+		return
+	}
+	p.Dob = time.Time{}
+	p.Location = utils.CoarseLocation(p.Location)
+	p.GeoLocation = GeoLocation{CountryCode: p.CountryCode}
+	p.DistanceKm = nil
+	p.User.Email = ""
+}
+
+// requirePublicProfile is requireProfile for profiles the caller does not
+// own: minors without a consenting guardian are reported as missing.
+func requirePublicProfile(db *gorm.DB, profileID uint) (Profile, error) {
+	profile, err := requireProfile(db, profileID)
+	if err != nil || !profile.IsMinor {
+		return profile, err
+	}
+	var count int64
+	if err := db.Model(&Profile{}).Scopes(PublicProfiles).Where("profiles.id = ?", profile.ID).Count(&count).Error; err != nil {
+		return profile, api_errors.Internal("Could not verify profile.", err)
+	}
+	if count == 0 {
+		return profile, api_errors.NotFound(api_errors.CodeProfileNotFound, "Profile with given ID does not exist.")
+	}
+	return profile, nil
+}
+
+// GuardianOf returns whether userID is a minor and, if so, the user ID of
+// their consenting guardian, 0 when there is none.
+func GuardianOf(db *gorm.DB, userID uint) (minor bool, guardianID uint, err error) {
+	var profile Profile
+	err = db.Select("id", "dob").Where("user_id = ?", userID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, 0, nil
+	}
+	if err != nil || !profile.IsMinor {
+		return false, 0, err
+	}
+	var link GuardianLink
+	err = db.Where("profile_id = ? AND status = ?", profile.ID, GuardianConsented).Find(&link).Error
+	return true, derefUint(link.GuardianUserID), err
+}
+
+// requireOwnProfile loads profileID and checks that it belongs to the current
+// user.
+func requireOwnProfile(c *gin.Context, db *gorm.DB, profileID uint) (Profile, error) {
+	profile, err := requireProfile(db, profileID)
+	if err != nil {
+		return profile, err
+	}
+	if profile.UserID != c.GetUint("userID") {
+		return profile, api_errors.Forbidden(api_errors.CodeForbidden, "You can only manage your own profile.")
+	}
+	return profile, nil
+}
+
+// RequestGuardianConsentGinHandler lets a minor name a guardian. The guardian
+// is e-mailed a consent token, which they confirm from their own account.
+func (h *DBHandler) RequestGuardianConsentGinHandler(c *gin.Context) {
+	var input RequestGuardianConsent
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	profile, err := requireOwnProfile(c, h.DB, input.ProfileID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if !profile.IsMinor {
+		api_errors.Abort(c, api_errors.BadRequest(api_errors.CodeNotMinor, "Only players under 18 need a guardian's consent."))
+		return
+	}
+	user, err := CurrentUser(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if strings.EqualFold(input.GuardianEmail, user.Email) {
+		api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("guardian_email", "not_self", "")))
+		return
+	}
+
+	token, hash, err := utils.NewToken()
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not create consent token.", err))
+		return
+	}
+	link := GuardianLink{
+		ProfileID:     profile.ID,
+		GuardianEmail: strings.ToLower(input.GuardianEmail),
+		Status:        GuardianPending,
+		TokenHash:     hash,
+		ExpiresAt:     time.Now().Add(guardianInviteTTL),
+	}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("profile_id = ?", profile.ID).Delete(&GuardianLink{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&link).Error
+	})
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to request guardian consent.", err))
+		return
+	}
+
+	body := fmt.Sprintf(
+		"%s %s has named you as their parent or guardian on ballerbio.\n\n"+
+			"Players under 18 need a guardian's consent before their profile is shown publicly. "+
+			"To consent, sign in or register with this e-mail address and submit this token to /guardians/consent:\n\n%s\n\n"+
+			"The token expires on %s. If you do not know this player, ignore this e-mail.",
+		profile.FirstName, profile.LastName, token, link.ExpiresAt.Format("2 January 2006"),
+	)
+	go func() {
+		if err := utils.SendEmail(link.GuardianEmail, "Consent request for "+profile.FirstName+"'s ballerbio profile", body); err != nil {
+			log.Printf("Guardian consent e-mail for profile %d failed: %v", profile.ID, err)
+		}
+	}()
+	c.JSON(http.StatusCreated, link)
+}
+
+// GiveGuardianConsentGinHandler links the current user as guardian of the
+// profile the token was issued for, making it public. The user's e-mail must
+// be the one the token was sent to.
+func (h *DBHandler) GiveGuardianConsentGinHandler(c *gin.Context) {
+	var input GiveGuardianConsent
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	user, err := CurrentUser(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	var link GuardianLink
+	err = h.DB.Where("token_hash = ? AND status = ?", utils.HashToken(input.Token), GuardianPending).First(&link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && time.Now().After(link.ExpiresAt)) {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeInvalidConsentToken, "The consent token is invalid or has expired."))
+		return
+	}
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve consent request.", err))
+		return
+	}
+	if !strings.EqualFold(link.GuardianEmail, user.Email) {
+		api_errors.Abort(c, api_errors.Forbidden(api_errors.CodeForbidden, "This consent request was sent to a different e-mail address."))
+		return
+	}
+	profile, err := GetProfileByID(h.DB, link.ProfileID)
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve profile.", err))
+		return
+	}
+	if profile.UserID == user.ID {
+		api_errors.Abort(c, api_errors.Forbidden(api_errors.CodeForbidden, "You cannot be your own guardian."))
+		return
+	}
+
+	now := time.Now()
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&link).Updates(map[string]any{
+			"status": GuardianConsented, "guardian_user_id": user.ID, "consented_at": now, "token_hash": "",
+		}).Error; err != nil {
+			return err
+		}
+		// The guardian sees every conversation of their ward.
+		var conversationIDs []uint
+		if err := tx.Model(&ConversationParticipant{}).Where("user_id = ?", profile.UserID).
+			Pluck("conversation_id", &conversationIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range conversationIDs {
+			participant := ConversationParticipant{ConversationID: id, UserID: user.ID}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&participant).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to record consent.", err))
+		return
+	}
+	notifyProfileWebhooks(h.DB, WebhookProfileUpdated, profile.ID)
+	link.Status = GuardianConsented
+	link.GuardianUserID = &user.ID
+	link.ConsentedAt = &now
+	c.JSON(http.StatusOK, link)
+}
+
+// GetGuardianLinkGinHandler shows the guardian status of a profile to its
+// owner and guardian.
+func (h *DBHandler) GetGuardianLinkGinHandler(c *gin.Context) {
+	link, err := h.requireGuardianLink(c)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, link)
+}
+
+// RemoveGuardianGinHandler withdraws consent. Either the player or the
+// guardian may do so; a minor's profile is hidden again until a new guardian
+// consents.
+func (h *DBHandler) RemoveGuardianGinHandler(c *gin.Context) {
+	link, err := h.requireGuardianLink(c)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if err := h.DB.Unscoped().Delete(link).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to remove guardian.", err))
+		return
+	}
+	notifyProfileHidden(h.DB, link.ProfileID)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *DBHandler) requireGuardianLink(c *gin.Context) (*GuardianLink, error) {
+	profileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, api_errors.InvalidID("id")
+	}
+	profile, err := requireProfile(h.DB, uint(profileID))
+	if err != nil {
+		return nil, err
+	}
+	var link GuardianLink
+	err = h.DB.Preload("Guardian", selectPublicUser).Where("profile_id = ?", profile.ID).First(&link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, api_errors.NotFound(api_errors.CodeNotFound, "This profile has no guardian.")
+	}
+	if err != nil {
+		return nil, api_errors.Internal("Could not retrieve guardian.", err)
+	}
+	userID := c.GetUint("userID")
+	if profile.UserID != userID && derefUint(link.GuardianUserID) != userID {
+		return nil, api_errors.Forbidden(api_errors.CodeForbidden, "Only the player and their guardian can manage the guardian.")
+	}
+	return &link, nil
+}
+
+// GetWardsGinHandler lists the full, unredacted profiles of the players the
+// current user is guardian of.
+func (h *DBHandler) GetWardsGinHandler(c *gin.Context) {
+	var profiles []Profile
+	err := h.DB.Preload("User").
+		Where("id IN (?)", h.DB.Model(&GuardianLink{}).Select("profile_id").
+			Where("guardian_user_id = ? AND status = ?", c.GetUint("userID"), GuardianConsented)).
+		Find(&profiles).Error
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve profiles.", err))
+		return
+	}
+	c.JSON(http.StatusOK, profiles)
+}
@@ -0,0 +1,83 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Highlight is a link to a video of the player, such as a match clip hosted
+// on YouTube or Vimeo.
+type Highlight struct {
+	gorm.Model
+
+	ProfileID   uint       `gorm:"not null;index" json:"profile_id"`
+	Title       string     `gorm:"size:200;not null" json:"title"`
+	URL         string     `gorm:"size:500;not null" json:"url"`
+	Description string     `gorm:"type:text" json:"description"`
+	MatchDate   *time.Time `json:"match_date"`
+}
+
+type AddHighlight struct {
+	ProfileID   uint       `json:"profile_id" binding:"required"`
+	Title       string     `json:"title" binding:"required,max=200"`
+	URL         string     `json:"url" binding:"required,max=500,http_url"`
+	Description string     `json:"description" binding:"max=2000"`
+	MatchDate   *time.Time `json:"match_date" binding:"omitempty,notfuture"`
+}
+
+func (h *DBHandler) AddHighlightGinHandler(c *gin.Context) {
+	var input AddHighlight
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	profile, err := requireOwnProfile(c, h.DB, input.ProfileID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	highlight := Highlight{
+		ProfileID:   profile.ID,
+		Title:       input.Title,
+		URL:         input.URL,
+		Description: input.Description,
+		MatchDate:   input.MatchDate,
+	}
+	if err := h.DB.Create(&highlight).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to add highlight.", err))
+		return
+	}
+	c.JSON(http.StatusCreated, highlight)
+}
+
+// GetHighlightsGinHandler lists a player's highlights, most recent match
+// first.
+func (h *DBHandler) GetHighlightsGinHandler(c *gin.Context) {
+	profileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+	profile, err := requirePublicProfile(h.DB, uint(profileID))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	highlights := []Highlight{}
+	if err := h.DB.Where("profile_id = ?", profile.ID).
+		Order("match_date DESC NULLS LAST, created_at DESC").Find(&highlights).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve highlights.", err))
+		return
+	}
+	c.JSON(http.StatusOK, highlights)
+}
@@ -0,0 +1,463 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Injury status of a player, derived from their injury history. A player is
+// injured while an injury has started and not ended, and returning for
+// ReturnToPlayWindow after the end of their latest injury.
+const (
+	InjuryStatusFit       = "fit"
+	InjuryStatusInjured   = "injured"
+	InjuryStatusReturning = "returning"
+)
+
+// AvailabilityAvailable matches fit and returning players in the profile
+// listing.
+const AvailabilityAvailable = "available"
+
+const (
+	ReturnToPlayWindow = 28 * 24 * time.Hour
+	// RecurrenceWindow is how soon after returning an injury to the same body
+	// area (and side) counts as a recurrence.
+	RecurrenceWindow = 365 * 24 * time.Hour
+)
+
+// seasonStartMonth is when split seasons ("2024/25") begin.
+const seasonStartMonth = time.July
+
+const (
+	BodyRegionHead      = "head_neck"
+	BodyRegionUpperLimb = "upper_limb"
+	BodyRegionTrunk     = "trunk"
+	BodyRegionLowerLimb = "lower_limb"
+	BodyRegionOther     = "other"
+)
+
+const BodyAreaOther = "other"
+
+type BodyAreaInfo struct {
+	Code    string   `json:"code"`
+	Name    string   `json:"name"`
+	Region  string   `json:"region"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// BodyAreaCatalogue is the injury taxonomy. Aliases are words seen in the
+// free-text InjuryType, used to classify injuries recorded without a body area.
+var BodyAreaCatalogue = []BodyAreaInfo{
+	{Code: "head", Name: "Head", Region: BodyRegionHead, Aliases: []string{"concussion", "face", "eye", "nose", "jaw", "cheekbone", "skull"}},
+	{Code: "neck", Name: "Neck", Region: BodyRegionHead, Aliases: []string{"whiplash", "cervical"}},
+	{Code: "shoulder", Name: "Shoulder", Region: BodyRegionUpperLimb, Aliases: []string{"collarbone", "clavicle", "rotator cuff", "ac joint"}},
+	{Code: "arm", Name: "Arm", Region: BodyRegionUpperLimb, Aliases: []string{"elbow", "forearm", "upper arm", "biceps", "triceps"}},
+	{Code: "hand", Name: "Wrist and Hand", Region: BodyRegionUpperLimb, Aliases: []string{"wrist", "finger", "thumb", "scaphoid"}},
+	{Code: "chest", Name: "Chest", Region: BodyRegionTrunk, Aliases: []string{"rib", "ribs", "sternum", "pectoral"}},
+	{Code: "back", Name: "Back", Region: BodyRegionTrunk, Aliases: []string{"lower back", "upper back", "spine", "lumbar", "disc", "vertebra"}},
+	{Code: "abdomen", Name: "Abdomen", Region: BodyRegionTrunk, Aliases: []string{"abdominal", "abs", "stomach", "oblique"}},
+	{Code: "hip", Name: "Hip", Region: BodyRegionLowerLimb, Aliases: []string{"pelvis", "hip flexor", "glute", "gluteal"}},
+	{Code: "groin", Name: "Groin", Region: BodyRegionLowerLimb, Aliases: []string{"adductor", "adductors", "pubalgia", "sports hernia", "osteitis pubis"}},
+	{Code: "hamstring", Name: "Hamstring", Region: BodyRegionLowerLimb, Aliases: []string{"hamstrings", "hammy", "back of thigh"}},
+	{Code: "quadriceps", Name: "Quadriceps", Region: BodyRegionLowerLimb, Aliases: []string{"quad", "quads", "thigh", "dead leg"}},
+	{Code: "knee", Name: "Knee", Region: BodyRegionLowerLimb, Aliases: []string{"acl", "mcl", "pcl", "lcl", "cruciate", "meniscus", "patella", "kneecap", "patellar tendon"}},
+	{Code: "calf", Name: "Calf", Region: BodyRegionLowerLimb, Aliases: []string{"calves", "soleus", "gastrocnemius"}},
+	{Code: "shin", Name: "Shin", Region: BodyRegionLowerLimb, Aliases: []string{"shin splints", "tibia", "fibula", "lower leg"}},
+	{Code: "achilles", Name: "Achilles", Region: BodyRegionLowerLimb, Aliases: []string{"achilles tendon", "achilles tendinopathy"}},
+	{Code: "ankle", Name: "Ankle", Region: BodyRegionLowerLimb, Aliases: []string{"ankles", "syndesmosis"}},
+	{Code: "foot", Name: "Foot", Region: BodyRegionLowerLimb, Aliases: []string{"toe", "toes", "metatarsal", "heel", "plantar fasciitis", "arch"}},
+	{Code: BodyAreaOther, Name: "Other", Region: BodyRegionOther, Aliases: []string{"illness", "virus", "fatigue"}},
+}
+
+var bodyAreaIndex = map[string]string{}
+
+func init() {
+	for _, a := range BodyAreaCatalogue {
+		bodyAreaIndex[normalizePositionKey(a.Code)] = a.Code
+		bodyAreaIndex[normalizePositionKey(a.Name)] = a.Code
+		for _, alias := range a.Aliases {
+			bodyAreaIndex[normalizePositionKey(alias)] = a.Code
+		}
+	}
+}
+
+// NormalizeBodyArea maps a catalogue code, name or alias ("ACL", "thigh") onto
+// a body area code.
+func NormalizeBodyArea(s string) (string, bool) {
+	code, ok := bodyAreaIndex[normalizePositionKey(s)]
+	return code, ok
+}
+
+// InferBodyArea classifies a free-text injury description such as "Torn left
+// hamstring" by the longest catalogue word it contains, or returns "other".
+func InferBodyArea(text string) string {
+	words := " " + normalizePositionKey(text) + " "
+	best, bestLen := BodyAreaOther, 0
+	for key, code := range bodyAreaIndex {
+		longer := len(key) > bestLen || (len(key) == bestLen && code < best)
+		if longer && strings.Contains(words, " "+key+" ") {
+			best, bestLen = code, len(key)
+		}
+	}
+	return best
+}
+
+// LookupBodyArea returns the catalogue entry for a body area code.
+func LookupBodyArea(code string) (BodyAreaInfo, bool) {
+	for _, a := range BodyAreaCatalogue {
+		if a.Code == code {
+			return a, true
+		}
+	}
+	return BodyAreaInfo{}, false
+}
+
+// SeasonOf returns the split season ("2024/25") a day falls in.
+func SeasonOf(t time.Time) string {
+	year := t.Year()
+	if t.Month() < seasonStartMonth {
+		year--
+	}
+	return fmt.Sprintf("%d/%02d", year, (year+1)%100)
+}
+
+func seasonStart(t time.Time) time.Time {
+	year := t.Year()
+	if t.Month() < seasonStartMonth {
+		year--
+	}
+	return time.Date(year, seasonStartMonth, 1, 0, 0, 0, 0, t.Location())
+}
+
+// daysBetween counts the whole days from start to end.
+func daysBetween(start, end time.Time) int {
+	if !end.After(start) {
+		return 0
+	}
+	return int(end.Sub(start).Hours() / 24)
+}
+
+// outUntil is when the player stopped being out with injury, as of now.
+func (i Injury) outUntil(now time.Time) time.Time {
+	if i.EndDate == nil || i.EndDate.After(now) {
+		return now
+	}
+	return *i.EndDate
+}
+
+// Ongoing reports whether the injury has started and not yet ended.
+func (i Injury) Ongoing(now time.Time) bool {
+	return i.StartDate != nil && !i.StartDate.After(now) && (i.EndDate == nil || i.EndDate.After(now))
+}
+
+// AnalyzeInjuries fills in DaysOut and the recurrence fields of injuries, and
+// sorts them by start date.
+func AnalyzeInjuries(injuries []Injury, now time.Time) {
+	slices.SortStableFunc(injuries, func(a, b Injury) int {
+		switch {
+		case a.StartDate == nil && b.StartDate == nil:
+			return 0
+		case a.StartDate == nil:
+			return 1
+		case b.StartDate == nil:
+			return -1
+		}
+		return a.StartDate.Compare(*b.StartDate)
+	})
+	for i := range injuries {
+		injury := &injuries[i]
+		injury.DaysOut, injury.IsRecurrence, injury.RecurrenceOfID = 0, false, nil
+		if injury.StartDate == nil || injury.StartDate.After(now) {
+			continue
+		}
+		injury.DaysOut = daysBetween(*injury.StartDate, injury.outUntil(now))
+
+		// The most recent earlier injury to the same place that had ended.
+		for j := i - 1; j >= 0; j-- {
+			previous := injuries[j]
+			if previous.BodyArea != injury.BodyArea || injury.BodyArea == BodyAreaOther || previous.EndDate == nil {
+				continue
+			}
+			if previous.Side != "" && injury.Side != "" && previous.Side != injury.Side {
+				continue
+			}
+			if !injury.StartDate.Before(*previous.EndDate) && injury.StartDate.Sub(*previous.EndDate) <= RecurrenceWindow {
+				injury.IsRecurrence = true
+				injury.RecurrenceOfID = &previous.ID
+			}
+			break
+		}
+	}
+}
+
+// InjuryStatusOf derives a player's injury status from their injuries.
+func InjuryStatusOf(injuries []Injury, now time.Time) string {
+	status := InjuryStatusFit
+	for _, injury := range injuries {
+		if injury.Ongoing(now) {
+			return InjuryStatusInjured
+		}
+		if injury.EndDate != nil && !injury.EndDate.After(now) && now.Sub(*injury.EndDate) < ReturnToPlayWindow {
+			status = InjuryStatusReturning
+		}
+	}
+	return status
+}
+
+// AnalyzeInjuries annotates the profile's preloaded injuries and sets its
+// InjuryStatus.
+func (p *Profile) AnalyzeInjuries(now time.Time) {
+	AnalyzeInjuries(p.Injuries, now)
+	p.InjuryStatus = InjuryStatusOf(p.Injuries, now)
+}
+
+// availabilityScope filters profiles by injury status in SQL, with the same
+// rules as InjuryStatusOf.
+func availabilityScope(availability string, now time.Time) func(*gorm.DB) *gorm.DB {
+	injured := "EXISTS (SELECT 1 FROM injuries i WHERE i.profile_id = profiles.id AND i.deleted_at IS NULL AND i.start_date <= ? AND (i.end_date IS NULL OR i.end_date > ?))"
+	returning := "EXISTS (SELECT 1 FROM injuries i WHERE i.profile_id = profiles.id AND i.deleted_at IS NULL AND i.end_date <= ? AND i.end_date > ?)"
+	since := now.Add(-ReturnToPlayWindow)
+	return func(db *gorm.DB) *gorm.DB {
+		switch availability {
+		case InjuryStatusInjured:
+			return db.Where(injured, now, now)
+		case InjuryStatusReturning:
+			return db.Where("NOT "+injured, now, now).Where(returning, now, since)
+		case InjuryStatusFit:
+			return db.Where("NOT "+injured, now, now).Where("NOT "+returning, now, since)
+		case AvailabilityAvailable:
+			return db.Where("NOT "+injured, now, now)
+		}
+		return db
+	}
+}
+
+type UpdateInjury struct {
+	InjuryType  *string    `json:"injury_type" binding:"omitempty,max=100"`
+	Description *string    `json:"description"`
+	BodyArea    string     `json:"body_area" binding:"omitempty,body_area"`
+	Side        string     `json:"side" binding:"omitempty,oneof=left right both"`
+	EndDate     *time.Time `json:"end_date"`
+}
+
+type InjurySummaryQuery struct {
+	ProfileID uint `form:"profile_id" json:"profile_id" binding:"required"`
+}
+
+type SeasonDaysOut struct {
+	Season   string `json:"season"`
+	DaysOut  int    `json:"days_out"`
+	Injuries int    `json:"injuries"`
+}
+
+type BodyAreaDaysOut struct {
+	BodyArea    string `json:"body_area"`
+	Name        string `json:"name"`
+	DaysOut     int    `json:"days_out"`
+	Injuries    int    `json:"injuries"`
+	Recurrences int    `json:"recurrences"`
+}
+
+// InjurySummary is a player's injury record. Days out of injuries spanning
+// two seasons are split between them; ongoing injuries count up to today.
+type InjurySummary struct {
+	ProfileID      uint              `json:"profile_id"`
+	Status         string            `json:"status"`
+	CurrentInjury  *Injury           `json:"current_injury"`
+	TotalDaysOut   int               `json:"total_days_out"`
+	InjuryCount    int               `json:"injury_count"`
+	Recurrences    int               `json:"recurrences"`
+	BySeason       []SeasonDaysOut   `json:"by_season"`
+	ByBodyArea     []BodyAreaDaysOut `json:"by_body_area"`
+	RecentInjuries []Injury          `json:"recent_injuries"`
+}
+
+// SummarizeInjuries builds the injury record of a player. injuries must have
+// been through AnalyzeInjuries.
+func SummarizeInjuries(profileID uint, injuries []Injury, now time.Time) InjurySummary {
+	summary := InjurySummary{
+		ProfileID:      profileID,
+		Status:         InjuryStatusOf(injuries, now),
+		InjuryCount:    len(injuries),
+		BySeason:       []SeasonDaysOut{},
+		ByBodyArea:     []BodyAreaDaysOut{},
+		RecentInjuries: []Injury{},
+	}
+	seasons := map[string]*SeasonDaysOut{}
+	areas := map[string]*BodyAreaDaysOut{}
+	for _, injury := range injuries {
+		if injury.Ongoing(now) && summary.CurrentInjury == nil {
+			current := injury
+			summary.CurrentInjury = &current
+		}
+		summary.TotalDaysOut += injury.DaysOut
+		if injury.IsRecurrence {
+			summary.Recurrences++
+		}
+
+		area, ok := areas[injury.BodyArea]
+		if !ok {
+			info, _ := LookupBodyArea(injury.BodyArea)
+			area = &BodyAreaDaysOut{BodyArea: injury.BodyArea, Name: info.Name}
+			areas[injury.BodyArea] = area
+		}
+		area.Injuries++
+		area.DaysOut += injury.DaysOut
+		if injury.IsRecurrence {
+			area.Recurrences++
+		}
+
+		if injury.StartDate == nil || injury.StartDate.After(now) {
+			continue
+		}
+		end := injury.outUntil(now)
+		for start := *injury.StartDate; ; {
+			season := SeasonOf(start)
+			next := seasonStart(start).AddDate(1, 0, 0)
+			s, ok := seasons[season]
+			if !ok {
+				s = &SeasonDaysOut{Season: season}
+				seasons[season] = s
+			}
+			if start.Equal(*injury.StartDate) {
+				s.Injuries++
+			}
+			if !end.After(next) {
+				s.DaysOut += daysBetween(start, end)
+				break
+			}
+			s.DaysOut += daysBetween(start, next)
+			start = next
+		}
+	}
+
+	for _, s := range seasons {
+		summary.BySeason = append(summary.BySeason, *s)
+	}
+	slices.SortFunc(summary.BySeason, func(a, b SeasonDaysOut) int { return strings.Compare(b.Season, a.Season) })
+	for _, a := range areas {
+		summary.ByBodyArea = append(summary.ByBodyArea, *a)
+	}
+	slices.SortFunc(summary.ByBodyArea, func(a, b BodyAreaDaysOut) int {
+		if a.DaysOut != b.DaysOut {
+			return b.DaysOut - a.DaysOut
+		}
+		return strings.Compare(a.BodyArea, b.BodyArea)
+	})
+	for i := len(injuries) - 1; i >= 0 && len(summary.RecentInjuries) < 5; i-- {
+		summary.RecentInjuries = append(summary.RecentInjuries, injuries[i])
+	}
+	return summary
+}
+
+func (h *DBHandler) GetBodyAreasGinHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, BodyAreaCatalogue)
+}
+
+// GetInjurySummaryGinHandler returns a player's injury status, days out per
+// season and per body area, and recurrences.
+func (h *DBHandler) GetInjurySummaryGinHandler(c *gin.Context) {
+	var query InjurySummaryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	if _, err := requirePublicProfile(h.DB, query.ProfileID); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	var injuries []Injury
+	if err := h.DB.Where("profile_id = ?", query.ProfileID).Find(&injuries).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve injuries.", err))
+		return
+	}
+	now := time.Now()
+	AnalyzeInjuries(injuries, now)
+	c.JSON(http.StatusOK, SummarizeInjuries(query.ProfileID, injuries, now))
+}
+
+// UpdateInjuryGinHandler corrects an injury or records the player's return by
+// setting its end date.
+func (h *DBHandler) UpdateInjuryGinHandler(c *gin.Context) {
+	var input UpdateInjury
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	injuryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+	var injury Injury
+	err = h.DB.First(&injury, injuryID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "Injury not found."))
+		return
+	}
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve injury.", err))
+		return
+	}
+	if _, err := requireOwnProfile(c, h.DB, injury.ProfileID); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	if input.InjuryType != nil {
+		injury.InjuryType = *input.InjuryType
+	}
+	if input.Description != nil {
+		injury.Description = *input.Description
+	}
+	if input.BodyArea != "" {
+		injury.BodyArea, _ = NormalizeBodyArea(input.BodyArea)
+	}
+	if input.Side != "" {
+		injury.Side = input.Side
+	}
+	if input.EndDate != nil {
+		if injury.StartDate != nil && input.EndDate.Before(*injury.StartDate) {
+			api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("end_date", "after", "start_date")))
+			return
+		}
+		injury.EndDate = input.EndDate
+	}
+	if err := h.DB.Save(&injury).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to update injury.", err))
+		return
+	}
+	notifyProfileWebhooks(h.DB, WebhookProfileUpdated, injury.ProfileID)
+	c.JSON(http.StatusOK, injury)
+}
+
+// MigrateInjuries classifies injuries recorded before the body area taxonomy
+// from their InjuryType and Description.
+func MigrateInjuries(db *gorm.DB) error {
+	var injuries []Injury
+	return db.Where("body_area IS NULL OR body_area = ''").FindInBatches(&injuries, 200, func(tx *gorm.DB, batch int) error {
+		for _, injury := range injuries {
+			area := InferBodyArea(injury.InjuryType)
+			if area == BodyAreaOther {
+				area = InferBodyArea(injury.Description)
+			}
+			if err := db.Model(&Injury{}).Where("id = ?", injury.ID).Update("body_area", area).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
@@ -0,0 +1,201 @@
+package db_utils
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var injuryNow = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func injury(id uint, area, side string, start, end *time.Time) Injury {
+	return Injury{Model: gorm.Model{ID: id}, BodyArea: area, Side: side, StartDate: start, EndDate: end}
+}
+
+func TestAnalyzeInjuries(t *testing.T) {
+	tests := []struct {
+		name     string
+		injuries []Injury
+		// Per injury in start order: its ID, days out and the ID it recurs
+		// from, or 0.
+		wantIDs        []uint
+		wantDaysOut    []int
+		wantRecurrence []uint
+	}{
+		{
+			name: "same area within a year recurs",
+			injuries: []Injury{
+				injury(1, "hamstring", "", day(2024, 1, 1), day(2024, 2, 1)),
+				injury(2, "hamstring", "", day(2024, 6, 1), day(2024, 7, 1)),
+			},
+			wantIDs:        []uint{1, 2},
+			wantDaysOut:    []int{31, 30},
+			wantRecurrence: []uint{0, 1},
+		},
+		{
+			name: "other side does not recur",
+			injuries: []Injury{
+				injury(1, "hamstring", "left", day(2024, 1, 1), day(2024, 2, 1)),
+				injury(2, "hamstring", "right", day(2024, 6, 1), day(2024, 7, 1)),
+			},
+			wantIDs:        []uint{1, 2},
+			wantDaysOut:    []int{31, 30},
+			wantRecurrence: []uint{0, 0},
+		},
+		{
+			name: "unknown side matches either",
+			injuries: []Injury{
+				injury(1, "hamstring", "left", day(2024, 1, 1), day(2024, 2, 1)),
+				injury(2, "hamstring", "", day(2024, 6, 1), day(2024, 7, 1)),
+			},
+			wantIDs:        []uint{1, 2},
+			wantDaysOut:    []int{31, 30},
+			wantRecurrence: []uint{0, 1},
+		},
+		{
+			name: "outside the recurrence window",
+			injuries: []Injury{
+				injury(1, "knee", "", day(2022, 12, 1), day(2023, 1, 1)),
+				injury(2, "knee", "", day(2024, 3, 1), day(2024, 4, 1)),
+			},
+			wantIDs:        []uint{1, 2},
+			wantDaysOut:    []int{31, 31},
+			wantRecurrence: []uint{0, 0},
+		},
+		{
+			name: "other area never recurs",
+			injuries: []Injury{
+				injury(1, BodyAreaOther, "", day(2024, 1, 1), day(2024, 1, 8)),
+				injury(2, BodyAreaOther, "", day(2024, 2, 1), day(2024, 2, 8)),
+			},
+			wantIDs:        []uint{1, 2},
+			wantDaysOut:    []int{7, 7},
+			wantRecurrence: []uint{0, 0},
+		},
+		{
+			name: "recurs from the latest earlier injury",
+			injuries: []Injury{
+				injury(3, "calf", "", day(2023, 6, 1), day(2023, 7, 1)),
+				injury(1, "calf", "", day(2023, 1, 1), day(2023, 2, 1)),
+				injury(2, "calf", "", day(2023, 3, 1), day(2023, 4, 1)),
+			},
+			wantIDs:        []uint{1, 2, 3},
+			wantDaysOut:    []int{31, 31, 30},
+			wantRecurrence: []uint{0, 1, 2},
+		},
+		{
+			name: "starting before the earlier one ended is not a recurrence",
+			injuries: []Injury{
+				injury(1, "ankle", "", day(2024, 1, 1), day(2024, 3, 1)),
+				injury(2, "ankle", "", day(2024, 2, 1), day(2024, 4, 1)),
+			},
+			wantIDs:        []uint{1, 2},
+			wantDaysOut:    []int{60, 60},
+			wantRecurrence: []uint{0, 0},
+		},
+		{
+			name: "earlier ongoing injury is skipped",
+			injuries: []Injury{
+				injury(1, "back", "", day(2024, 1, 1), nil),
+				injury(2, "back", "", day(2024, 3, 1), day(2024, 4, 1)),
+			},
+			wantIDs:        []uint{1, 2},
+			wantDaysOut:    []int{517, 31},
+			wantRecurrence: []uint{0, 0},
+		},
+		{
+			name: "undated last and future injuries not counted",
+			injuries: []Injury{
+				injury(3, "foot", "", nil, nil),
+				injury(2, "foot", "", day(2025, 7, 1), day(2025, 8, 1)),
+				injury(1, "knee", "", day(2025, 5, 1), nil),
+			},
+			wantIDs:        []uint{1, 2, 3},
+			wantDaysOut:    []int{31, 0, 0},
+			wantRecurrence: []uint{0, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			AnalyzeInjuries(tt.injuries, injuryNow)
+			var ids, recurrence []uint
+			var daysOut []int
+			for _, i := range tt.injuries {
+				ids = append(ids, i.ID)
+				daysOut = append(daysOut, i.DaysOut)
+				var of uint
+				if i.RecurrenceOfID != nil {
+					of = *i.RecurrenceOfID
+				}
+				if i.IsRecurrence != (of != 0) {
+					t.Errorf("injury %d: IsRecurrence = %v with RecurrenceOfID %d", i.ID, i.IsRecurrence, of)
+				}
+				recurrence = append(recurrence, of)
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("order = %v, want %v", ids, tt.wantIDs)
+			}
+			if !slices.Equal(daysOut, tt.wantDaysOut) {
+				t.Errorf("days out = %v, want %v", daysOut, tt.wantDaysOut)
+			}
+			if !slices.Equal(recurrence, tt.wantRecurrence) {
+				t.Errorf("recurrence of = %v, want %v", recurrence, tt.wantRecurrence)
+			}
+		})
+	}
+}
+
+func TestSummarizeInjuries(t *testing.T) {
+	injuries := []Injury{
+		// Spans the 2023/24 and 2024/25 seasons: 61 days then 31.
+		injury(1, "hamstring", "left", day(2024, 5, 1), day(2024, 8, 1)),
+		injury(2, "hamstring", "left", day(2024, 10, 1), day(2024, 11, 1)),
+		injury(3, "knee", "", day(2025, 5, 1), nil),
+	}
+	AnalyzeInjuries(injuries, injuryNow)
+	summary := SummarizeInjuries(7, injuries, injuryNow)
+
+	if summary.ProfileID != 7 || summary.Status != InjuryStatusInjured {
+		t.Errorf("profile %d status %q, want 7 %q", summary.ProfileID, summary.Status, InjuryStatusInjured)
+	}
+	if summary.CurrentInjury == nil || summary.CurrentInjury.ID != 3 {
+		t.Errorf("current injury = %v, want 3", summary.CurrentInjury)
+	}
+	if summary.TotalDaysOut != 154 || summary.InjuryCount != 3 || summary.Recurrences != 1 {
+		t.Errorf("totals = %d days, %d injuries, %d recurrences, want 154, 3, 1",
+			summary.TotalDaysOut, summary.InjuryCount, summary.Recurrences)
+	}
+	wantSeasons := []SeasonDaysOut{
+		{Season: "2024/25", DaysOut: 93, Injuries: 2},
+		{Season: "2023/24", DaysOut: 61, Injuries: 1},
+	}
+	if !slices.Equal(summary.BySeason, wantSeasons) {
+		t.Errorf("by season = %+v, want %+v", summary.BySeason, wantSeasons)
+	}
+	wantAreas := []BodyAreaDaysOut{
+		{BodyArea: "hamstring", Name: "Hamstring", DaysOut: 123, Injuries: 2, Recurrences: 1},
+		{BodyArea: "knee", Name: "Knee", DaysOut: 31, Injuries: 1},
+	}
+	if !slices.Equal(summary.ByBodyArea, wantAreas) {
+		t.Errorf("by body area = %+v, want %+v", summary.ByBodyArea, wantAreas)
+	}
+	var recent []uint
+	for _, i := range summary.RecentInjuries {
+		recent = append(recent, i.ID)
+	}
+	if !slices.Equal(recent, []uint{3, 2, 1}) {
+		t.Errorf("recent injuries = %v, want [3 2 1]", recent)
+	}
+}
+
+func TestSummarizeInjuriesEmpty(t *testing.T) {
+	summary := SummarizeInjuries(7, nil, injuryNow)
+	if summary.Status != InjuryStatusFit || summary.CurrentInjury != nil {
+		t.Errorf("status %q, current %v, want fit with no current injury", summary.Status, summary.CurrentInjury)
+	}
+	if summary.BySeason == nil || summary.ByBodyArea == nil || summary.RecentInjuries == nil {
+		t.Error("empty summary lists must encode as [], not null")
+	}
+}
@@ -0,0 +1,307 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/utils"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Leaderboard categories. Discipline ranks players by disciplinary points, a
+// yellow card counting one and a red card three.
+const (
+	LeaderboardGoals      = "goals"
+	LeaderboardAssists    = "assists"
+	LeaderboardMinutes    = "minutes"
+	LeaderboardDiscipline = "discipline"
+)
+
+var LeaderboardCategories = []string{LeaderboardGoals, LeaderboardAssists, LeaderboardMinutes, LeaderboardDiscipline}
+
+// leaderboardValues is the SQL aggregate each category ranks on.
+var leaderboardValues = map[string]string{
+	LeaderboardGoals:      "SUM(s.goals)",
+	LeaderboardAssists:    "SUM(s.assists)",
+	LeaderboardMinutes:    "SUM(s.minutes_played)",
+	LeaderboardDiscipline: "COALESCE(SUM(s.yellow_cards), 0) + 3 * COALESCE(SUM(s.red_cards), 0)",
+}
+
+// leaderboardCacheTTL bounds how stale a leaderboard gets through profile
+// changes (position, visibility, age group). Season stat changes invalidate
+// it straight away.
+const leaderboardCacheTTL = 10 * time.Minute
+
+// LeaderboardQuery filters the season stats a leaderboard is computed from.
+// Position filters match the primary position only, so every player appears
+// on one position's board.
+type LeaderboardQuery struct {
+	Season        string `form:"season" json:"season,omitempty" binding:"omitempty,season"`
+	LeagueID      uint   `form:"league_id" json:"league_id,omitempty"`
+	Country       string `form:"country" json:"country,omitempty" binding:"max=100"`
+	Position      string `form:"position" json:"position,omitempty" binding:"omitempty,position"`
+	PositionGroup string `form:"position_group" json:"position_group,omitempty" binding:"omitempty,oneof=goalkeeper defender midfielder forward"`
+	AgeGroup      string `form:"age_group" json:"age_group,omitempty" binding:"omitempty,age_group"`
+	Verified      bool   `form:"verified" json:"verified,omitempty"`
+	Limit         int    `form:"limit" json:"limit,omitempty" binding:"omitempty,gte=1,lte=100"`
+	Offset        int    `form:"offset" json:"offset,omitempty" binding:"omitempty,gte=0"`
+}
+
+// LeaderboardEntry is one player's totals over the matching seasons. Rank is
+// shared by tied players.
+type LeaderboardEntry struct {
+	Rank          int    `json:"rank"`
+	ProfileID     uint   `json:"profile_id"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	Slug          string `json:"slug"`
+	Position      string `json:"position"`
+	Age           *int   `json:"age"`
+	Value         int    `json:"value"`
+	Seasons       int    `json:"seasons"`
+	Appearances   int    `json:"appearances"`
+	Goals         int    `json:"goals"`
+	Assists       int    `json:"assists"`
+	MinutesPlayed int    `json:"minutes_played"`
+	YellowCards   int    `json:"yellow_cards"`
+	RedCards      int    `json:"red_cards"`
+}
+
+type Leaderboard struct {
+	Category    string             `json:"category"`
+	Filters     LeaderboardQuery   `json:"filters"`
+	Total       int                `json:"total"`
+	Entries     []LeaderboardEntry `json:"entries"`
+	GeneratedAt time.Time          `json:"generated_at"`
+}
+
+// leaderboardKey identifies a cached leaderboard. Filters are normalized so
+// "England" and "GB-ENG", or "u21" and "U21", share an entry.
+type leaderboardKey struct {
+	Category      string
+	Season        string
+	LeagueID      uint
+	CountryID     uint
+	Position      string
+	PositionGroup string
+	AgeGroup      string
+	Verified      bool
+	Limit         int
+	Offset        int
+}
+
+type cachedLeaderboard struct {
+	Generation uint64
+	Expires    time.Time
+	Board      Leaderboard
+}
+
+// leaderboardCache holds computed leaderboards until season stats change.
+// Writes bump the generation, so a leaderboard computed while stats were
+// changing is never stored. A write still uncommitted when it is bumped is
+// picked up when the entry expires.
+type leaderboardCache struct {
+	mu         sync.Mutex
+	generation uint64
+	entries    map[leaderboardKey]cachedLeaderboard
+}
+
+const maxCachedLeaderboards = 500
+
+var leaderboards = &leaderboardCache{entries: map[leaderboardKey]cachedLeaderboard{}}
+
+func (c *leaderboardCache) get(key leaderboardKey, now time.Time) (Leaderboard, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.entries[key]
+	if !ok || cached.Generation != c.generation || now.After(cached.Expires) {
+		return Leaderboard{}, c.generation, false
+	}
+	return cached.Board, c.generation, true
+}
+
+func (c *leaderboardCache) put(key leaderboardKey, generation uint64, board Leaderboard) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if len(c.entries) >= maxCachedLeaderboards {
+		clear(c.entries)
+	}
+	c.entries[key] = cachedLeaderboard{Generation: generation, Expires: board.GeneratedAt.Add(leaderboardCacheTTL), Board: board}
+}
+
+func (c *leaderboardCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	clear(c.entries)
+}
+
+// RegisterLeaderboardInvalidation drops cached leaderboards whenever a
+// statement writes to season_stats, whichever way it was issued.
+func RegisterLeaderboardInvalidation(db *gorm.DB) error {
+	invalidate := func(tx *gorm.DB) {
+		if tx.Error == nil && tx.Statement.Table == "season_stats" {
+			leaderboards.invalidate()
+		}
+	}
+	if err := db.Callback().Create().After("gorm:create").Register("leaderboard:invalidate", invalidate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("leaderboard:invalidate", invalidate); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register("leaderboard:invalidate", invalidate)
+}
+
+// ComputeLeaderboard ranks the public players on category over the season
+// stats matching key, in one query: totals are grouped per player and ranked
+// with window functions, so paging does not change ranks.
+func ComputeLeaderboard(db *gorm.DB, key leaderboardKey, now time.Time) ([]LeaderboardEntry, int, error) {
+	value := leaderboardValues[key.Category]
+	query := db.Table("season_stats s").
+		Joins("JOIN profiles ON profiles.id = s.profile_id AND profiles.deleted_at IS NULL").
+		Where("s.deleted_at IS NULL").
+		Scopes(PublicProfiles)
+	if key.Season != "" {
+		query = query.Where("s.season = ?", key.Season)
+	}
+	if key.LeagueID != 0 {
+		query = query.Where("s.league_id = ?", key.LeagueID)
+	}
+	if key.CountryID != 0 {
+		// A season counts for the country of its league, or of its club when
+		// the league is not in the directory.
+		query = query.
+			Joins("LEFT JOIN leagues l ON l.id = s.league_id").
+			Joins("LEFT JOIN clubs c ON c.id = s.club_id").
+			Where("COALESCE(l.country_id, c.country_id) = ?", key.CountryID)
+	}
+	// Given both, a player must match the position and the group.
+	if key.Position != "" {
+		query = query.Where("profiles.position = ?", key.Position)
+	}
+	if key.PositionGroup != "" {
+		query = query.Where("profiles.position IN ?", PositionsInGroup(key.PositionGroup))
+	}
+	// Age groups are ages today, as for trials: U21 keeps players under 21.
+	if key.AgeGroup == AgeGroupSenior {
+		query = query.Where("profiles.dob <= ?", now.AddDate(-SeniorMinimumAge, 0, 0))
+	} else if n, err := strconv.Atoi(strings.TrimPrefix(key.AgeGroup, "U")); err == nil {
+		query = query.Where("profiles.dob > ?", now.AddDate(-n, 0, 0))
+	}
+	if key.Verified {
+		query = query.Where("s.is_verified")
+	}
+
+	// The filtered query is reused for the count below.
+	query = query.Session(&gorm.Session{})
+
+	var rows []struct {
+		LeaderboardEntry
+		Dob   time.Time
+		Total int
+	}
+	err := query.Select(
+		"s.profile_id, profiles.first_name, profiles.last_name, profiles.slug, profiles.position, profiles.dob, " +
+			"COUNT(DISTINCT s.season) AS seasons, " +
+			"COALESCE(SUM(s.appearances), 0) AS appearances, COALESCE(SUM(s.goals), 0) AS goals, " +
+			"COALESCE(SUM(s.assists), 0) AS assists, COALESCE(SUM(s.minutes_played), 0) AS minutes_played, " +
+			"COALESCE(SUM(s.yellow_cards), 0) AS yellow_cards, COALESCE(SUM(s.red_cards), 0) AS red_cards, " +
+			value + " AS value, RANK() OVER (ORDER BY " + value + " DESC) AS rank, COUNT(*) OVER () AS total",
+	).
+		Group("s.profile_id, profiles.id").
+		Having(value + " > 0").
+		Order("rank, profiles.last_name, profiles.first_name, s.profile_id").
+		Limit(key.Limit).Offset(key.Offset).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]LeaderboardEntry, len(rows))
+	total := 0
+	for i, row := range rows {
+		entries[i] = row.LeaderboardEntry
+		if !row.Dob.IsZero() {
+			age := utils.AgeOn(row.Dob, now)
+			entries[i].Age = &age
+		}
+		total = row.Total
+	}
+	// Past the last page the window count is not available.
+	if len(rows) == 0 && key.Offset > 0 {
+		err := db.Raw("SELECT COUNT(*) FROM (?) ranked", query.Select("s.profile_id").Group("s.profile_id, profiles.id").Having(value+" > 0")).
+			Scan(&total).Error
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return entries, total, nil
+}
+
+// GetLeaderboardGinHandler serves a leaderboard from the cache, computing it
+// when season stats changed since it was last asked for.
+func (h *DBHandler) GetLeaderboardGinHandler(c *gin.Context) {
+	category := c.Param("category")
+	if !slices.Contains(LeaderboardCategories, category) {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "Unknown leaderboard."))
+		return
+	}
+	var query LeaderboardQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = 20
+	}
+	position, _ := NormalizePosition(query.Position)
+	ageGroup, _ := NormalizeAgeGroup(query.AgeGroup)
+	key := leaderboardKey{
+		Category:      category,
+		Season:        query.Season,
+		LeagueID:      query.LeagueID,
+		Position:      position,
+		PositionGroup: query.PositionGroup,
+		AgeGroup:      ageGroup,
+		Verified:      query.Verified,
+		Limit:         query.Limit,
+		Offset:        query.Offset,
+	}
+	if query.Country != "" {
+		country, err := ResolveCountry(h.DB, query.Country)
+		if err != nil {
+			api_errors.Abort(c, api_errors.Internal("Could not resolve country.", err))
+			return
+		}
+		if country == nil {
+			api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("country", "country", "")))
+			return
+		}
+		key.CountryID = country.ID
+	}
+
+	now := time.Now()
+	board, generation, ok := leaderboards.get(key, now)
+	if ok {
+		c.JSON(http.StatusOK, board)
+		return
+	}
+	entries, total, err := ComputeLeaderboard(h.DB, key, now)
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not compute the leaderboard.", err))
+		return
+	}
+	board = Leaderboard{Category: category, Filters: query, Total: total, Entries: entries, GeneratedAt: now}
+	leaderboards.put(key, generation, board)
+	c.JSON(http.StatusOK, board)
+}
@@ -0,0 +1,197 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/geo"
+	"ballerbio/utils"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// geocoder resolves the free-text locations of profiles and searches.
+var geocoder geo.Geocoder = geo.DefaultGazetteer()
+
+// SetGeocoder replaces the geocoder, e.g. with a larger gazetteer or an
+// online provider.
+func SetGeocoder(g geo.Geocoder) {
+	geocoder = g
+}
+
+const (
+	// CoordinateDecimals is the precision coordinates are stored with, about
+	// a kilometre: nothing finer than a neighbourhood is ever kept.
+	CoordinateDecimals = 2
+	// DefaultSearchRadiusKm applies when a search gives a centre but no
+	// radius.
+	DefaultSearchRadiusKm = 50
+)
+
+// GeoLocation is the structured form of Profile.Location.
+type GeoLocation struct {
+	City        string   `gorm:"size:100" json:"city,omitempty"`
+	Region      string   `gorm:"size:100" json:"region,omitempty"`
+	CountryCode string   `gorm:"size:2;index" json:"country_code,omitempty"`
+	Latitude    *float64 `gorm:"index:idx_profiles_lat_lng" json:"latitude"`
+	Longitude   *float64 `gorm:"index:idx_profiles_lat_lng" json:"longitude"`
+}
+
+// LocationInput sets a structured location. Without coordinates the city,
+// region and country are geocoded, or the free-text location when they are
+// not given either.
+type LocationInput struct {
+	City        string   `json:"city" binding:"max=100"`
+	Region      string   `json:"region" binding:"max=100"`
+	CountryCode string   `json:"country_code" binding:"omitempty,iso3166_1_alpha2"`
+	Latitude    *float64 `json:"latitude" binding:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude   *float64 `json:"longitude" binding:"required_with=Latitude,omitempty,gte=-180,lte=180"`
+}
+
+func roundCoordinate(v float64) *float64 {
+	rounded := utils.Round(v, CoordinateDecimals)
+	return &rounded
+}
+
+// ResolveLocation turns input, or the free-text location text, into a
+// structured location, and returns the text to show for it. A place the
+// geocoder does not know keeps its city, region and country without
+// coordinates.
+func ResolveLocation(text string, input LocationInput) (GeoLocation, string, error) {
+	loc := GeoLocation{City: strings.TrimSpace(input.City), Region: strings.TrimSpace(input.Region), CountryCode: strings.ToUpper(input.CountryCode)}
+	var parts []string
+	for _, part := range []string{loc.City, loc.Region, loc.CountryCode} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	if input.Latitude != nil && input.Longitude != nil {
+		loc.Latitude, loc.Longitude = roundCoordinate(*input.Latitude), roundCoordinate(*input.Longitude)
+	} else {
+		query := text
+		if len(parts) > 0 {
+			query = strings.Join(parts, ", ")
+		}
+		place, err := geocoder.Geocode(query)
+		if err != nil {
+			return loc, text, err
+		}
+		if place != nil {
+			loc = GeoLocation{
+				City:        place.Name,
+				Region:      place.Region,
+				CountryCode: place.CountryCode,
+				Latitude:    roundCoordinate(place.Latitude),
+				Longitude:   roundCoordinate(place.Longitude),
+			}
+		}
+	}
+	if strings.TrimSpace(text) == "" && loc.City != "" {
+		text = loc.City
+		if loc.CountryCode != "" {
+			text += ", " + utils.CountryName(loc.CountryCode, "en")
+		}
+	}
+	return loc, text, nil
+}
+
+// ResolveNear geocodes the place a radius search is centred on. Coordinates
+// given explicitly take precedence.
+func (f *ProfileFilter) ResolveNear() error {
+	if f.Lat != nil || strings.TrimSpace(f.Near) == "" {
+		return nil
+	}
+	place, err := geocoder.Geocode(f.Near)
+	if err != nil {
+		return api_errors.Internal("Could not geocode the search location.", err)
+	}
+	if place == nil {
+		return api_errors.Invalid(api_errors.FieldErrorFor("near", "place", ""))
+	}
+	f.Lat, f.Lng = &place.Latitude, &place.Longitude
+	return nil
+}
+
+// earthDistance is set when the earthdistance extension is installed; radius
+// searches fall back to a bounding box and the haversine formula otherwise.
+var earthDistance bool
+
+// EnableEarthDistance installs the earthdistance extension and its index if
+// the database allows it.
+func EnableEarthDistance(db *gorm.DB) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("CREATE EXTENSION IF NOT EXISTS cube").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("CREATE EXTENSION IF NOT EXISTS earthdistance").Error; err != nil {
+			return err
+		}
+		return tx.Exec("CREATE INDEX IF NOT EXISTS idx_profiles_earth ON profiles USING gist (ll_to_earth(latitude, longitude))").Error
+	})
+	if err != nil {
+		log.Printf("earthdistance is not available, radius searches use the haversine formula: %v", err)
+		return
+	}
+	earthDistance = true
+}
+
+// withinRadius keeps the profiles within radiusKm of a point. Minors are
+// never matched by distance.
+func withinRadius(lat, lng, radiusKm float64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("profiles.latitude IS NOT NULL AND profiles.longitude IS NOT NULL AND profiles.dob <= ?", time.Now().AddDate(-AdultAge, 0, 0))
+		if earthDistance {
+			return db.Where(
+				"earth_box(ll_to_earth(?, ?), ?) @> ll_to_earth(profiles.latitude, profiles.longitude) AND earth_distance(ll_to_earth(?, ?), ll_to_earth(profiles.latitude, profiles.longitude)) <= ?",
+				lat, lng, radiusKm*1000, lat, lng, radiusKm*1000,
+			)
+		}
+		minLat, maxLat, minLng, maxLng, wraps := geo.BoundingBox(lat, lng, radiusKm)
+		db = db.Where("profiles.latitude BETWEEN ? AND ?", minLat, maxLat)
+		if !wraps {
+			db = db.Where("profiles.longitude BETWEEN ? AND ?", minLng, maxLng)
+		}
+		return db.Where(
+			"2 * ? * ASIN(SQRT(LEAST(1, POWER(SIN(RADIANS(profiles.latitude - ?) / 2), 2) + COS(RADIANS(?)) * COS(RADIANS(profiles.latitude)) * POWER(SIN(RADIANS(profiles.longitude - ?) / 2), 2)))) <= ?",
+			geo.EarthRadiusKm, lat, lat, lng, radiusKm,
+		)
+	}
+}
+
+// SetDistanceFrom records how far the profile is from a point, to the
+// nearest kilometre.
+func (p *Profile) SetDistanceFrom(lat, lng float64) {
+	if p.Latitude == nil || p.Longitude == nil {
+		return
+	}
+	km := utils.Round(geo.Distance(lat, lng, *p.Latitude, *p.Longitude), 0)
+	p.DistanceKm = &km
+}
+
+// MigrateLocations geocodes the free-text location of profiles that predate
+// structured locations.
+func MigrateLocations(db *gorm.DB) error {
+	var profiles []Profile
+	return db.Where("latitude IS NULL AND city IS NULL AND location <> ''").FindInBatches(&profiles, 200, func(tx *gorm.DB, batch int) error {
+		for _, p := range profiles {
+			loc, _, err := ResolveLocation(p.Location, LocationInput{})
+			if err != nil {
+				return err
+			}
+			// An empty city marks profiles the geocoder could not place, so
+			// they are not looked up again on every start.
+			if err := db.Model(&Profile{}).Where("id = ?", p.ID).Updates(map[string]any{
+				"city":         loc.City,
+				"region":       loc.Region,
+				"country_code": loc.CountryCode,
+				"latitude":     loc.Latitude,
+				"longitude":    loc.Longitude,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
@@ -0,0 +1,162 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Where a match is played from the player's side.
+const (
+	MatchHome    = "home"
+	MatchAway    = "away"
+	MatchNeutral = "neutral"
+)
+
+// Match is a fixture logged by a player, past or upcoming. Score and personal
+// figures are filled in once it has been played.
+type Match struct {
+	gorm.Model
+
+	ProfileID     uint      `gorm:"not null;index" json:"profile_id"`
+	ClubID        *uint     `gorm:"index" json:"club_id"`
+	ClubName      string    `gorm:"size:100" json:"club_name"`
+	Opponent      string    `gorm:"size:100;not null" json:"opponent"`
+	Competition   string    `gorm:"size:100" json:"competition"`
+	KickoffAt     time.Time `gorm:"not null;index" json:"kickoff_at"`
+	TimeZone      string    `gorm:"size:64" json:"time_zone"`
+	Venue         string    `gorm:"size:200" json:"venue"`
+	HomeAway      string    `gorm:"size:10" json:"home_away"`
+	GoalsFor      *int      `json:"goals_for"`
+	GoalsAgainst  *int      `json:"goals_against"`
+	MinutesPlayed *int      `json:"minutes_played"`
+	Goals         *int      `json:"goals"`
+	Assists       *int      `json:"assists"`
+}
+
+type AddMatch struct {
+	ProfileID     uint      `json:"profile_id" binding:"required"`
+	ClubID        *uint     `json:"club_id"`
+	ClubName      string    `json:"club_name" binding:"max=100"`
+	Opponent      string    `json:"opponent" binding:"required,max=100"`
+	Competition   string    `json:"competition" binding:"max=100"`
+	KickoffAt     time.Time `json:"kickoff_at" binding:"required"`
+	TimeZone      string    `json:"time_zone" binding:"omitempty,timezone"`
+	Venue         string    `json:"venue" binding:"max=200"`
+	HomeAway      string    `json:"home_away" binding:"omitempty,oneof=home away neutral"`
+	GoalsFor      *int      `json:"goals_for" binding:"omitempty,gte=0"`
+	GoalsAgainst  *int      `json:"goals_against" binding:"omitempty,gte=0"`
+	MinutesPlayed *int      `json:"minutes_played" binding:"omitempty,gte=0,lte=130"`
+	Goals         *int      `json:"goals" binding:"omitempty,gte=0"`
+	Assists       *int      `json:"assists" binding:"omitempty,gte=0"`
+}
+
+type MatchesQuery struct {
+	ProfileID uint `form:"profile_id" json:"profile_id" binding:"required"`
+	Upcoming  bool `form:"upcoming" json:"upcoming,omitempty"`
+}
+
+func (h *DBHandler) AddMatchGinHandler(c *gin.Context) {
+	var input AddMatch
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	profile, err := requireOwnProfile(c, h.DB, input.ProfileID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	match := Match{
+		ProfileID:     profile.ID,
+		ClubName:      input.ClubName,
+		Opponent:      input.Opponent,
+		Competition:   input.Competition,
+		KickoffAt:     input.KickoffAt,
+		TimeZone:      input.TimeZone,
+		Venue:         input.Venue,
+		HomeAway:      input.HomeAway,
+		GoalsFor:      input.GoalsFor,
+		GoalsAgainst:  input.GoalsAgainst,
+		MinutesPlayed: input.MinutesPlayed,
+		Goals:         input.Goals,
+		Assists:       input.Assists,
+	}
+	if input.ClubID != nil || input.ClubName != "" {
+		placement, err := resolveClubPlacement(h.DB, input.ClubID, input.ClubName, "", "")
+		if err != nil {
+			api_errors.Abort(c, err)
+			return
+		}
+		match.ClubID = placement.clubID()
+		match.ClubName = placement.Club.Name
+	}
+	if err := h.DB.Create(&match).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to log match.", err))
+		return
+	}
+	c.JSON(http.StatusCreated, match)
+}
+
+// GetMatchesGinHandler lists a player's logged matches, most recent first, or
+// the upcoming ones soonest first.
+func (h *DBHandler) GetMatchesGinHandler(c *gin.Context) {
+	var query MatchesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	if _, err := requirePublicProfile(h.DB, query.ProfileID); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	db := h.DB.Where("profile_id = ?", query.ProfileID)
+	if query.Upcoming {
+		db = db.Where("kickoff_at >= ?", time.Now()).Order("kickoff_at")
+	} else {
+		db = db.Order("kickoff_at DESC")
+	}
+	var matches []Match
+	if err := db.Find(&matches).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve matches.", err))
+		return
+	}
+	c.JSON(http.StatusOK, matches)
+}
+
+func (h *DBHandler) DeleteMatchGinHandler(c *gin.Context) {
+	matchID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+	var match Match
+	err = h.DB.First(&match, matchID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "Match not found."))
+		return
+	}
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve match.", err))
+		return
+	}
+	if _, err := requireOwnProfile(c, h.DB, match.ProfileID); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if err := h.DB.Delete(&match).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to delete match.", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
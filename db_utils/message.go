@@ -0,0 +1,681 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Reasons a user can be reported for.
+const (
+	ReportSpam          = "spam"
+	ReportHarassment    = "harassment"
+	ReportInappropriate = "inappropriate"
+	ReportOther         = "other"
+)
+
+// Report statuses. Reports stay open until an admin has dealt with them.
+const (
+	ReportOpen     = "open"
+	ReportReviewed = "reviewed"
+)
+
+// publicUserColumns are the user columns other members may see. E-mail
+// addresses are never shared through messaging.
+var publicUserColumns = []string{"id", "username", "role", "is_verified"}
+
+func selectPublicUser(db *gorm.DB) *gorm.DB {
+	return db.Select(publicUserColumns)
+}
+
+// Conversation is a private message thread between its participants.
+type Conversation struct {
+	gorm.Model
+
+	Subject       string                    `gorm:"size:200" json:"subject"`
+	StartedByID   uint                      `gorm:"not null" json:"started_by_id"`
+	LastMessageAt time.Time                 `gorm:"index" json:"last_message_at"`
+	Participants  []ConversationParticipant `json:"participants,omitempty"`
+	LastMessage   *Message                  `gorm:"-" json:"last_message,omitempty"`
+	UnreadCount   int64                     `gorm:"-" json:"unread_count"`
+}
+
+// ConversationParticipant links a user to a conversation. LastReadAt is the
+// read receipt: every message sent up to then has been seen.
+type ConversationParticipant struct {
+	gorm.Model
+
+	ConversationID uint       `gorm:"not null;uniqueIndex:idx_conversation_user" json:"conversation_id"`
+	UserID         uint       `gorm:"not null;uniqueIndex:idx_conversation_user;index" json:"user_id"`
+	User           *User      `json:"user,omitempty"`
+	LastReadAt     *time.Time `json:"last_read_at"`
+}
+
+type Message struct {
+	gorm.Model
+
+	ConversationID uint   `gorm:"not null;index" json:"conversation_id"`
+	SenderID       uint   `gorm:"not null" json:"sender_id"`
+	Body           string `gorm:"type:text;not null" json:"body"`
+	ReadBy         []uint `gorm:"-" json:"read_by"`
+}
+
+// UserBlock stops Blocked from messaging Blocker, and the other way round.
+type UserBlock struct {
+	gorm.Model
+
+	BlockerID uint  `gorm:"not null;uniqueIndex:idx_user_block" json:"blocker_id"`
+	BlockedID uint  `gorm:"not null;uniqueIndex:idx_user_block;index" json:"blocked_id"`
+	Blocked   *User `gorm:"foreignKey:BlockedID" json:"blocked,omitempty"`
+}
+
+// UserReport flags a user, optionally pointing at the offending message, for
+// an admin to review.
+type UserReport struct {
+	gorm.Model
+
+	ReporterID     uint   `gorm:"not null" json:"reporter_id"`
+	ReportedUserID uint   `gorm:"not null;index" json:"reported_user_id"`
+	MessageID      *uint  `json:"message_id"`
+	Reason         string `gorm:"size:20;not null" json:"reason"`
+	Details        string `gorm:"size:2000" json:"details"`
+	Status         string `gorm:"size:10;not null;default:'open';index" json:"status"`
+}
+
+type StartConversation struct {
+	RecipientID uint   `json:"recipient_id" binding:"required"`
+	Subject     string `json:"subject" binding:"max=200"`
+	Body        string `json:"body" binding:"required,max=5000"`
+}
+
+type SendMessage struct {
+	Body string `json:"body" binding:"required,max=5000"`
+}
+
+type MessagesQuery struct {
+	BeforeID uint `form:"before_id" json:"before_id,omitempty"`
+	Limit    int  `form:"limit" json:"limit,omitempty" binding:"omitempty,gte=1,lte=100"`
+}
+
+type ConversationsResponse struct {
+	UnreadCount   int64          `json:"unread_count"`
+	Conversations []Conversation `json:"conversations"`
+}
+
+type ReportUser struct {
+	Reason    string `json:"reason" binding:"required,oneof=spam harassment inappropriate other"`
+	Details   string `json:"details" binding:"max=2000"`
+	MessageID *uint  `json:"message_id"`
+}
+
+type ReportsQuery struct {
+	Status string `form:"status" json:"status,omitempty" binding:"omitempty,oneof=open reviewed"`
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func IsBlocked(db *gorm.DB, a, b uint) (bool, error) {
+	var count int64
+	err := db.Model(&UserBlock{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", a, b, b, a).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// conversationMembers returns who takes part in a conversation between sender
+// and recipient. Minors only talk through their guardian, who is added to the
+// conversation and without whom they cannot be contacted at all.
+func conversationMembers(db *gorm.DB, senderID, recipientID uint) ([]uint, error) {
+	blocked, err := IsBlocked(db, senderID, recipientID)
+	if err != nil {
+		return nil, api_errors.Internal("Could not check blocked users.", err)
+	}
+	if blocked {
+		return nil, api_errors.Forbidden(api_errors.CodeUserBlocked, "You cannot message this user.")
+	}
+
+	members := []uint{senderID, recipientID}
+	for _, userID := range []uint{senderID, recipientID} {
+		minor, guardianID, err := GuardianOf(db, userID)
+		if err != nil {
+			return nil, api_errors.Internal("Could not check guardian consent.", err)
+		}
+		if !minor {
+			continue
+		}
+		if guardianID == 0 && userID == senderID {
+			return nil, api_errors.Forbidden(api_errors.CodeMinorContact, "Players under 18 need a guardian's consent before messaging other members.")
+		}
+		if guardianID == 0 {
+			return nil, api_errors.Forbidden(api_errors.CodeMinorContact, "Players under 18 can only be contacted through their guardian.")
+		}
+		if !slices.Contains(members, guardianID) {
+			members = append(members, guardianID)
+		}
+	}
+	return members, nil
+}
+
+// checkParticipants returns the API error that stops sender from writing to
+// the other participants, or nil when they may: nobody has blocked the
+// sender, and every minor's guardian follows the conversation.
+func checkParticipants(db *gorm.DB, senderID uint, participants []ConversationParticipant) error {
+	ids := make([]uint, 0, len(participants))
+	for _, p := range participants {
+		ids = append(ids, p.UserID)
+	}
+	for _, p := range participants {
+		if p.UserID == senderID {
+			continue
+		}
+		blocked, err := IsBlocked(db, senderID, p.UserID)
+		if err != nil {
+			return api_errors.Internal("Could not check blocked users.", err)
+		}
+		if blocked {
+			return api_errors.Forbidden(api_errors.CodeUserBlocked, "You cannot message this user.")
+		}
+	}
+	for _, userID := range ids {
+		minor, guardianID, err := GuardianOf(db, userID)
+		if err != nil {
+			return api_errors.Internal("Could not check guardian consent.", err)
+		}
+		if minor && (guardianID == 0 || !slices.Contains(ids, guardianID)) {
+			return api_errors.Forbidden(api_errors.CodeMinorContact, "Players under 18 can only be contacted through their guardian.")
+		}
+	}
+	return nil
+}
+
+// requireConversation loads the conversation in the :id parameter. Users who
+// do not take part in it get a 404.
+func requireConversation(c *gin.Context, db *gorm.DB) (*Conversation, error) {
+	conversationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, api_errors.InvalidID("id")
+	}
+	var conversation Conversation
+	err = db.Preload("Participants.User", selectPublicUser).
+		Where("EXISTS (SELECT 1 FROM conversation_participants p WHERE p.conversation_id = conversations.id AND p.user_id = ? AND p.deleted_at IS NULL)", c.GetUint("userID")).
+		First(&conversation, conversationID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, api_errors.NotFound(api_errors.CodeConversationNotFound, "Conversation not found.")
+	}
+	if err != nil {
+		return nil, api_errors.Internal("Could not retrieve conversation.", err)
+	}
+	return &conversation, nil
+}
+
+// findConversation returns the conversation between exactly members, or nil
+// if they have not talked yet.
+func findConversation(db *gorm.DB, members []uint) (*Conversation, error) {
+	var conversation Conversation
+	err := db.Where("id IN (?)", db.Model(&ConversationParticipant{}).Select("conversation_id").
+		Group("conversation_id").
+		Having("COUNT(*) = ? AND COUNT(*) FILTER (WHERE user_id IN ?) = ?", len(members), members, len(members))).
+		First(&conversation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// unreadCounts returns the number of messages from others that userID has not
+// read, per conversation.
+func unreadCounts(db *gorm.DB, userID uint, conversationIDs []uint) (map[uint]int64, error) {
+	var rows []struct {
+		ConversationID uint
+		Count          int64
+	}
+	query := db.Table("messages m").
+		Select("m.conversation_id, COUNT(*) AS count").
+		Joins("JOIN conversation_participants p ON p.conversation_id = m.conversation_id AND p.user_id = ? AND p.deleted_at IS NULL", userID).
+		Where("m.deleted_at IS NULL AND m.sender_id <> ? AND (p.last_read_at IS NULL OR m.created_at > p.last_read_at)", userID)
+	if conversationIDs != nil {
+		query = query.Where("m.conversation_id IN ?", conversationIDs)
+	}
+	if err := query.Group("m.conversation_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ConversationID] = row.Count
+	}
+	return counts, nil
+}
+
+// fillReadBy sets the read receipts of messages from the participants' last
+// read times. Senders are not listed as readers of their own messages.
+func fillReadBy(messages []Message, participants []ConversationParticipant) {
+	for i := range messages {
+		messages[i].ReadBy = []uint{}
+		for _, p := range participants {
+			if p.UserID != messages[i].SenderID && p.LastReadAt != nil && !messages[i].CreatedAt.After(*p.LastReadAt) {
+				messages[i].ReadBy = append(messages[i].ReadBy, p.UserID)
+			}
+		}
+	}
+}
+
+// PostMessage adds a message from senderID to conversation and e-mails the
+// other participants who had nothing unread there yet, so a burst of messages
+// sends one e-mail.
+func PostMessage(db *gorm.DB, conversation *Conversation, senderID uint, body string) (*Message, error) {
+	message := Message{ConversationID: conversation.ID, SenderID: senderID, Body: body}
+
+	var notify []uint
+	for _, p := range conversation.Participants {
+		if p.UserID == senderID {
+			continue
+		}
+		counts, err := unreadCounts(db, p.UserID, []uint{conversation.ID})
+		if err != nil {
+			return nil, err
+		}
+		if counts[conversation.ID] == 0 {
+			notify = append(notify, p.UserID)
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&message).Error; err != nil {
+			return err
+		}
+		conversation.LastMessageAt = message.CreatedAt
+		if err := tx.Model(conversation).Update("last_message_at", message.CreatedAt).Error; err != nil {
+			return err
+		}
+		// Sending a message implies having read the thread up to it.
+		return tx.Model(&ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id = ?", conversation.ID, senderID).
+			Update("last_read_at", message.CreatedAt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sender := GetUserByID(db, senderID)
+	if sender == nil {
+		return &message, nil
+	}
+	for _, userID := range notify {
+		notification := Notification{
+			Type:  NotificationNewMessage,
+			Title: fmt.Sprintf("New message from %s", sender.Username),
+			Body:  fmt.Sprintf("%s sent you a message on ballerbio. Sign in to read and reply: /conversations/%d", sender.Username, conversation.ID),
+			Data:  map[string]any{"conversation_id": conversation.ID},
+		}
+		if err := Notify(db, userID, notification, false, true); err != nil {
+			log.Printf("Could not notify user %d of message %d: %v", userID, message.ID, err)
+		}
+	}
+	message.ReadBy = []uint{}
+	return &message, nil
+}
+
+// StartConversationGinHandler sends a first message to a user. If the two
+// already have a conversation the message is added to it. Guardians of minors
+// on either side are added to new conversations.
+func (h *DBHandler) StartConversationGinHandler(c *gin.Context) {
+	var input StartConversation
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	userID := c.GetUint("userID")
+	if input.RecipientID == userID {
+		api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("recipient_id", "not_self", "")))
+		return
+	}
+	recipient := GetUserByID(h.DB, input.RecipientID)
+	if recipient == nil || recipient.ID == 0 {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeUserNotFound, "User not found."))
+		return
+	}
+	members, err := conversationMembers(h.DB, userID, recipient.ID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	conversation, err := findConversation(h.DB, members)
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not look up conversations.", err))
+		return
+	}
+	status := http.StatusOK
+	if conversation == nil {
+		status = http.StatusCreated
+		conversation = &Conversation{Subject: input.Subject, StartedByID: userID, LastMessageAt: time.Now()}
+		for _, member := range members {
+			conversation.Participants = append(conversation.Participants, ConversationParticipant{UserID: member})
+		}
+		if err := h.DB.Create(conversation).Error; err != nil {
+			api_errors.Abort(c, api_errors.Internal("Failed to start conversation.", err))
+			return
+		}
+	}
+	if err := h.DB.Preload("User", selectPublicUser).Where("conversation_id = ?", conversation.ID).
+		Find(&conversation.Participants).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve participants.", err))
+		return
+	}
+
+	message, err := PostMessage(h.DB, conversation, userID, input.Body)
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to send message.", err))
+		return
+	}
+	conversation.LastMessage = message
+	c.JSON(status, conversation)
+}
+
+// GetConversationsGinHandler lists the user's conversations, most recently
+// active first, with their unread counters.
+func (h *DBHandler) GetConversationsGinHandler(c *gin.Context) {
+	userID := c.GetUint("userID")
+	var response ConversationsResponse
+	err := h.DB.Preload("Participants.User", selectPublicUser).
+		Where("id IN (?)", h.DB.Model(&ConversationParticipant{}).Select("conversation_id").Where("user_id = ?", userID)).
+		Order("last_message_at DESC").
+		Find(&response.Conversations).Error
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve conversations.", err))
+		return
+	}
+
+	counts, err := unreadCounts(h.DB, userID, nil)
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not count unread messages.", err))
+		return
+	}
+	for i := range response.Conversations {
+		conversation := &response.Conversations[i]
+		conversation.UnreadCount = counts[conversation.ID]
+		response.UnreadCount += conversation.UnreadCount
+
+		var last Message
+		if err := h.DB.Where("conversation_id = ?", conversation.ID).Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+			api_errors.Abort(c, api_errors.Internal("Could not retrieve messages.", err))
+			return
+		}
+		if last.ID != 0 {
+			fillReadBy([]Message{last}, conversation.Participants)
+			conversation.LastMessage = &last
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *DBHandler) GetConversationGinHandler(c *gin.Context) {
+	conversation, err := requireConversation(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	counts, err := unreadCounts(h.DB, c.GetUint("userID"), []uint{conversation.ID})
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not count unread messages.", err))
+		return
+	}
+	conversation.UnreadCount = counts[conversation.ID]
+	c.JSON(http.StatusOK, conversation)
+}
+
+// GetMessagesGinHandler pages through a conversation, newest first. Pass the
+// smallest ID received as before_id to fetch older messages.
+func (h *DBHandler) GetMessagesGinHandler(c *gin.Context) {
+	var query MessagesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	conversation, err := requireConversation(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = 50
+	}
+
+	db := h.DB.Where("conversation_id = ?", conversation.ID)
+	if query.BeforeID > 0 {
+		db = db.Where("id < ?", query.BeforeID)
+	}
+	var messages []Message
+	if err := db.Order("id DESC").Limit(query.Limit).Find(&messages).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve messages.", err))
+		return
+	}
+	fillReadBy(messages, conversation.Participants)
+	c.JSON(http.StatusOK, messages)
+}
+
+func (h *DBHandler) SendMessageGinHandler(c *gin.Context) {
+	var input SendMessage
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	conversation, err := requireConversation(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	userID := c.GetUint("userID")
+	if err := checkParticipants(h.DB, userID, conversation.Participants); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	message, err := PostMessage(h.DB, conversation, userID, input.Body)
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to send message.", err))
+		return
+	}
+	c.JSON(http.StatusCreated, message)
+}
+
+// MarkConversationReadGinHandler records that the user has read every message
+// in the conversation so far.
+func (h *DBHandler) MarkConversationReadGinHandler(c *gin.Context) {
+	conversation, err := requireConversation(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	now := time.Now()
+	if err := h.DB.Model(&ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ?", conversation.ID, c.GetUint("userID")).
+		Update("last_read_at", now).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to mark conversation as read.", err))
+		return
+	}
+	for i := range conversation.Participants {
+		if conversation.Participants[i].UserID == c.GetUint("userID") {
+			conversation.Participants[i].LastReadAt = &now
+		}
+	}
+	c.JSON(http.StatusOK, conversation)
+}
+
+func (h *DBHandler) BlockUserGinHandler(c *gin.Context) {
+	blockedID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+	userID := c.GetUint("userID")
+	if uint(blockedID) == userID {
+		api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("id", "not_self", "")))
+		return
+	}
+	blocked := GetUserByID(h.DB, uint(blockedID))
+	if blocked == nil || blocked.ID == 0 {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeUserNotFound, "User not found."))
+		return
+	}
+
+	block := UserBlock{BlockerID: userID, BlockedID: blocked.ID}
+	err = h.DB.Where("blocker_id = ? AND blocked_id = ?", userID, blocked.ID).FirstOrCreate(&block).Error
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to block user.", err))
+		return
+	}
+	c.JSON(http.StatusCreated, block)
+}
+
+func (h *DBHandler) UnblockUserGinHandler(c *gin.Context) {
+	blockedID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+	result := h.DB.Unscoped().Where("blocker_id = ? AND blocked_id = ?", c.GetUint("userID"), blockedID).Delete(&UserBlock{})
+	if result.Error != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to unblock user.", result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "You have not blocked this user."))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *DBHandler) GetBlockedUsersGinHandler(c *gin.Context) {
+	var blocks []UserBlock
+	if err := h.DB.Preload("Blocked", selectPublicUser).Where("blocker_id = ?", c.GetUint("userID")).
+		Order("created_at DESC").Find(&blocks).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve blocked users.", err))
+		return
+	}
+	c.JSON(http.StatusOK, blocks)
+}
+
+// ReportUserGinHandler files a report about a user. A reported message must
+// have been sent by that user in a conversation the reporter takes part in.
+func (h *DBHandler) ReportUserGinHandler(c *gin.Context) {
+	var input ReportUser
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	reportedID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+	userID := c.GetUint("userID")
+	if uint(reportedID) == userID {
+		api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("id", "not_self", "")))
+		return
+	}
+	reported := GetUserByID(h.DB, uint(reportedID))
+	if reported == nil || reported.ID == 0 {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeUserNotFound, "User not found."))
+		return
+	}
+	if input.MessageID != nil {
+		var count int64
+		err := h.DB.Model(&Message{}).
+			Where("id = ? AND sender_id = ?", *input.MessageID, reported.ID).
+			Where("conversation_id IN (?)", h.DB.Model(&ConversationParticipant{}).Select("conversation_id").Where("user_id = ?", userID)).
+			Count(&count).Error
+		if err != nil {
+			api_errors.Abort(c, api_errors.Internal("Could not check the reported message.", err))
+			return
+		}
+		if count == 0 {
+			api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "Message not found."))
+			return
+		}
+	}
+
+	report := UserReport{
+		ReporterID:     userID,
+		ReportedUserID: reported.ID,
+		MessageID:      input.MessageID,
+		Reason:         input.Reason,
+		Details:        input.Details,
+		Status:         ReportOpen,
+	}
+	if err := h.DB.Create(&report).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to file report.", err))
+		return
+	}
+	c.JSON(http.StatusCreated, report)
+}
+
+// GetReportsGinHandler lists user reports for admins, oldest first.
+func (h *DBHandler) GetReportsGinHandler(c *gin.Context) {
+	var query ReportsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	if _, err := requireRole(c, h.DB, RoleAdmin); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	db := h.DB
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	var reports []UserReport
+	if err := db.Order("created_at").Find(&reports).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve reports.", err))
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+func (h *DBHandler) ResolveReportGinHandler(c *gin.Context) {
+	reportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+	if _, err := requireRole(c, h.DB, RoleAdmin); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	var report UserReport
+	err = h.DB.First(&report, reportID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "Report not found."))
+		return
+	}
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve report.", err))
+		return
+	}
+	report.Status = ReportReviewed
+	if err := h.DB.Model(&report).Update("status", ReportReviewed).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to update report.", err))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
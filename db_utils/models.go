@@ -1,12 +1,15 @@
 package db_utils
 
 import (
+	"ballerbio/api_errors"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/lib/pq"
 	"gorm.io/gorm"
 )
@@ -16,21 +19,33 @@ type DBHandler struct {
 	DB *gorm.DB
 }
 
+// Skill is a catalogue skill listed on a profile. Name and Level predate the
+// catalogue and are kept in sync with Code and SelfRating for old clients.
 type Skill struct {
 	gorm.Model
-	Name      string  `json:"skill_name"`
-	Level     string  `json:"level"`
-	ProfileID uint    `gorm:"not null" json:"profile_id"`
-	Profile   Profile `json:"profile"`
+	Code             string     `gorm:"size:30;index" json:"skill"`
+	Name             string     `json:"skill_name"`
+	Level            string     `json:"level"`
+	SelfRating       *int       `json:"self_rating"`
+	CoachRating      *int       `json:"coach_rating"`
+	CoachRatedByID   *uint      `json:"coach_rated_by_id"`
+	CoachRatedAt     *time.Time `json:"coach_rated_at"`
+	EndorsementCount int        `gorm:"not null;default:0" json:"endorsement_count"`
+	ProfileID        uint       `gorm:"not null" json:"profile_id"`
+	Profile          Profile    `json:"profile"`
 }
 
+// AddSkill accepts a catalogue code in Skill or, from older clients, a
+// free-text Name; likewise a SelfRating or a textual Level.
 type AddSkill struct {
 	gorm.Model
 
-	Name      string  `json:"skill_name" binding:"required"`
-	Level     string  `json:"level" binding:"required"`
-	ProfileID uint    `json:"profile_id" binding:"required"`
-	Profile   Profile `json:"profile" binding:"required"`
+	Skill      string  `json:"skill" binding:"omitempty,skill"`
+	Name       string  `json:"skill_name" binding:"omitempty,skill"`
+	SelfRating *int    `json:"self_rating" binding:"omitempty,gte=1,lte=10"`
+	Level      string  `json:"level" binding:"omitempty,skill_level"`
+	ProfileID  uint    `json:"profile_id" binding:"required"`
+	Profile    Profile `json:"profile" binding:"required"`
 }
 
 type Achievement struct {
@@ -47,7 +62,7 @@ type AddAchievement struct {
 	gorm.Model	
 	Title        string     `gorm:"size:100" json:"title" binding:"required"`
 	Description  string     `json:"description" binding:"required"`
-	DateAchieved *time.Time `json:"date_achieved" binding:"required"`
+	DateAchieved *time.Time `json:"date_achieved" binding:"required,notfuture"`
 	ProfileID    uint       `gorm:"not null" json:"profile_id" binding:"required"`
 	Profile      Profile    `json:"profile" binding:"required"`
 }
@@ -56,19 +71,28 @@ type Injury struct {
 	gorm.Model
 
 	InjuryType  string     `gorm:"size:100" json:"injury_type"`
+	BodyArea    string     `gorm:"size:20;index" json:"body_area"`
+	Side        string     `gorm:"size:5" json:"side"`
 	Description string     `json:"description"`
 	StartDate   *time.Time `json:"start_date"`
-	EndDate     *time.Time `json:"end_date"`
-	ProfileID   uint       `gorm:"not null" json:"profile_id"`
-	Profile     Profile    `json:"profile"`
+	// EndDate is when the player is, or is expected to be, fit again.
+	EndDate   *time.Time `json:"end_date"`
+	ProfileID uint       `gorm:"not null" json:"profile_id"`
+	Profile   Profile    `json:"profile"`
+
+	DaysOut        int   `gorm:"-" json:"days_out"`
+	IsRecurrence   bool  `gorm:"-" json:"is_recurrence"`
+	RecurrenceOfID *uint `gorm:"-" json:"recurrence_of_id,omitempty"`
 }
 
 type AddInjury struct {
 	gorm.Model
 
 	InjuryType  string     `gorm:"size:100" json:"injury_type" binding:"required"`
+	BodyArea    string     `json:"body_area" binding:"omitempty,body_area"`
+	Side        string     `json:"side" binding:"omitempty,oneof=left right both"`
 	Description string     `json:"description" binding:"required"`
-	StartDate   *time.Time `json:"start_date" binding:"required"`
+	StartDate   *time.Time `json:"start_date" binding:"required,notfuture"`
 	EndDate     *time.Time `json:"end_date"`
 	ProfileID   uint       `gorm:"not null" json:"profile_id" binding:"required"`
 	Profile     Profile    `json:"profile" binding:"required"`
@@ -98,10 +122,16 @@ const (
 	ContractTypeTrial     = "Trial"
 )
 
+// ContractTypes lists every accepted ClubProfile.ContractType value.
+var ContractTypes = []string{ContractTypePermanent, ContractTypeLoan, ContractTypeTrial}
+
 type ClubProfile struct {
 	gorm.Model
 
 	ProfileID       *uint      `json:"profile_id"`
+	ClubID          *uint      `gorm:"index" json:"club_id"`
+	Club            *Club      `json:"club,omitempty"`
+	LeagueID        *uint      `json:"league_id"`
 	ClubName        string     `gorm:"size:100" json:"club_name"`
 	ClubLeague      string     `gorm:"size:100" json:"club_league"`
 	ClubCountry     string     `gorm:"size:100" json:"club_country"`
@@ -112,6 +142,11 @@ type ClubProfile struct {
 	ClubGoals       *int32     `json:"club_goals"`
 	ClubAssists     *int32     `json:"club_assists"`
 	ContractType    string     `gorm:"size:20;default:'Permanent'" json:"contract_type"`
+	ContractStart   *time.Time `json:"contract_start"`
+	ContractEnd     *time.Time `gorm:"index" json:"contract_end"`
+	// ExpiryNotifiedAt is set once the contract expiry alert has gone out.
+	ExpiryNotifiedAt *time.Time `json:"-"`
+	Verification
 	Profile         Profile    `json:"profile"`
 }
 
@@ -119,16 +154,19 @@ type AddClubProfile struct {
 	gorm.Model
 
 	ProfileID       *uint      `json:"profile_id" binding:"required"`
-	ClubName        string     `gorm:"size:100" json:"club_name" binding:"required"`
-	ClubLeague      string     `gorm:"size:100" json:"club_league" binding:"required"`	
-	ClubCountry     string     `gorm:"size:100" json:"club_country" binding:"required"`
-	StartYear       *time.Time `json:"start_year" binding:"required"`
+	ClubID          *uint      `json:"club_id"`
+	ClubName        string     `gorm:"size:100" json:"club_name" binding:"required_without=ClubID"`
+	ClubLeague      string     `gorm:"size:100" json:"club_league" binding:"required_without=ClubID"`	
+	ClubCountry     string     `gorm:"size:100" json:"club_country" binding:"required_without=ClubID"`
+	StartYear       *time.Time `json:"start_year" binding:"required,notfuture"`
 	EndYear         *time.Time `json:"end_year"`
 	IsPresentClub   bool       `gorm:"default:false" json:"is_present_club"`
-	ClubAppearances *int32     `json:"club_appearances"`
-	ClubGoals       *int32     `json:"club_goals"`
-	ClubAssists     *int32     `json:"club_assists"`
-	ContractType    string     `gorm:"size:20;default:'Permanent'" json:"contract_type" binding:"required"`
+	ClubAppearances *int32     `json:"club_appearances" binding:"omitempty,gte=0,lte=2000"`
+	ClubGoals       *int32     `json:"club_goals" binding:"omitempty,gte=0"`
+	ClubAssists     *int32     `json:"club_assists" binding:"omitempty,gte=0"`
+	ContractType    string     `gorm:"size:20;default:'Permanent'" json:"contract_type" binding:"required,contract_type"`
+	ContractStart   *time.Time `json:"contract_start"`
+	ContractEnd     *time.Time `json:"contract_end"`
 	Profile         Profile    `json:"profile" binding:"required"`
 }
 
@@ -137,6 +175,9 @@ type SeasonStat struct {
 
 	ProfileID     uint    `gorm:"not null" json:"profile_id"`
 	Season        string  `gorm:"size:20" json:"season"`
+	ClubID        *uint   `gorm:"index" json:"club_id"`
+	Club          *Club   `json:"club,omitempty"`
+	LeagueID      *uint   `json:"league_id"`
 	ClubName      string  `gorm:"size:100" json:"club_name"`
 	LeagueName    string  `gorm:"size:100" json:"league_name"`
 	Appearances   *int32  `json:"appearances"`
@@ -145,6 +186,7 @@ type SeasonStat struct {
 	MinutesPlayed *int32  `json:"minutes_played"`
 	YellowCards   *int32  `json:"yellow_cards"`
 	RedCards      *int32  `json:"red_cards"`
+	Verification
 	Profile       Profile `json:"profile"`
 }
 
@@ -152,21 +194,51 @@ type AddSeasonStat struct {
 	gorm.Model
 
 	ProfileID     uint    `gorm:"not null" json:"profile_id" binding:"required"`
-	Season        string  `gorm:"size:20" json:"season" binding:"required"`
-	ClubName      string  `gorm:"size:100" json:"club_name" binding:"required"`	
-	LeagueName    string  `gorm:"size:100" json:"league_name" binding:"required"`
-	Appearances   *int32  `json:"appearances"`
-	Goals         *int32  `json:"goals"`
-	Assists       *int32  `json:"assists"`
-	MinutesPlayed *int32  `json:"minutes_played"`
-	YellowCards   *int32  `json:"yellow_cards"`
-	RedCards      *int32  `json:"red_cards"`
+	Season        string  `gorm:"size:20" json:"season" binding:"required,season"`
+	ClubID        *uint   `json:"club_id"`
+	ClubName      string  `gorm:"size:100" json:"club_name" binding:"required_without=ClubID"`	
+	LeagueName    string  `gorm:"size:100" json:"league_name" binding:"required_without=ClubID"`
+	Appearances   *int32  `json:"appearances" binding:"omitempty,gte=0,lte=100"`
+	Goals         *int32  `json:"goals" binding:"omitempty,gte=0"`
+	Assists       *int32  `json:"assists" binding:"omitempty,gte=0"`
+	MinutesPlayed *int32  `json:"minutes_played" binding:"omitempty,gte=0"`
+	YellowCards   *int32  `json:"yellow_cards" binding:"omitempty,gte=0"`
+	RedCards      *int32  `json:"red_cards" binding:"omitempty,gte=0"`
 	Profile       Profile `json:"profile" binding:"required"`
 }
 
-func GetPlayerSkills(db *gorm.DB, profileID uint) ([]Skill, error) {
+// requireProfile loads the profile a child resource is being attached to and
+// reports a missing or unknown profile_id as an API error.
+func requireProfile(db *gorm.DB, profileID uint) (Profile, error) {
+	if profileID == 0 {
+		return Profile{}, api_errors.Invalid(api_errors.FieldErrorFor("profile_id", "required", ""))
+	}
+	profile, err := GetProfileByID(db, profileID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return profile, api_errors.NotFound(api_errors.CodeProfileNotFound, "Profile with given ID does not exist.")
+	}
+	if err != nil {
+		return profile, api_errors.Internal("Could not verify profile.", err)
+	}
+	return profile, nil
+}
+
+func derefUint(v *uint) uint {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// GetPlayerSkills returns the skills of profile, which the caller has checked
+// is public. Each carries the profile with a minor's details redacted.
+func GetPlayerSkills(db *gorm.DB, profile Profile) ([]Skill, error) {
 	var skills []Skill
-	result := db.Preload("Profile").Find(&skills, profileID)
+	result := db.Where("profile_id = ?", profile.ID).Find(&skills)
+	profile.RedactMinor()
+	for i := range skills {
+		skills[i].Profile = profile
+	}
 	return skills, result.Error
 }
 
@@ -174,35 +246,28 @@ func (h *DBHandler) GetPlayerSkillsGinHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	profileID, err := strconv.Atoi(idParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid profile ID format.",
-		})
+		api_errors.Abort(c, api_errors.InvalidID("id"))
 		return
 	}
 
-	profile, err := GetPlayerSkills(h.DB, uint(profileID))
-
+	profile, err := requirePublicProfile(h.DB, uint(profileID))
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "Profile not found.",
-			})
-			return
-		}
+		api_errors.Abort(c, err)
+		return
+	}
 
-		log.Printf("Error fetching profile ID %d: %v", profileID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Could not retrieve profile.",
-		})
+	skills, err := GetPlayerSkills(h.DB, profile)
+
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve skills.", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, profile)
+	c.JSON(http.StatusOK, skills)
 }
 
 func AddSkillToProfile(db *gorm.DB, skill *Skill) error {
-	result := db.Create(skill)
-	return result.Error
+	return SetProfileSkill(db, skill)
 }
 
 func (h *DBHandler) AddSkillToProfileGinHandler(c *gin.Context) {
@@ -212,40 +277,43 @@ func (h *DBHandler) AddSkillToProfileGinHandler(c *gin.Context) {
 	if err := c.ShouldBindJSON(&input); err != nil {
 		// Log the error for debugging
 		log.Printf("JSON binding error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		api_errors.Abort(c, api_errors.Validation(err))
 		return
 	}
 
-	if input.ProfileID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ProfileID is required and must be non-zero."})
+	check_if_profile_exists, err := requireOwnProfile(c, h.DB, input.ProfileID)
+	if err != nil {
+		api_errors.Abort(c, err)
 		return
 	}
 
-	check_if_profile_exists, err := GetProfileByID(h.DB, input.ProfileID)
-	if err != nil {
-		log.Printf("Error checking profile existence: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify profile."})
-		return
+	code, _ := NormalizeSkill(input.Skill)
+	if input.Skill == "" {
+		code, _ = NormalizeSkill(input.Name)
 	}
-	if check_if_profile_exists.ID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Profile with given ID does not exist."})
-		return
+	info, _ := LookupSkill(code)
+	rating := input.SelfRating
+	if rating == nil {
+		parsed, _ := ParseSkillLevel(input.Level)
+		rating = &parsed
 	}
 
 	skill := Skill{
-		Name:      input.Name,
-		Level:     input.Level,
-		ProfileID: input.ProfileID,
-		Profile:   check_if_profile_exists,
+		Code:       code,
+		Name:       info.Name,
+		Level:      SkillLevelLabel(*rating),
+		SelfRating: rating,
+		ProfileID:  input.ProfileID,
+		Profile:    check_if_profile_exists,
 	}
 
 	if err := AddSkillToProfile(h.DB, &skill); err != nil {
-		log.Printf("Database create error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create profile."})
+		api_errors.Abort(c, api_errors.Internal("Failed to add skill.", err))
 		return
 	}
+	notifyProfileWebhooks(h.DB, WebhookProfileUpdated, skill.ProfileID)
 
-	// 4. Respond with the newly created profile (including the new ID)
+	// 4. Respond with the newly created skill (including the new ID)
 	c.JSON(http.StatusCreated, skill)
 }
 
@@ -261,21 +329,12 @@ func (h *DBHandler) AddAchievementToProfileGinHandler(c *gin.Context) {
 	if err := c.ShouldBindJSON(&input); err != nil {
 		// Log the error for debugging
 		log.Printf("JSON binding error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	if input.ProfileID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ProfileID is required and must be non-zero."})
+		api_errors.Abort(c, api_errors.Validation(err))
 		return
 	}
-	check_if_profile_exists, err := GetProfileByID(h.DB, input.ProfileID)
+	check_if_profile_exists, err := requireOwnProfile(c, h.DB, input.ProfileID)
 	if err != nil {
-		log.Printf("Error checking profile existence: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify profile."})
-		return
-	}	
-	if check_if_profile_exists.ID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Profile with given ID does not exist."})
+		api_errors.Abort(c, err)
 		return
 	}
 	achievement := Achievement{
@@ -286,8 +345,7 @@ func (h *DBHandler) AddAchievementToProfileGinHandler(c *gin.Context) {
 		Profile:      check_if_profile_exists,
 	}	
 	if err := AddAchievementToProfile(h.DB, &achievement); err != nil {
-		log.Printf("Database create error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create achievement."})
+		api_errors.Abort(c, api_errors.Internal("Failed to create achievement.", err))
 		return
 	}
 	// 4. Respond with the newly created achievement (including the new ID)
@@ -305,25 +363,23 @@ func (h *DBHandler) AddInjuryToProfileGinHandler(c *gin.Context) {
 	if err := c.ShouldBindJSON(&input); err != nil {
 		// Log the error for debugging
 		log.Printf("JSON binding error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	if input.ProfileID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ProfileID is required and must be non-zero."})
+		api_errors.Abort(c, api_errors.Validation(err))
 		return
 	}
-	check_if_profile_exists, err := GetProfileByID(h.DB, input.ProfileID)
+	check_if_profile_exists, err := requireOwnProfile(c, h.DB, input.ProfileID)
 	if err != nil {
-		log.Printf("Error checking profile existence: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify profile."})
+		api_errors.Abort(c, err)
 		return
 	}
-	if check_if_profile_exists.ID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Profile with given ID does not exist."})
-		return
+	// Injuries recorded without a body area are classified from their type.
+	bodyArea, ok := NormalizeBodyArea(input.BodyArea)
+	if !ok {
+		bodyArea = InferBodyArea(input.InjuryType)
 	}
 	injury := Injury{
 		InjuryType:  input.InjuryType,
+		BodyArea:    bodyArea,
+		Side:        input.Side,
 		Description: input.Description,
 		StartDate:   input.StartDate,
 		EndDate:     input.EndDate,
@@ -331,10 +387,10 @@ func (h *DBHandler) AddInjuryToProfileGinHandler(c *gin.Context) {
 		Profile:     check_if_profile_exists,
 	}
 	if err := AddInjuryToProfile(h.DB, &injury); err != nil {
-		log.Printf("Database create error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create injury."})
+		api_errors.Abort(c, api_errors.Internal("Failed to create injury.", err))
 		return
 	}
+	notifyProfileWebhooks(h.DB, WebhookProfileUpdated, injury.ProfileID)
 	// 4. Respond with the newly created injury (including the new ID)
 	c.JSON(http.StatusCreated, injury)
 }
@@ -350,21 +406,12 @@ func (h *DBHandler) AddSocialLinkToProfileGinHandler(c *gin.Context) {
 	if err := c.ShouldBindJSON(&input); err != nil {
 		// Log the error for debugging
 		log.Printf("JSON binding error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		api_errors.Abort(c, api_errors.Validation(err))
 		return
 	}	
-	if input.ProfileID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ProfileID is required and must be non-zero."})
-		return
-	}
-	check_if_profile_exists, err := GetProfileByID(h.DB, input.ProfileID)
+	check_if_profile_exists, err := requireOwnProfile(c, h.DB, input.ProfileID)
 	if err != nil {
-		log.Printf("Error checking profile existence: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify profile."})
-		return
-	}
-	if check_if_profile_exists.ID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Profile with given ID does not exist."})
+		api_errors.Abort(c, err)
 		return
 	}
 	socialLink := SocialLink{
@@ -374,14 +421,52 @@ func (h *DBHandler) AddSocialLinkToProfileGinHandler(c *gin.Context) {
 		Profile:   check_if_profile_exists,
 	}
 	if err := AddSocialLinkToProfile(h.DB, &socialLink); err != nil {
-		log.Printf("Database create error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create social link."})
+		api_errors.Abort(c, api_errors.Internal("Failed to create social link.", err))
 		return
 	}
+	notifyProfileWebhooks(h.DB, WebhookProfileUpdated, socialLink.ProfileID)
 	// 4. Respond with the newly created social link (including the new ID)
 	c.JSON(http.StatusCreated, socialLink)
 }
 
+// HasPresentClub reports whether the profile already has a club spell marked
+// as its present club. A player can only have one.
+func HasPresentClub(db *gorm.DB, profileID uint) (bool, error) {
+	var count int64
+	result := db.Model(&ClubProfile{}).Where("profile_id = ? AND is_present_club = ?", profileID, true).Count(&count)
+	return count > 0, result.Error
+}
+
+// presentClubIndex backs HasPresentClub, so concurrent requests cannot both
+// add a present club.
+const presentClubIndex = "idx_club_profiles_present"
+
+// EnforceSinglePresentClub creates presentClubIndex. Profiles that already
+// have several present clubs keep the one that started last.
+func EnforceSinglePresentClub(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&ClubProfile{}, presentClubIndex) {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE club_profiles SET is_present_club = false
+			WHERE is_present_club AND deleted_at IS NULL AND id NOT IN (
+				SELECT DISTINCT ON (profile_id) id FROM club_profiles
+				WHERE is_present_club AND deleted_at IS NULL
+				ORDER BY profile_id, start_year DESC NULLS LAST, id DESC)`).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec("CREATE UNIQUE INDEX " + presentClubIndex + " ON club_profiles (profile_id) WHERE is_present_club AND deleted_at IS NULL").Error
+	})
+}
+
+// isUniqueViolation reports whether err is Postgres refusing a duplicate in
+// the unique index named index.
+func isUniqueViolation(err error, index string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == index
+}
+
 func AddClubProfileToProfile(db *gorm.DB, clubProfile *ClubProfile) error {
 	result := db.Create(clubProfile)
 	return result.Error
@@ -393,28 +478,38 @@ func (h *DBHandler) AddClubProfileToProfileGinHandler(c *gin.Context) {
 	if err := c.ShouldBindJSON(&input); err != nil {
 		// Log the error for debugging
 		log.Printf("JSON binding error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		api_errors.Abort(c, api_errors.Validation(err))
 		return
 	}
-	if input.ProfileID == nil || *input.ProfileID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ProfileID is required and must be non-zero."})
-		return
-	}
-	check_if_profile_exists, err := GetProfileByID(h.DB, *input.ProfileID)
+	check_if_profile_exists, err := requireOwnProfile(c, h.DB, derefUint(input.ProfileID))
 	if err != nil {
-		log.Printf("Error checking profile existence: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify profile."})
+		api_errors.Abort(c, err)
 		return
 	}
-	if check_if_profile_exists.ID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Profile with given ID does not exist."})
+	if input.IsPresentClub {
+		hasPresentClub, err := HasPresentClub(h.DB, check_if_profile_exists.ID)
+		if err != nil {
+			api_errors.Abort(c, api_errors.Internal("Could not verify present club.", err))
+			return
+		}
+		if hasPresentClub {
+			api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("is_present_club", "unique_present_club", "")))
+			return
+		}
+	}
+	placement, err := resolveClubPlacement(h.DB, input.ClubID, input.ClubName, input.ClubLeague, input.ClubCountry)
+	if err != nil {
+		api_errors.Abort(c, err)
 		return
 	}
 	clubProfile := ClubProfile{
 		ProfileID:       input.ProfileID,
-		ClubName:        input.ClubName,	
-		ClubLeague:      input.ClubLeague,
-		ClubCountry:     input.ClubCountry,
+		ClubID:          placement.clubID(),
+		Club:            placement.Club,
+		LeagueID:        placement.leagueID(),
+		ClubName:        placement.Club.Name,	
+		ClubLeague:      placement.leagueName(input.ClubLeague),
+		ClubCountry:     placement.countryName(input.ClubCountry),
 		StartYear:       input.StartYear,
 		EndYear:         input.EndYear,
 		IsPresentClub:   input.IsPresentClub,
@@ -422,11 +517,17 @@ func (h *DBHandler) AddClubProfileToProfileGinHandler(c *gin.Context) {
 		ClubGoals:       input.ClubGoals,
 		ClubAssists:     input.ClubAssists,
 		ContractType:    input.ContractType,
+		ContractStart:   input.ContractStart,
+		ContractEnd:     input.ContractEnd,
 		Profile:         check_if_profile_exists,
 	}
 	if err := AddClubProfileToProfile(h.DB, &clubProfile); err != nil {
-		log.Printf("Database create error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create club profile."})
+		// Another request added a present club since the check above.
+		if isUniqueViolation(err, presentClubIndex) {
+			api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("is_present_club", "unique_present_club", "")))
+			return
+		}
+		api_errors.Abort(c, api_errors.Internal("Failed to create club profile.", err))
 		return
 	}	
 	// 4. Respond with the newly created club profile (including the new ID)
@@ -444,28 +545,27 @@ func (h *DBHandler) AddSeasonStatToProfileGinHandler(c *gin.Context) {
 	if err := c.ShouldBindJSON(&input); err != nil {
 		// Log the error for debugging
 		log.Printf("JSON binding error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	if input.ProfileID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ProfileID is required and must be non-zero."})
+		api_errors.Abort(c, api_errors.Validation(err))
 		return
 	}
-	check_if_profile_exists, err := GetProfileByID(h.DB, input.ProfileID)
+	check_if_profile_exists, err := requireOwnProfile(c, h.DB, input.ProfileID)
 	if err != nil {
-		log.Printf("Error checking profile existence: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify profile."})
+		api_errors.Abort(c, err)
 		return
 	}
-	if check_if_profile_exists.ID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Profile with given ID does not exist."})
+	placement, err := resolveClubPlacement(h.DB, input.ClubID, input.ClubName, input.LeagueName, "")
+	if err != nil {
+		api_errors.Abort(c, err)
 		return
 	}
 	seasonStat := SeasonStat{
 		ProfileID:     input.ProfileID,
 		Season:        input.Season,	
-		ClubName:      input.ClubName,
-		LeagueName:    input.LeagueName,
+		ClubID:        placement.clubID(),
+		Club:          placement.Club,
+		LeagueID:      placement.leagueID(),
+		ClubName:      placement.Club.Name,
+		LeagueName:    placement.leagueName(input.LeagueName),
 		Appearances:   input.Appearances,
 		Goals:         input.Goals,
 		Assists:       input.Assists,
@@ -475,8 +575,7 @@ func (h *DBHandler) AddSeasonStatToProfileGinHandler(c *gin.Context) {
 		Profile:       check_if_profile_exists,
 	}
 	if err := AddSeasonStatToProfile(h.DB, &seasonStat); err != nil {
-		log.Printf("Database create error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create season stat."})
+		api_errors.Abort(c, api_errors.Internal("Failed to create season stat.", err))
 		return
 	}
 	// 4. Respond with the newly created season stat (including the new ID)
@@ -0,0 +1,287 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/utils"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Nationality is a country a player holds citizenship of, or is eligible to
+// represent. CountryCode is an ISO 3166-1 alpha-2 code or a home nation code
+// such as GB-ENG. Exactly one nationality of a profile is primary.
+type Nationality struct {
+	gorm.Model
+
+	ProfileID       uint   `gorm:"not null;uniqueIndex:idx_profile_nationality" json:"profile_id"`
+	CountryCode     string `gorm:"size:6;not null;uniqueIndex:idx_profile_nationality;index" json:"country_code"`
+	Name            string `gorm:"-" json:"name"`
+	IsPrimary       bool   `gorm:"not null;default:false" json:"is_primary"`
+	EligibilityNote string `gorm:"size:500" json:"eligibility_note,omitempty"`
+}
+
+// NationalityInput names a country by ISO code, name, alias or demonym:
+// "IE", "Ireland" and "Irish" are the same nationality.
+type NationalityInput struct {
+	Country         string `json:"country" binding:"required,max=100"`
+	IsPrimary       bool   `json:"is_primary"`
+	EligibilityNote string `json:"eligibility_note" binding:"max=500"`
+}
+
+// UpdateNationalities replaces every nationality of a profile. The first one
+// is primary unless another is marked so.
+type UpdateNationalities struct {
+	ProfileID     uint               `json:"profile_id" binding:"required"`
+	Nationalities []NationalityInput `json:"nationalities" binding:"required,min=1,max=5,unique=Country,dive"`
+}
+
+// AfterFind names the country in English; LocalizeCountries translates it.
+func (n *Nationality) AfterFind(tx *gorm.DB) error {
+	n.Name = utils.CountryName(n.CountryCode, "en")
+	return nil
+}
+
+// AfterCreate mirrors AfterFind so freshly created nationalities render the
+// same way.
+func (n *Nationality) AfterCreate(tx *gorm.DB) error {
+	return n.AfterFind(tx)
+}
+
+// LocalizeCountries names the profile's nationalities in lang (a BCP 47 tag
+// such as "fr"). Nationality becomes the name of the primary one. The profile
+// must not be saved afterwards.
+func (p *Profile) LocalizeCountries(lang string) {
+	if lang == "" {
+		lang = "en"
+	}
+	for i := range p.Nationalities {
+		p.Nationalities[i].Name = utils.CountryName(p.Nationalities[i].CountryCode, lang)
+		if p.Nationalities[i].IsPrimary {
+			p.Nationality = p.Nationalities[i].Name
+		}
+	}
+}
+
+// resolveNationalities maps inputs onto directory countries. Two inputs
+// naming the same country ("Ireland" and "IE") are merged. Unknown countries
+// are reported against field, e.g. "nationalities[1].country".
+func resolveNationalities(db *gorm.DB, inputs []NationalityInput, field string) ([]Nationality, error) {
+	var nationalities []Nationality
+	for i, input := range inputs {
+		country, err := ResolveCountry(db, input.Country)
+		if err != nil {
+			return nil, api_errors.Internal("Could not resolve nationality.", err)
+		}
+		if country == nil {
+			return nil, api_errors.Invalid(api_errors.FieldErrorFor(fmt.Sprintf("%s[%d].country", field, i), "country", ""))
+		}
+		nationalities = appendNationality(nationalities, Nationality{
+			CountryCode:     country.Code,
+			IsPrimary:       input.IsPrimary,
+			EligibilityNote: strings.TrimSpace(input.EligibilityNote),
+		})
+	}
+	markPrimaryNationality(nationalities)
+	return nationalities, nil
+}
+
+// appendNationality adds n to nationalities, merging it into an earlier
+// entry for the same country.
+func appendNationality(nationalities []Nationality, n Nationality) []Nationality {
+	for i := range nationalities {
+		if nationalities[i].CountryCode != n.CountryCode {
+			continue
+		}
+		nationalities[i].IsPrimary = nationalities[i].IsPrimary || n.IsPrimary
+		if nationalities[i].EligibilityNote == "" {
+			nationalities[i].EligibilityNote = n.EligibilityNote
+		}
+		return nationalities
+	}
+	return append(nationalities, n)
+}
+
+func markPrimaryNationality(nationalities []Nationality) {
+	for _, n := range nationalities {
+		if n.IsPrimary {
+			return
+		}
+	}
+	if len(nationalities) > 0 {
+		nationalities[0].IsPrimary = true
+	}
+}
+
+// primaryNationalityFirst orders preloaded nationalities.
+func primaryNationalityFirst(db *gorm.DB) *gorm.DB {
+	return db.Order("is_primary DESC, id")
+}
+
+// primaryNationalityName is the English name of the primary nationality.
+func primaryNationalityName(nationalities []Nationality) string {
+	for _, n := range nationalities {
+		if n.IsPrimary {
+			return utils.CountryName(n.CountryCode, "en")
+		}
+	}
+	return ""
+}
+
+// nationalitySeparators split free-text nationalities such as "Irish /
+// English" or "French, Algerian". " and " is only tried once the whole text
+// failed to resolve, so "Trinidad and Tobago" stays whole.
+var (
+	nationalitySeparators = regexp.MustCompile(`\s*[/,;&+|]\s*`)
+	nationalityAnd        = regexp.MustCompile(`(?i)\s+and\s+`)
+)
+
+// ParseNationalities resolves free text listing one or more nationalities,
+// the first being primary. It also returns the parts it could not resolve.
+func ParseNationalities(db *gorm.DB, text string) ([]Nationality, []string, error) {
+	var nationalities []Nationality
+	var unresolved []string
+	for _, part := range nationalitySeparators.Split(strings.TrimSpace(text), -1) {
+		if part == "" {
+			continue
+		}
+		country, err := ResolveCountry(db, part)
+		if err != nil {
+			return nil, nil, err
+		}
+		if country != nil {
+			nationalities = appendNationality(nationalities, Nationality{CountryCode: country.Code})
+			continue
+		}
+		for _, sub := range nationalityAnd.Split(part, -1) {
+			country, err := ResolveCountry(db, sub)
+			if err != nil {
+				return nil, nil, err
+			}
+			if country == nil {
+				unresolved = append(unresolved, sub)
+				continue
+			}
+			nationalities = appendNationality(nationalities, Nationality{CountryCode: country.Code})
+		}
+	}
+	markPrimaryNationality(nationalities)
+	return nationalities, unresolved, nil
+}
+
+// SetNationalities replaces the nationalities of a profile and keeps
+// Profile.Nationality on the English name of the primary one.
+func SetNationalities(db *gorm.DB, profileID uint, nationalities []Nationality) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Removed rows are deleted for good: the profile may add the
+		// country back later, and the unique index covers deleted rows.
+		if err := tx.Unscoped().Where("profile_id = ?", profileID).Delete(&Nationality{}).Error; err != nil {
+			return err
+		}
+		for i := range nationalities {
+			nationalities[i].ID = 0
+			nationalities[i].ProfileID = profileID
+		}
+		if len(nationalities) > 0 {
+			if err := tx.Create(&nationalities).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&Profile{}).Where("id = ?", profileID).Update("nationality", primaryNationalityName(nationalities)).Error
+	})
+}
+
+// ResolveNationality turns the nationality filter into a country code, so
+// "Irish" and "Ireland" find the same players.
+func (f *ProfileFilter) ResolveNationality(db *gorm.DB) error {
+	if strings.TrimSpace(f.Nationality) == "" {
+		return nil
+	}
+	country, err := ResolveCountry(db, f.Nationality)
+	if err != nil {
+		return api_errors.Internal("Could not resolve nationality.", err)
+	}
+	if country == nil {
+		return api_errors.Invalid(api_errors.FieldErrorFor("nationality", "country", ""))
+	}
+	f.Nationality = country.Code
+	return nil
+}
+
+// Resolve looks up the places and countries the filter names. It runs once,
+// before the filter is applied or saved.
+func (f *ProfileFilter) Resolve(db *gorm.DB) error {
+	if err := f.ResolveNear(); err != nil {
+		return err
+	}
+	return f.ResolveNationality(db)
+}
+
+// withNationality keeps the profiles holding any nationality matching code.
+// GB also matches the home nations, GB-ENG only England.
+func withNationality(code string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(
+			"EXISTS (SELECT 1 FROM nationalities n WHERE n.profile_id = profiles.id AND n.deleted_at IS NULL AND (n.country_code = ? OR n.country_code LIKE ?))",
+			code, code+"-%",
+		)
+	}
+}
+
+func (h *DBHandler) UpdateNationalitiesGinHandler(c *gin.Context) {
+	var input UpdateNationalities
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	profile, err := requireOwnProfile(c, h.DB, input.ProfileID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	nationalities, err := resolveNationalities(h.DB, input.Nationalities, "nationalities")
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if err := SetNationalities(h.DB, profile.ID, nationalities); err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to update nationalities.", err))
+		return
+	}
+	notifyProfileWebhooks(h.DB, WebhookProfileUpdated, profile.ID)
+	c.JSON(http.StatusOK, nationalities)
+}
+
+// MigrateNationalities turns the free-text nationality of profiles that
+// predate structured nationalities into Nationality rows. Values that cannot
+// be resolved are logged and left for manual review.
+func MigrateNationalities(db *gorm.DB) error {
+	var profiles []Profile
+	return db.Select("id", "nationality").
+		Where("nationality <> '' AND NOT EXISTS (SELECT 1 FROM nationalities n WHERE n.profile_id = profiles.id)").
+		FindInBatches(&profiles, 200, func(tx *gorm.DB, batch int) error {
+			for _, profile := range profiles {
+				nationalities, unresolved, err := ParseNationalities(db, profile.Nationality)
+				if err != nil {
+					return err
+				}
+				if len(unresolved) > 0 || len(nationalities) == 0 {
+					log.Printf("Nationality migration: profile %d has unmapped nationality %q", profile.ID, profile.Nationality)
+					continue
+				}
+				if err := SetNationalities(db, profile.ID, nationalities); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
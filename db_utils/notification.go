@@ -0,0 +1,127 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/utils"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Notification types.
+const (
+	NotificationSavedSearchMatch = "saved_search_match"
+	NotificationNewMessage       = "new_message"
+	NotificationTrialUpdate      = "trial_update"
+	NotificationContractExpiry   = "contract_expiry"
+)
+
+// Notification is an in-app alert. Data carries type-specific identifiers
+// (profile IDs, search ID, ...) for clients to link to.
+type Notification struct {
+	gorm.Model
+
+	UserID uint           `gorm:"not null;index" json:"user_id"`
+	Type   string         `gorm:"size:40;not null" json:"type"`
+	Title  string         `gorm:"size:200;not null" json:"title"`
+	Body   string         `gorm:"type:text" json:"body"`
+	Data   map[string]any `gorm:"serializer:json;type:jsonb" json:"data,omitempty"`
+	ReadAt *time.Time     `json:"read_at"`
+}
+
+type NotificationsQuery struct {
+	Unread bool `form:"unread" json:"unread,omitempty"`
+}
+
+type NotificationsResponse struct {
+	UnreadCount   int64          `json:"unread_count"`
+	Notifications []Notification `json:"notifications"`
+}
+
+// Notify stores notification for userID when inApp is set and e-mails it when
+// email is set. E-mail failures are logged, not returned.
+func Notify(db *gorm.DB, userID uint, notification Notification, inApp, email bool) error {
+	notification.UserID = userID
+	if inApp {
+		if err := db.Create(&notification).Error; err != nil {
+			return err
+		}
+	}
+	if email {
+		user := GetUserByID(db, userID)
+		if user == nil || user.ID == 0 {
+			return nil
+		}
+		go func() {
+			if err := utils.SendEmail(user.Email, notification.Title, notification.Body); err != nil {
+				log.Printf("Notification e-mail to user %d failed: %v", userID, err)
+			}
+		}()
+	}
+	return nil
+}
+
+func (h *DBHandler) GetNotificationsGinHandler(c *gin.Context) {
+	var query NotificationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	userID := c.GetUint("userID")
+
+	var response NotificationsResponse
+	if err := h.DB.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&response.UnreadCount).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not count notifications.", err))
+		return
+	}
+	db := h.DB.Where("user_id = ?", userID)
+	if query.Unread {
+		db = db.Where("read_at IS NULL")
+	}
+	if err := db.Order("created_at DESC").Limit(100).Find(&response.Notifications).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve notifications.", err))
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *DBHandler) MarkNotificationReadGinHandler(c *gin.Context) {
+	notificationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+	var notification Notification
+	err = h.DB.Where("user_id = ?", c.GetUint("userID")).First(&notification, notificationID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "Notification not found."))
+		return
+	}
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve notification.", err))
+		return
+	}
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := h.DB.Model(&notification).Update("read_at", now).Error; err != nil {
+			api_errors.Abort(c, api_errors.Internal("Failed to update notification.", err))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, notification)
+}
+
+func (h *DBHandler) MarkAllNotificationsReadGinHandler(c *gin.Context) {
+	if err := h.DB.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", c.GetUint("userID")).
+		Update("read_at", time.Now()).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to update notifications.", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,262 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Canonical position codes. Profile.Position and ProfilePosition.Position only
+// ever hold one of these.
+const (
+	PositionGK  = "GK"
+	PositionRB  = "RB"
+	PositionRWB = "RWB"
+	PositionCB  = "CB"
+	PositionLB  = "LB"
+	PositionLWB = "LWB"
+	PositionDM  = "DM"
+	PositionCM  = "CM"
+	PositionRM  = "RM"
+	PositionLM  = "LM"
+	PositionAM  = "AM"
+	PositionRW  = "RW"
+	PositionLW  = "LW"
+	PositionSS  = "SS"
+	PositionCF  = "CF"
+	PositionST  = "ST"
+)
+
+const (
+	PositionGroupGoalkeeper = "goalkeeper"
+	PositionGroupDefender   = "defender"
+	PositionGroupMidfielder = "midfielder"
+	PositionGroupForward    = "forward"
+)
+
+// SupportedLanguages are the locales position labels are translated into.
+var SupportedLanguages = []string{"en", "fr", "es", "pt", "de"}
+
+type PositionInfo struct {
+	Code    string            `json:"code"`
+	Group   string            `json:"group"`
+	Label   string            `json:"label"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Aliases []string          `json:"aliases,omitempty"`
+}
+
+// PositionCatalogue is the canonical position taxonomy. Labels are keyed by
+// SupportedLanguages; aliases are extra spellings seen in free-text input.
+var PositionCatalogue = []PositionInfo{
+	{Code: PositionGK, Group: PositionGroupGoalkeeper, Labels: map[string]string{"en": "Goalkeeper", "fr": "Gardien de but", "es": "Portero", "pt": "Goleiro", "de": "Torwart"}, Aliases: []string{"keeper", "goalie", "gk", "g"}},
+	{Code: PositionRB, Group: PositionGroupDefender, Labels: map[string]string{"en": "Right Back", "fr": "Arrière droit", "es": "Lateral derecho", "pt": "Lateral direito", "de": "Rechter Verteidiger"}, Aliases: []string{"right fullback", "right full back", "rd"}},
+	{Code: PositionRWB, Group: PositionGroupDefender, Labels: map[string]string{"en": "Right Wing-Back", "fr": "Piston droit", "es": "Carrilero derecho", "pt": "Ala direito", "de": "Rechter Flügelverteidiger"}, Aliases: []string{"right wingback"}},
+	{Code: PositionCB, Group: PositionGroupDefender, Labels: map[string]string{"en": "Centre-Back", "fr": "Défenseur central", "es": "Defensa central", "pt": "Zagueiro", "de": "Innenverteidiger"}, Aliases: []string{"center back", "centre half", "center half", "central defender", "defender", "stopper", "sweeper", "libero", "cd", "dc"}},
+	{Code: PositionLB, Group: PositionGroupDefender, Labels: map[string]string{"en": "Left Back", "fr": "Arrière gauche", "es": "Lateral izquierdo", "pt": "Lateral esquerdo", "de": "Linker Verteidiger"}, Aliases: []string{"left fullback", "left full back", "ld"}},
+	{Code: PositionLWB, Group: PositionGroupDefender, Labels: map[string]string{"en": "Left Wing-Back", "fr": "Piston gauche", "es": "Carrilero izquierdo", "pt": "Ala esquerdo", "de": "Linker Flügelverteidiger"}, Aliases: []string{"left wingback"}},
+	{Code: PositionDM, Group: PositionGroupMidfielder, Labels: map[string]string{"en": "Defensive Midfielder", "fr": "Milieu défensif", "es": "Mediocentro defensivo", "pt": "Volante", "de": "Defensives Mittelfeld"}, Aliases: []string{"cdm", "holding midfielder", "anchor", "number 6", "dmf"}},
+	{Code: PositionCM, Group: PositionGroupMidfielder, Labels: map[string]string{"en": "Central Midfielder", "fr": "Milieu central", "es": "Centrocampista", "pt": "Meio-campista", "de": "Zentrales Mittelfeld"}, Aliases: []string{"centre midfielder", "center midfielder", "midfielder", "midfield", "box to box", "mc", "cmf", "number 8"}},
+	{Code: PositionRM, Group: PositionGroupMidfielder, Labels: map[string]string{"en": "Right Midfielder", "fr": "Milieu droit", "es": "Interior derecho", "pt": "Meia direita", "de": "Rechtes Mittelfeld"}, Aliases: []string{"right midfield", "mr"}},
+	{Code: PositionLM, Group: PositionGroupMidfielder, Labels: map[string]string{"en": "Left Midfielder", "fr": "Milieu gauche", "es": "Interior izquierdo", "pt": "Meia esquerda", "de": "Linkes Mittelfeld"}, Aliases: []string{"left midfield", "ml"}},
+	{Code: PositionAM, Group: PositionGroupMidfielder, Labels: map[string]string{"en": "Attacking Midfielder", "fr": "Milieu offensif", "es": "Mediapunta", "pt": "Meia atacante", "de": "Offensives Mittelfeld"}, Aliases: []string{"cam", "playmaker", "number 10", "amf", "trequartista"}},
+	{Code: PositionRW, Group: PositionGroupForward, Labels: map[string]string{"en": "Right Winger", "fr": "Ailier droit", "es": "Extremo derecho", "pt": "Ponta direita", "de": "Rechtsaußen"}, Aliases: []string{"right wing", "rwf"}},
+	{Code: PositionLW, Group: PositionGroupForward, Labels: map[string]string{"en": "Left Winger", "fr": "Ailier gauche", "es": "Extremo izquierdo", "pt": "Ponta esquerda", "de": "Linksaußen"}, Aliases: []string{"left wing", "lwf"}},
+	{Code: PositionSS, Group: PositionGroupForward, Labels: map[string]string{"en": "Second Striker", "fr": "Second attaquant", "es": "Segundo delantero", "pt": "Segundo atacante", "de": "Hängende Spitze"}, Aliases: []string{"support striker", "shadow striker"}},
+	{Code: PositionCF, Group: PositionGroupForward, Labels: map[string]string{"en": "Centre-Forward", "fr": "Avant-centre", "es": "Delantero centro", "pt": "Centroavante", "de": "Mittelstürmer"}, Aliases: []string{"center forward", "target man", "number 9", "forward", "attacker"}},
+	{Code: PositionST, Group: PositionGroupForward, Labels: map[string]string{"en": "Striker", "fr": "Attaquant", "es": "Delantero", "pt": "Atacante", "de": "Stürmer"}, Aliases: []string{"main striker", "poacher"}},
+}
+
+var positionIndex = map[string]string{}
+
+func init() {
+	for _, p := range PositionCatalogue {
+		positionIndex[normalizePositionKey(p.Code)] = p.Code
+		for _, label := range p.Labels {
+			positionIndex[normalizePositionKey(label)] = p.Code
+		}
+		for _, alias := range p.Aliases {
+			positionIndex[normalizePositionKey(alias)] = p.Code
+		}
+	}
+}
+
+func normalizePositionKey(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.NewReplacer("-", " ", "_", " ", ".", " ", "'", "").Replace(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// NormalizePosition maps free-text input ("Centre Back", "centre-back", "cb",
+// "defender") onto a canonical position code.
+func NormalizePosition(s string) (string, bool) {
+	code, ok := positionIndex[normalizePositionKey(s)]
+	return code, ok
+}
+
+// LookupPosition returns the catalogue entry for a canonical code.
+func LookupPosition(code string) (PositionInfo, bool) {
+	for _, p := range PositionCatalogue {
+		if p.Code == code {
+			return p, true
+		}
+	}
+	return PositionInfo{}, false
+}
+
+// PositionsInGroup lists the codes belonging to a group such as "defender".
+func PositionsInGroup(group string) []string {
+	var codes []string
+	for _, p := range PositionCatalogue {
+		if p.Group == group {
+			codes = append(codes, p.Code)
+		}
+	}
+	return codes
+}
+
+// ProfilePosition is a position a player can play. Each profile has exactly
+// one primary position, mirrored in Profile.Position.
+type ProfilePosition struct {
+	gorm.Model
+
+	ProfileID   uint   `gorm:"not null;uniqueIndex:idx_profile_position" json:"profile_id"`
+	Position    string `gorm:"size:3;not null;uniqueIndex:idx_profile_position" json:"position"`
+	IsPrimary   bool   `gorm:"default:false" json:"is_primary"`
+	Proficiency int    `gorm:"default:3" json:"proficiency"`
+}
+
+// PositionInput is a secondary position given when creating a profile.
+type PositionInput struct {
+	Position    string `json:"position" binding:"required,position"`
+	Proficiency int    `json:"proficiency" binding:"omitempty,gte=1,lte=5"`
+}
+
+type AddProfilePosition struct {
+	ProfileID   uint   `json:"profile_id" binding:"required"`
+	Position    string `json:"position" binding:"required,position"`
+	Proficiency int    `json:"proficiency" binding:"omitempty,gte=1,lte=5"`
+	IsPrimary   bool   `json:"is_primary"`
+}
+
+const (
+	PrimaryPositionProficiency = 5
+	DefaultPositionProficiency = 3
+)
+
+// SetProfilePosition adds or updates a position on a profile. Making it
+// primary demotes the previous primary and updates Profile.Position.
+func SetProfilePosition(db *gorm.DB, position *ProfilePosition) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if position.IsPrimary {
+			if err := tx.Model(&ProfilePosition{}).
+				Where("profile_id = ? AND position <> ?", position.ProfileID, position.Position).
+				Update("is_primary", false).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&Profile{}).Where("id = ?", position.ProfileID).
+				Update("position", position.Position).Error; err != nil {
+				return err
+			}
+		}
+
+		var existing ProfilePosition
+		err := tx.Where("profile_id = ? AND position = ?", position.ProfileID, position.Position).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(position).Error
+		}
+		if err != nil {
+			return err
+		}
+		position.ID = existing.ID
+		position.CreatedAt = existing.CreatedAt
+		return tx.Model(&existing).Updates(map[string]any{
+			"is_primary":  position.IsPrimary || existing.IsPrimary,
+			"proficiency": position.Proficiency,
+		}).Error
+	})
+}
+
+func (h *DBHandler) AddProfilePositionGinHandler(c *gin.Context) {
+	var input AddProfilePosition
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	profile, err := requireOwnProfile(c, h.DB, input.ProfileID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	code, _ := NormalizePosition(input.Position)
+	proficiency := input.Proficiency
+	if proficiency == 0 {
+		proficiency = DefaultPositionProficiency
+	}
+	position := ProfilePosition{
+		ProfileID:   profile.ID,
+		Position:    code,
+		IsPrimary:   input.IsPrimary || code == profile.Position,
+		Proficiency: proficiency,
+	}
+	if err := SetProfilePosition(h.DB, &position); err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to add position.", err))
+		return
+	}
+	notifyProfileWebhooks(h.DB, WebhookProfileUpdated, profile.ID)
+	c.JSON(http.StatusCreated, position)
+}
+
+// GetPositionsGinHandler lists the position taxonomy with labels in the
+// language requested through ?lang= (English by default).
+func (h *DBHandler) GetPositionsGinHandler(c *gin.Context) {
+	lang := c.DefaultQuery("lang", "en")
+	positions := make([]PositionInfo, 0, len(PositionCatalogue))
+	for _, p := range PositionCatalogue {
+		label, ok := p.Labels[lang]
+		if !ok {
+			label = p.Labels["en"]
+		}
+		positions = append(positions, PositionInfo{Code: p.Code, Group: p.Group, Label: label, Labels: p.Labels, Aliases: p.Aliases})
+	}
+	c.JSON(http.StatusOK, positions)
+}
+
+// MigrateProfilePositions rewrites free-text Profile.Position values onto the
+// canonical codes and gives every profile a primary ProfilePosition. Only
+// profiles without one are visited. Values that cannot be mapped are logged
+// once and flagged with PositionUnmapped for manual review.
+func MigrateProfilePositions(db *gorm.DB) error {
+	var profiles []Profile
+	pending := "NOT position_unmapped AND NOT EXISTS (SELECT 1 FROM profile_positions pp WHERE pp.profile_id = profiles.id AND pp.is_primary AND pp.deleted_at IS NULL)"
+	return db.Select("id", "position").Where(pending).FindInBatches(&profiles, 200, func(tx *gorm.DB, batch int) error {
+		for _, profile := range profiles {
+			code, ok := NormalizePosition(profile.Position)
+			if !ok {
+				log.Printf("Position migration: profile %d has unmapped position %q", profile.ID, profile.Position)
+				if err := db.Model(&Profile{}).Where("id = ?", profile.ID).Update("position_unmapped", true).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if code != profile.Position {
+				if err := db.Model(&Profile{}).Where("id = ?", profile.ID).Update("position", code).Error; err != nil {
+					return err
+				}
+			}
+			primary := ProfilePosition{ProfileID: profile.ID, Position: code, IsPrimary: true, Proficiency: PrimaryPositionProficiency}
+			if err := SetProfilePosition(db, &primary); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
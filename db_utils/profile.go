@@ -1,33 +1,69 @@
 package db_utils
 
 import (
+	"ballerbio/api_errors"
 	"ballerbio/utils"
+	"errors"
 	"log"
 	"net/http"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 )
 
 type Profile struct {
 	gorm.Model
-	FirstName    string        `json:"first_name"`
-	LastName     string        `json:"last_name"`
-	Dob          time.Time     `json:"dob"`
-	Position     string        `json:"position"`
-	Height       float64       `json:"height"`
-	Weight       float64       `json:"weight"`
-	Bio          string        `json:"bio"`
-	Location     string        `json:"location"`
-	Nationality  string        `json:"nationality"`
-	Slug         string        `gorm:"not null" json:"slug"`
-	UserID       uint          `gorm:"unique;not null" json:"user_id"`
-	User         User          `json:"user"`
+	FirstName   string            `json:"first_name"`
+	LastName    string            `json:"last_name"`
+	Dob         time.Time         `json:"dob,omitzero"`
+	Age         int               `gorm:"-" json:"age"`
+	IsMinor     bool              `gorm:"-" json:"is_minor"`
+	Position    string            `json:"position"`
+	Height      float64           `json:"height"`
+	HeightUnit  string            `gorm:"-" json:"height_unit"`
+	Weight      float64           `json:"weight"`
+	WeightUnit  string            `gorm:"-" json:"weight_unit"`
+	Bio         string            `json:"bio"`
+	Location    string            `json:"location"`
+	Nationality string            `json:"nationality"`
+	Slug        string            `gorm:"not null" json:"slug"`
+	UserID      uint              `gorm:"unique;not null" json:"user_id"`
+	User        User              `json:"user"`
+	Positions   []ProfilePosition `json:"positions"`
+
+	// PositionUnmapped marks profiles whose legacy Position
+	// MigrateProfilePositions could not map, pending manual review.
+	PositionUnmapped bool `gorm:"default:false" json:"-"`
+
+	PreferredFoot  string         `gorm:"size:5" json:"preferred_foot"`
+	WeakFootRating *int           `json:"weak_foot_rating"`
+	PlayingStyles  pq.StringArray `gorm:"type:text[]" json:"playing_styles"`
+	PhysicalTests  []PhysicalTest `json:"physical_tests"`
+
+	// TransferStatus is what the player advertises to scouts; see
+	// TransferStatuses.
+	TransferStatus    string     `gorm:"size:25;index" json:"transfer_status"`
+	ContractExpiresAt *time.Time `gorm:"-" json:"contract_expires_at,omitempty"`
+
+	// GeoLocation is the structured form of Location. DistanceKm is only
+	// set by radius searches.
+	GeoLocation
+	DistanceKm *float64 `gorm:"-" json:"distance_km,omitempty"`
+
+	// Nationalities lists the primary nationality first. Nationality is
+	// the name of the primary one.
+	Nationalities []Nationality `json:"nationalities"`
+
 	Skills       []Skill       `json:"skills"`
 	Achievements []Achievement `json:"achievements"`
 	Injuries     []Injury      `json:"injuries"`
+	InjuryStatus string        `gorm:"-" json:"injury_status,omitempty"`
 	SocialLinks  []SocialLink  `json:"social_links"`
 	ClubProfiles []ClubProfile `json:"club_profiles"`
 	SeasonStats  []SeasonStat  `json:"season_stats"`
@@ -38,14 +74,158 @@ type CreateProfileInput struct {
 
 	FirstName   string    `json:"first_name" binding:"required"`
 	LastName    string    `json:"last_name" binding:"required"`
-	Dob         time.Time `json:"dob" binding:"required"`
-	Position    string    `json:"position" binding:"required"`
-	Height      float64   `json:"height" binding:"required"`
-	Weight      float64   `json:"weight" binding:"required"`
+	Dob         time.Time `json:"dob" binding:"required,dob"`
+	Position    string    `json:"position" binding:"required,position"`
+	Height      float64   `json:"height" binding:"required,gt=0"`
+	HeightUnit  string    `json:"height_unit" binding:"omitempty,oneof=cm in"`
+	Weight      float64   `json:"weight" binding:"required,gt=0"`
+	WeightUnit  string    `json:"weight_unit" binding:"omitempty,oneof=kg lb"`
 	Bio         string    `json:"bio" binding:"required"`
 	Location    string    `json:"location" binding:"required"`
-	Nationality string    `json:"nationality" binding:"required"`
-	UserID      uint      `json:"user_id" binding:"required"`
+	Nationality string    `json:"nationality" binding:"required_without=Nationalities,max=100"`
+
+	SecondaryPositions []PositionInput `json:"secondary_positions" binding:"omitempty,max=4,dive"`
+	PreferredFoot      string          `json:"preferred_foot" binding:"omitempty,oneof=left right both"`
+	WeakFootRating     *int            `json:"weak_foot_rating" binding:"omitempty,gte=1,lte=5"`
+	PlayingStyles      []string        `json:"playing_styles" binding:"omitempty,max=5,dive,playing_style"`
+
+	LocationInput
+
+	// Nationalities takes precedence over the free-text Nationality.
+	Nationalities []NationalityInput `json:"nationalities" binding:"omitempty,max=5,unique=Country,dive"`
+}
+
+// ProfileFilter holds the query-string filters accepted by the profile listing.
+// Heights and weights are interpreted in Units, which also selects the unit
+// system of the response. Lang selects the language of country names.
+type ProfileFilter struct {
+	Position       string   `form:"position" json:"position,omitempty" binding:"omitempty,position"`
+	PositionGroup  string   `form:"position_group" json:"position_group,omitempty" binding:"omitempty,oneof=goalkeeper defender midfielder forward"`
+	PreferredFoot  string   `form:"preferred_foot" json:"preferred_foot,omitempty" binding:"omitempty,oneof=left right both"`
+	MinWeakFoot    int      `form:"min_weak_foot" json:"min_weak_foot,omitempty" binding:"omitempty,gte=1,lte=5"`
+	PlayingStyle   string   `form:"playing_style" json:"playing_style,omitempty" binding:"omitempty,playing_style"`
+	MinHeight      *float64 `form:"min_height" json:"min_height,omitempty" binding:"omitempty,gt=0"`
+	MaxHeight      *float64 `form:"max_height" json:"max_height,omitempty" binding:"omitempty,gt=0"`
+	MinWeight      *float64 `form:"min_weight" json:"min_weight,omitempty" binding:"omitempty,gt=0"`
+	MaxWeight      *float64 `form:"max_weight" json:"max_weight,omitempty" binding:"omitempty,gt=0"`
+	MaxSprint30m   *float64 `form:"max_sprint_30m" json:"max_sprint_30m,omitempty" binding:"omitempty,gt=0"`
+	Skill          string   `form:"skill" json:"skill,omitempty" binding:"omitempty,skill"`
+	MinSkillRating int      `form:"min_skill_rating" json:"min_skill_rating,omitempty" binding:"omitempty,gte=1,lte=10"`
+	MinAge         int      `form:"min_age" json:"min_age,omitempty" binding:"omitempty,gte=5,lte=60"`
+	MaxAge         int      `form:"max_age" json:"max_age,omitempty" binding:"omitempty,gte=5,lte=60"`
+	Location       string   `form:"location" json:"location,omitempty" binding:"max=100"`
+	Nationality    string   `form:"nationality" json:"nationality,omitempty" binding:"max=100"`
+	Near           string   `form:"near" json:"near,omitempty" binding:"max=100"`
+	Lat            *float64 `form:"lat" json:"lat,omitempty" binding:"required_with=Lng,omitempty,gte=-90,lte=90"`
+	Lng            *float64 `form:"lng" json:"lng,omitempty" binding:"required_with=Lat,omitempty,gte=-180,lte=180"`
+	RadiusKm       float64  `form:"radius_km" json:"radius_km,omitempty" binding:"omitempty,gt=0,lte=500"`
+	Availability   string   `form:"availability" json:"availability,omitempty" binding:"omitempty,oneof=fit injured returning available"`
+	TransferStatus string   `form:"transfer_status" json:"transfer_status,omitempty" binding:"omitempty,oneof=not_available available_for_transfer free_agent open_to_trials"`
+	ExpiringWithin int      `form:"contract_expires_within" json:"contract_expires_within,omitempty" binding:"omitempty,gte=1,lte=730"`
+	Units          string   `form:"units" json:"units,omitempty" binding:"omitempty,oneof=metric imperial"`
+	Lang           string   `form:"lang" json:"lang,omitempty" binding:"max=35"`
+}
+
+// Apply narrows query down to the public profiles matching the filter.
+// Position filters match primary as well as secondary positions.
+func (f ProfileFilter) Apply(query *gorm.DB) *gorm.DB {
+	query = query.Scopes(PublicProfiles)
+
+	var positions []string
+	if code, ok := NormalizePosition(f.Position); ok {
+		positions = append(positions, code)
+	}
+	if f.PositionGroup != "" {
+		positions = append(positions, PositionsInGroup(f.PositionGroup)...)
+	}
+	if len(positions) > 0 {
+		query = query.Where(
+			"profiles.position IN ? OR EXISTS (SELECT 1 FROM profile_positions pp WHERE pp.profile_id = profiles.id AND pp.deleted_at IS NULL AND pp.position IN ?)",
+			positions, positions,
+		)
+	}
+
+	// "left" also matches two-footed players, and so does "right".
+	switch f.PreferredFoot {
+	case PreferredFootLeft, PreferredFootRight:
+		query = query.Where("profiles.preferred_foot IN ?", []string{f.PreferredFoot, PreferredFootBoth})
+	case PreferredFootBoth:
+		query = query.Where("profiles.preferred_foot = ?", PreferredFootBoth)
+	}
+	if f.MinWeakFoot > 0 {
+		query = query.Where("profiles.weak_foot_rating >= ?", f.MinWeakFoot)
+	}
+	if f.PlayingStyle != "" {
+		query = query.Where("? = ANY(profiles.playing_styles)", f.PlayingStyle)
+	}
+
+	heightUnit, weightUnit := utils.UnitCentimetre, utils.UnitKilogram
+	if f.Units == utils.UnitSystemImperial {
+		heightUnit, weightUnit = utils.UnitInch, utils.UnitPound
+	}
+	if f.MinHeight != nil {
+		query = query.Where("profiles.height >= ?", utils.HeightToCm(*f.MinHeight, heightUnit))
+	}
+	if f.MaxHeight != nil {
+		query = query.Where("profiles.height <= ?", utils.HeightToCm(*f.MaxHeight, heightUnit))
+	}
+	if f.MinWeight != nil {
+		query = query.Where("profiles.weight >= ?", utils.WeightToKg(*f.MinWeight, weightUnit))
+	}
+	if f.MaxWeight != nil {
+		query = query.Where("profiles.weight <= ?", utils.WeightToKg(*f.MaxWeight, weightUnit))
+	}
+	if f.MaxSprint30m != nil {
+		query = query.Where(
+			"EXISTS (SELECT 1 FROM physical_tests pt WHERE pt.profile_id = profiles.id AND pt.deleted_at IS NULL AND pt.test_type = ? AND pt.value <= ?)",
+			PhysicalTestSprint30m, *f.MaxSprint30m,
+		)
+	}
+	// Ages are whole years today: max_age=20 keeps players born less than 21
+	// years ago, i.e. U21s.
+	now := time.Now()
+	if f.MinAge > 0 {
+		query = query.Where("profiles.dob <= ?", now.AddDate(-f.MinAge, 0, 0))
+	}
+	if f.MaxAge > 0 {
+		query = query.Where("profiles.dob > ?", now.AddDate(-f.MaxAge-1, 0, 0))
+	}
+	if location := strings.TrimSpace(f.Location); location != "" {
+		query = query.Where("profiles.location ILIKE ?", "%"+location+"%")
+	}
+	// Nationality is a country code by now, see Resolve. A player matches on
+	// any of their nationalities.
+	if f.Nationality != "" {
+		query = query.Scopes(withNationality(f.Nationality))
+	}
+	// near=Manchester&radius_km=50, or lat and lng instead of near; see
+	// ResolveNear.
+	if f.Lat != nil && f.Lng != nil {
+		radius := f.RadiusKm
+		if radius == 0 {
+			radius = DefaultSearchRadiusKm
+		}
+		query = query.Scopes(withinRadius(*f.Lat, *f.Lng, radius))
+	}
+	if f.Availability != "" {
+		query = query.Scopes(availabilityScope(f.Availability, now))
+	}
+	if f.TransferStatus != "" {
+		query = query.Where("profiles.transfer_status = ?", f.TransferStatus)
+	}
+	// contract_expires_within=90 keeps players whose present contract ends
+	// in the next 90 days.
+	if f.ExpiringWithin > 0 {
+		query = query.Scopes(contractExpiresWithin(f.ExpiringWithin, now))
+	}
+	if code, ok := NormalizeSkill(f.Skill); ok {
+		// Coach assessments take precedence over self ratings.
+		query = query.Where(
+			"EXISTS (SELECT 1 FROM skills s WHERE s.profile_id = profiles.id AND s.deleted_at IS NULL AND s.code = ? AND COALESCE(s.coach_rating, s.self_rating, 0) >= ?)",
+			code, f.MinSkillRating,
+		)
+	}
+	return query
 }
 
 func GetProfileByID(db *gorm.DB, profileID uint) (Profile, error) {
@@ -57,12 +237,15 @@ func GetProfileByID(db *gorm.DB, profileID uint) (Profile, error) {
 	return profile, result.Error
 }
 
-func GetProfiles(db *gorm.DB) ([]Profile, error) {
+func GetProfiles(db *gorm.DB, filter ProfileFilter) ([]Profile, error) {
 	var profiles []Profile
 
 	// Preload ALL relationships
-	result := db.
-		Preload("User").
+	result := filter.Apply(db).
+		Preload("User", selectPublicUser).
+		Preload("Positions").
+		Preload("Nationalities", primaryNationalityFirst).
+		Preload("PhysicalTests").
 		Preload("Skills").
 		Preload("Achievements").
 		Preload("Injuries").
@@ -71,22 +254,46 @@ func GetProfiles(db *gorm.DB) ([]Profile, error) {
 		Preload("SeasonStats").
 		Find(&profiles)
 
+	now := time.Now()
+	for i := range profiles {
+		profiles[i].AnalyzeInjuries(now)
+		profiles[i].ContractExpiresAt = profiles[i].CurrentContractEnd()
+	}
+	// Radius searches list the nearest players first.
+	if filter.Lat != nil && filter.Lng != nil {
+		for i := range profiles {
+			profiles[i].SetDistanceFrom(*filter.Lat, *filter.Lng)
+		}
+		sort.SliceStable(profiles, func(i, j int) bool { return *profiles[i].DistanceKm < *profiles[j].DistanceKm })
+	}
 	return profiles, result.Error
 }
 
 func (h *DBHandler) GetProfilesGinHandler(c *gin.Context) {
+	var filter ProfileFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	if err := filter.Resolve(h.DB); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
 	// 1. Call the database function (no package prefix needed for GetProfiles)
-	profiles, err := GetProfiles(h.DB)
+	profiles, err := GetProfiles(h.DB, filter)
 
 	if err != nil {
-		log.Printf("Error fetching profiles: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Could not retrieve profiles.",
-		})
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve profiles.", err))
 		return
 	}
 
 	// 2. Respond with the fetched data
+	for i := range profiles {
+		profiles[i].ConvertUnits(filter.Units)
+		profiles[i].LocalizeCountries(filter.Lang)
+		profiles[i].RedactMinor()
+	}
 	c.JSON(http.StatusOK, profiles)
 }
 
@@ -95,15 +302,21 @@ func GetProfile(db *gorm.DB, profileID uint, slug string) (Profile, error) {
 
 	// Preload ALL relationships
 	result := db.
-		Preload("User").
+		Preload("User", selectPublicUser).
+		Preload("Positions").
+		Preload("Nationalities", primaryNationalityFirst).
+		Preload("PhysicalTests").
 		Preload("Skills").
 		Preload("Achievements").
 		Preload("Injuries").
 		Preload("SocialLinks").
 		Preload("ClubProfiles").
 		Preload("SeasonStats").
+		Scopes(PublicProfiles).
 		Where("id = ? AND slug = ?", profileID, slug).
 		First(&profile)
+	profile.AnalyzeInjuries(time.Now())
+	profile.ContractExpiresAt = profile.CurrentContractEnd()
 
 	// utils.SendEmail(profile.User.Email, "Profile Viewed", "Your profile was just viewed.")
 
@@ -117,9 +330,13 @@ func (h *DBHandler) GetProfileByIDGinHandler(c *gin.Context) {
 
 	profileID, err := strconv.Atoi(idParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid profile ID format.",
-		})
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+
+	var units UnitsQuery
+	if err := c.ShouldBindQuery(&units); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
 		return
 	}
 
@@ -127,21 +344,19 @@ func (h *DBHandler) GetProfileByIDGinHandler(c *gin.Context) {
 	profile, err := GetProfile(h.DB, uint(profileID), string(slugParam))
 
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "Profile not found.",
-			})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			api_errors.Abort(c, api_errors.NotFound(api_errors.CodeProfileNotFound, "Profile not found."))
 			return
 		}
 
-		log.Printf("Error fetching profile ID %d: %v", profileID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Could not retrieve profile.",
-		})
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve profile.", err))
 		return
 	}
 
 	// 3. Respond with the fetched data
+	profile.ConvertUnits(units.Units)
+	profile.LocalizeCountries(units.Lang)
+	profile.RedactMinor()
 	c.JSON(http.StatusOK, profile)
 }
 
@@ -157,49 +372,92 @@ func (h *DBHandler) CreateProfileGinHandler(c *gin.Context) {
 	if err := c.ShouldBindJSON(&input); err != nil {
 		// Log the error for debugging
 		log.Printf("JSON binding error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		api_errors.Abort(c, api_errors.Validation(err))
 		return
 	}
 
-	if input.UserID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "UserID is required and must be non-zero."})
+	// Profiles always belong to the caller, so nobody can create one (or a
+	// minor's) under another account.
+	userID := c.GetUint("userID")
+	check_if_user_exists := GetUserByID(h.DB, userID)
+	if check_if_user_exists == nil || check_if_user_exists.ID == 0 {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeUserNotFound, "User does not exist."))
 		return
 	}
 
-	check_if_user_exists := GetUserByID(h.DB, input.UserID)
-	// if err != nil {
-	// 	log.Printf("Error checking user existence: %v", err)
-	// 	c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify user."})
-	// 	return
-	// }
+	create_slug := utils.ProfileSlugify(input.FirstName, input.LastName)
 
-	if check_if_user_exists.ID == 0 || check_if_user_exists == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User with given UserID does not exist."})
+	location, locationText, err := ResolveLocation(input.Location, input.LocationInput)
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not geocode the location.", err))
 		return
 	}
 
-	create_slug := utils.ProfileSlugify(input.FirstName, input.LastName)
+	var nationalities []Nationality
+	if len(input.Nationalities) > 0 {
+		nationalities, err = resolveNationalities(h.DB, input.Nationalities, "nationalities")
+		if err != nil {
+			api_errors.Abort(c, err)
+			return
+		}
+	} else {
+		var unresolved []string
+		nationalities, unresolved, err = ParseNationalities(h.DB, input.Nationality)
+		if err != nil {
+			api_errors.Abort(c, api_errors.Internal("Could not resolve nationality.", err))
+			return
+		}
+		if len(unresolved) > 0 || len(nationalities) == 0 {
+			api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("nationality", "country", "")))
+			return
+		}
+	}
+
+	primaryPosition, _ := NormalizePosition(input.Position)
+	positions := []ProfilePosition{{Position: primaryPosition, IsPrimary: true, Proficiency: PrimaryPositionProficiency}}
+	for _, secondary := range input.SecondaryPositions {
+		// Aliases of a position already listed ("centre back" after "CB")
+		// would hit the unique index.
+		code, _ := NormalizePosition(secondary.Position)
+		if slices.ContainsFunc(positions, func(p ProfilePosition) bool { return p.Position == code }) {
+			continue
+		}
+		proficiency := secondary.Proficiency
+		if proficiency == 0 {
+			proficiency = DefaultPositionProficiency
+		}
+		positions = append(positions, ProfilePosition{Position: code, Proficiency: proficiency})
+	}
 
 	profile := Profile{
 		FirstName:   input.FirstName,
 		LastName:    input.LastName,
 		Dob:         input.Dob,
-		Position:    input.Position,
-		Height:      input.Height,
-		Weight:      input.Weight,
+		Position:    primaryPosition,
+		Height:      utils.Round(utils.HeightToCm(input.Height, input.HeightUnit), 1),
+		Weight:      utils.Round(utils.WeightToKg(input.Weight, input.WeightUnit), 1),
 		Bio:         input.Bio,
-		Location:    input.Location,
-		Nationality: input.Nationality,
+		Location:    locationText,
+		Nationality: primaryNationalityName(nationalities),
 		Slug:        create_slug,
-		UserID:      input.UserID,
+		UserID:      userID,
+		Positions:   positions,
+
+		PreferredFoot:  input.PreferredFoot,
+		WeakFootRating: input.WeakFootRating,
+		PlayingStyles:  pq.StringArray(input.PlayingStyles),
+
+		GeoLocation: location,
+
+		Nationalities: nationalities,
 	}
 
 	// 3. Call the database function
 	if err := CreateProfile(h.DB, &profile); err != nil {
-		log.Printf("Database create error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create profile."})
+		api_errors.Abort(c, api_errors.Internal("Failed to create profile.", err))
 		return
 	}
+	notifyProfileWebhooks(h.DB, WebhookProfileCreated, profile.ID)
 
 	// 4. Respond with the newly created profile (including the new ID)
 	c.JSON(http.StatusCreated, profile)
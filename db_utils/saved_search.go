@@ -0,0 +1,345 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// How often a saved search is evaluated. Instant searches are evaluated on
+// every run of the alert job.
+const (
+	SearchFrequencyInstant = "instant"
+	SearchFrequencyDaily   = "daily"
+	SearchFrequencyWeekly  = "weekly"
+)
+
+// Where alerts for a saved search are delivered.
+const (
+	AlertChannelInApp = "in_app"
+	AlertChannelEmail = "email"
+	AlertChannelBoth  = "both"
+)
+
+var searchFrequencyIntervals = map[string]time.Duration{
+	SearchFrequencyInstant: 0,
+	SearchFrequencyDaily:   24 * time.Hour,
+	SearchFrequencyWeekly:  7 * 24 * time.Hour,
+}
+
+// maxAlertMatches caps how many profiles a single alert lists.
+const maxAlertMatches = 20
+
+// SavedSearch is a profile listing filter a user wants to be alerted about.
+// Filter is stored as JSON exactly as it is accepted by GET /profiles.
+type SavedSearch struct {
+	gorm.Model
+
+	UserID     uint          `gorm:"not null;index" json:"user_id"`
+	Name       string        `gorm:"size:100;not null" json:"name"`
+	Filter     ProfileFilter `gorm:"serializer:json;type:jsonb" json:"filter"`
+	Frequency  string        `gorm:"size:10;not null;default:'daily'" json:"frequency"`
+	Channel    string        `gorm:"size:10;not null;default:'in_app'" json:"channel"`
+	Enabled    bool          `gorm:"not null;default:true" json:"enabled"`
+	LastRunAt  *time.Time    `json:"last_run_at"`
+	MatchCount int           `gorm:"not null;default:0" json:"match_count"`
+}
+
+// SavedSearchMatch records that a profile has been reported for a search, so
+// it is only alerted once.
+type SavedSearchMatch struct {
+	gorm.Model
+
+	SavedSearchID uint `gorm:"not null;uniqueIndex:idx_saved_search_match" json:"saved_search_id"`
+	ProfileID     uint `gorm:"not null;uniqueIndex:idx_saved_search_match" json:"profile_id"`
+}
+
+type SaveSearch struct {
+	Name      string        `json:"name" binding:"required,max=100"`
+	Filter    ProfileFilter `json:"filter"`
+	Frequency string        `json:"frequency" binding:"omitempty,oneof=instant daily weekly"`
+	Channel   string        `json:"channel" binding:"omitempty,oneof=in_app email both"`
+	Enabled   *bool         `json:"enabled"`
+}
+
+func requireSavedSearch(c *gin.Context, db *gorm.DB) (*SavedSearch, error) {
+	searchID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, api_errors.InvalidID("id")
+	}
+	var search SavedSearch
+	err = db.Where("user_id = ?", c.GetUint("userID")).First(&search, searchID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, api_errors.NotFound(api_errors.CodeNotFound, "Saved search not found.")
+	}
+	if err != nil {
+		return nil, api_errors.Internal("Could not retrieve saved search.", err)
+	}
+	return &search, nil
+}
+
+// SavedSearchDue reports whether search should be evaluated at now.
+func SavedSearchDue(search SavedSearch, now time.Time) bool {
+	if !search.Enabled {
+		return false
+	}
+	if search.LastRunAt == nil {
+		return true
+	}
+	return !search.LastRunAt.Add(searchFrequencyIntervals[search.Frequency]).After(now)
+}
+
+// newSearchMatches returns the profiles matching search that were created or
+// updated since its last run and have not been reported yet.
+func newSearchMatches(db *gorm.DB, search SavedSearch) ([]Profile, error) {
+	var profiles []Profile
+	query := search.Filter.Apply(db.Model(&Profile{})).
+		Where("NOT EXISTS (SELECT 1 FROM saved_search_matches m WHERE m.saved_search_id = ? AND m.profile_id = profiles.id AND m.deleted_at IS NULL)", search.ID)
+	if search.LastRunAt != nil {
+		query = query.Where("profiles.updated_at > ?", *search.LastRunAt)
+	}
+	err := query.Order("profiles.updated_at DESC").Find(&profiles).Error
+	return profiles, err
+}
+
+// RunSavedSearch evaluates one search and alerts its owner about the new
+// matches. The first run only records the current matches as a baseline.
+func RunSavedSearch(db *gorm.DB, search *SavedSearch, now time.Time) error {
+	firstRun := search.LastRunAt == nil
+	profiles, err := newSearchMatches(db, *search)
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, profile := range profiles {
+			match := SavedSearchMatch{SavedSearchID: search.ID, ProfileID: profile.ID}
+			if err := tx.Create(&match).Error; err != nil {
+				return err
+			}
+		}
+		search.LastRunAt = &now
+		search.MatchCount += len(profiles)
+		return tx.Model(search).Updates(map[string]any{"last_run_at": now, "match_count": search.MatchCount}).Error
+	})
+	if err != nil || firstRun || len(profiles) == 0 {
+		return err
+	}
+
+	listed := profiles
+	if len(listed) > maxAlertMatches {
+		listed = listed[:maxAlertMatches]
+	}
+	ids := make([]uint, 0, len(listed))
+	var body strings.Builder
+	fmt.Fprintf(&body, "%d new player(s) match your saved search %q:\n\n", len(profiles), search.Name)
+	for _, profile := range listed {
+		ids = append(ids, profile.ID)
+		fmt.Fprintf(&body, "- %s %s (%s), /profiles/%d/%s\n", profile.FirstName, profile.LastName, profile.Position, profile.ID, profile.Slug)
+	}
+	if len(profiles) > len(listed) {
+		fmt.Fprintf(&body, "\n...and %d more.\n", len(profiles)-len(listed))
+	}
+
+	notification := Notification{
+		Type:  NotificationSavedSearchMatch,
+		Title: fmt.Sprintf("%d new match(es) for %q", len(profiles), search.Name),
+		Body:  body.String(),
+		Data:  map[string]any{"saved_search_id": search.ID, "profile_ids": ids},
+	}
+	inApp := search.Channel != AlertChannelEmail
+	email := search.Channel != AlertChannelInApp
+	return Notify(db, search.UserID, notification, inApp, email)
+}
+
+// RunSavedSearchAlerts evaluates every saved search that is due. It is run
+// periodically by the job scheduler; a failing search does not stop the rest.
+func RunSavedSearchAlerts(ctx context.Context, db *gorm.DB) error {
+	now := time.Now()
+	var searches []SavedSearch
+	if err := db.Where("enabled = ?", true).Find(&searches).Error; err != nil {
+		return err
+	}
+	failed := 0
+	for i := range searches {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !SavedSearchDue(searches[i], now) {
+			continue
+		}
+		if err := RunSavedSearch(db, &searches[i], now); err != nil {
+			log.Printf("Saved search %d failed: %v", searches[i].ID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d saved search(es) failed", failed)
+	}
+	return nil
+}
+
+func (h *DBHandler) SaveSearchGinHandler(c *gin.Context) {
+	var input SaveSearch
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	// Places and countries are looked up once, when the search is saved.
+	if err := input.Filter.Resolve(h.DB); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	search := SavedSearch{
+		UserID:    c.GetUint("userID"),
+		Name:      input.Name,
+		Filter:    input.Filter,
+		Frequency: input.Frequency,
+		Channel:   input.Channel,
+		Enabled:   input.Enabled == nil || *input.Enabled,
+	}
+	if search.Frequency == "" {
+		search.Frequency = SearchFrequencyDaily
+	}
+	if search.Channel == "" {
+		search.Channel = AlertChannelInApp
+	}
+	if err := h.DB.Create(&search).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to save search.", err))
+		return
+	}
+	// Record the current matches so only later ones are alerted.
+	if err := RunSavedSearch(h.DB, &search, time.Now()); err != nil {
+		log.Printf("Baseline run of saved search %d failed: %v", search.ID, err)
+	}
+	c.JSON(http.StatusCreated, search)
+}
+
+func (h *DBHandler) GetSavedSearchesGinHandler(c *gin.Context) {
+	var searches []SavedSearch
+	if err := h.DB.Where("user_id = ?", c.GetUint("userID")).Order("name").Find(&searches).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve saved searches.", err))
+		return
+	}
+	c.JSON(http.StatusOK, searches)
+}
+
+// sameFilter compares filters the way they are stored, so a missing list and
+// an empty one are the same.
+func sameFilter(a, b ProfileFilter) bool {
+	aj, aErr := json.Marshal(a)
+	bj, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aj, bj)
+}
+
+// UpdateSavedSearchGinHandler replaces a saved search. Changing the filter
+// starts a new baseline: the old matches are forgotten and everything that
+// matches the new filter now is recorded without an alert.
+func (h *DBHandler) UpdateSavedSearchGinHandler(c *gin.Context) {
+	var input SaveSearch
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	if err := input.Filter.Resolve(h.DB); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	search, err := requireSavedSearch(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	filterChanged := !sameFilter(search.Filter, input.Filter)
+	search.Name = input.Name
+	search.Filter = input.Filter
+	if input.Frequency != "" {
+		search.Frequency = input.Frequency
+	}
+	if input.Channel != "" {
+		search.Channel = input.Channel
+	}
+	if input.Enabled != nil {
+		search.Enabled = *input.Enabled
+	}
+	if filterChanged {
+		search.LastRunAt = nil
+		search.MatchCount = 0
+	}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(search).Error; err != nil {
+			return err
+		}
+		if !filterChanged {
+			return nil
+		}
+		return tx.Unscoped().Where("saved_search_id = ?", search.ID).Delete(&SavedSearchMatch{}).Error
+	})
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to update saved search.", err))
+		return
+	}
+	if filterChanged {
+		if err := RunSavedSearch(h.DB, search, time.Now()); err != nil {
+			log.Printf("Baseline run of saved search %d failed: %v", search.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, search)
+}
+
+func (h *DBHandler) DeleteSavedSearchGinHandler(c *gin.Context) {
+	search, err := requireSavedSearch(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("saved_search_id = ?", search.ID).Delete(&SavedSearchMatch{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(search).Error
+	})
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to delete saved search.", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RunSavedSearchGinHandler returns every profile currently matching a saved
+// search, without recording or alerting anything.
+func (h *DBHandler) RunSavedSearchGinHandler(c *gin.Context) {
+	search, err := requireSavedSearch(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	profiles, err := GetProfiles(h.DB, search.Filter)
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve profiles.", err))
+		return
+	}
+	for i := range profiles {
+		profiles[i].ConvertUnits(search.Filter.Units)
+		profiles[i].RedactMinor()
+	}
+	c.JSON(http.StatusOK, profiles)
+}
@@ -0,0 +1,609 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Access levels on a shortlist. Owners can also rename, delete and share it.
+const (
+	ShortlistOwner = "owner"
+	ShortlistEdit  = "edit"
+	ShortlistView  = "view"
+)
+
+// Shortlist is a named list of players kept by a scout. It is private to its
+// owner and the colleagues it is shared with.
+type Shortlist struct {
+	gorm.Model
+
+	OwnerID     uint             `gorm:"not null;index" json:"owner_id"`
+	Name        string           `gorm:"size:100;not null" json:"name"`
+	Description string           `gorm:"size:500" json:"description"`
+	Access      string           `gorm:"-" json:"access,omitempty"`
+	Entries     []ShortlistEntry `json:"entries,omitempty"`
+	Shares      []ShortlistShare `json:"shares,omitempty"`
+}
+
+// ShortlistEntry is a player on a shortlist with the scout's private notes.
+// Entries are shown in ascending Position.
+type ShortlistEntry struct {
+	gorm.Model
+
+	ShortlistID uint           `gorm:"not null;uniqueIndex:idx_shortlist_profile" json:"shortlist_id"`
+	ProfileID   uint           `gorm:"not null;uniqueIndex:idx_shortlist_profile;index" json:"profile_id"`
+	Profile     *Profile       `json:"profile,omitempty"`
+	Position    int            `gorm:"not null" json:"position"`
+	Note        string         `gorm:"type:text" json:"note"`
+	Rating      *int           `json:"rating"`
+	Tags        pq.StringArray `gorm:"type:text[]" json:"tags"`
+	AddedByID   uint           `json:"added_by_id"`
+}
+
+type ShortlistShare struct {
+	gorm.Model
+
+	ShortlistID uint   `gorm:"not null;uniqueIndex:idx_shortlist_share" json:"shortlist_id"`
+	UserID      uint   `gorm:"not null;uniqueIndex:idx_shortlist_share;index" json:"user_id"`
+	User        *User  `json:"user,omitempty"`
+	Permission  string `gorm:"size:10;not null;default:'view'" json:"permission"`
+}
+
+type CreateShortlist struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+type AddShortlistEntry struct {
+	ProfileID uint     `json:"profile_id" binding:"required"`
+	Note      string   `json:"note" binding:"max=5000"`
+	Rating    *int     `json:"rating" binding:"omitempty,gte=1,lte=10"`
+	Tags      []string `json:"tags" binding:"omitempty,max=10,dive,min=1,max=30"`
+}
+
+// UpdateShortlistEntry changes the notes of an entry. Only the fields that
+// are present are updated.
+type UpdateShortlistEntry struct {
+	Note   *string  `json:"note" binding:"omitempty,max=5000"`
+	Rating *int     `json:"rating" binding:"omitempty,gte=1,lte=10"`
+	Tags   []string `json:"tags" binding:"omitempty,max=10,dive,min=1,max=30"`
+}
+
+type ReorderShortlist struct {
+	EntryIDs []uint `json:"entry_ids" binding:"required,min=1"`
+}
+
+type ShareShortlist struct {
+	UserID     uint   `json:"user_id" binding:"required"`
+	Permission string `json:"permission" binding:"required,oneof=view edit"`
+}
+
+type ShortlistQuery struct {
+	Tag string `form:"tag" json:"tag,omitempty"`
+}
+
+// normalizeTags lower-cases and de-duplicates tags so "U21" and "u21" are
+// the same tag.
+func normalizeTags(tags []string) pq.StringArray {
+	normalized := pq.StringArray{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// requireShortlist loads a shortlist the current user can access with at
+// least the given level. Lists the user cannot see are reported as missing.
+func requireShortlist(c *gin.Context, db *gorm.DB, level string) (*Shortlist, error) {
+	listID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, api_errors.InvalidID("id")
+	}
+	var list Shortlist
+	err = db.First(&list, listID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, api_errors.NotFound(api_errors.CodeShortlistNotFound, "Shortlist not found.")
+	}
+	if err != nil {
+		return nil, api_errors.Internal("Could not retrieve shortlist.", err)
+	}
+
+	userID := c.GetUint("userID")
+	list.Access = ShortlistOwner
+	if list.OwnerID != userID {
+		var share ShortlistShare
+		err := db.Where("shortlist_id = ? AND user_id = ?", list.ID, userID).First(&share).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api_errors.NotFound(api_errors.CodeShortlistNotFound, "Shortlist not found.")
+		}
+		if err != nil {
+			return nil, api_errors.Internal("Could not check shortlist access.", err)
+		}
+		list.Access = share.Permission
+	}
+
+	if !shortlistAllows(list.Access, level) {
+		return nil, api_errors.Forbidden(api_errors.CodeForbidden, "You do not have "+level+" access to this shortlist.")
+	}
+	return &list, nil
+}
+
+func shortlistAllows(access, level string) bool {
+	switch level {
+	case ShortlistOwner:
+		return access == ShortlistOwner
+	case ShortlistEdit:
+		return access == ShortlistOwner || access == ShortlistEdit
+	default:
+		return true
+	}
+}
+
+func requireShortlistEntry(c *gin.Context, db *gorm.DB, list *Shortlist) (*ShortlistEntry, error) {
+	entryID, err := strconv.Atoi(c.Param("entry_id"))
+	if err != nil {
+		return nil, api_errors.InvalidID("entry_id")
+	}
+	var entry ShortlistEntry
+	err = db.Where("shortlist_id = ?", list.ID).First(&entry, entryID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, api_errors.NotFound(api_errors.CodeNotFound, "Shortlist entry not found.")
+	}
+	if err != nil {
+		return nil, api_errors.Internal("Could not retrieve shortlist entry.", err)
+	}
+	return &entry, nil
+}
+
+// GetShortlistEntries returns the entries of a list in order, optionally only
+// those carrying tag.
+func GetShortlistEntries(db *gorm.DB, listID uint, tag string) ([]ShortlistEntry, error) {
+	var entries []ShortlistEntry
+	query := db.Preload("Profile").Preload("Profile.ClubProfiles", "is_present_club = ?", true).
+		Where("shortlist_id = ?", listID)
+	if tag != "" {
+		query = query.Where("? = ANY(tags)", strings.ToLower(tag))
+	}
+	err := query.Order("position, id").Find(&entries).Error
+	for i := range entries {
+		if entries[i].Profile != nil {
+			entries[i].Profile.RedactMinor()
+		}
+	}
+	return entries, err
+}
+
+func (h *DBHandler) CreateShortlistGinHandler(c *gin.Context) {
+	var input CreateShortlist
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	user, err := requireRole(c, h.DB, RoleScout, RoleCoach, RoleClubAdmin)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	list := Shortlist{OwnerID: user.ID, Name: input.Name, Description: input.Description, Access: ShortlistOwner}
+	if err := h.DB.Create(&list).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to create shortlist.", err))
+		return
+	}
+	c.JSON(http.StatusCreated, list)
+}
+
+// GetShortlistsGinHandler lists the shortlists the current user owns or has
+// been given access to.
+func (h *DBHandler) GetShortlistsGinHandler(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var owned []Shortlist
+	if err := h.DB.Where("owner_id = ?", userID).Order("name").Find(&owned).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve shortlists.", err))
+		return
+	}
+	var shares []ShortlistShare
+	if err := h.DB.Where("user_id = ?", userID).Find(&shares).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve shortlists.", err))
+		return
+	}
+	access := map[uint]string{}
+	ids := make([]uint, 0, len(shares))
+	for _, share := range shares {
+		access[share.ShortlistID] = share.Permission
+		ids = append(ids, share.ShortlistID)
+	}
+	var shared []Shortlist
+	if len(ids) > 0 {
+		if err := h.DB.Where("id IN ?", ids).Order("name").Find(&shared).Error; err != nil {
+			api_errors.Abort(c, api_errors.Internal("Could not retrieve shortlists.", err))
+			return
+		}
+	}
+
+	lists := make([]Shortlist, 0, len(owned)+len(shared))
+	for _, list := range owned {
+		list.Access = ShortlistOwner
+		lists = append(lists, list)
+	}
+	for _, list := range shared {
+		list.Access = access[list.ID]
+		lists = append(lists, list)
+	}
+	c.JSON(http.StatusOK, lists)
+}
+
+func (h *DBHandler) GetShortlistGinHandler(c *gin.Context) {
+	var query ShortlistQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	list, err := requireShortlist(c, h.DB, ShortlistView)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if list.Entries, err = GetShortlistEntries(h.DB, list.ID, query.Tag); err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve shortlist entries.", err))
+		return
+	}
+	if list.Access == ShortlistOwner {
+		if err := h.DB.Preload("User", selectPublicUser).Where("shortlist_id = ?", list.ID).Find(&list.Shares).Error; err != nil {
+			api_errors.Abort(c, api_errors.Internal("Could not retrieve shortlist shares.", err))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *DBHandler) UpdateShortlistGinHandler(c *gin.Context) {
+	var input CreateShortlist
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	list, err := requireShortlist(c, h.DB, ShortlistOwner)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	list.Name = input.Name
+	list.Description = input.Description
+	if err := h.DB.Model(list).Updates(map[string]any{"name": input.Name, "description": input.Description}).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to update shortlist.", err))
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *DBHandler) DeleteShortlistGinHandler(c *gin.Context) {
+	list, err := requireShortlist(c, h.DB, ShortlistOwner)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("shortlist_id = ?", list.ID).Delete(&ShortlistEntry{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("shortlist_id = ?", list.ID).Delete(&ShortlistShare{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(list).Error
+	})
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to delete shortlist.", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *DBHandler) AddShortlistEntryGinHandler(c *gin.Context) {
+	var input AddShortlistEntry
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	list, err := requireShortlist(c, h.DB, ShortlistEdit)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	profile, err := requirePublicProfile(h.DB, input.ProfileID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	var count int64
+	if err := h.DB.Model(&ShortlistEntry{}).Where("shortlist_id = ? AND profile_id = ?", list.ID, profile.ID).Count(&count).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not check shortlist entries.", err))
+		return
+	}
+	if count > 0 {
+		api_errors.Abort(c, api_errors.Conflict(api_errors.CodeAlreadyShortlisted, "This player is already on the shortlist."))
+		return
+	}
+
+	var last struct{ Position int }
+	if err := h.DB.Model(&ShortlistEntry{}).Select("COALESCE(MAX(position), 0) AS position").
+		Where("shortlist_id = ?", list.ID).Scan(&last).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not add to shortlist.", err))
+		return
+	}
+	entry := ShortlistEntry{
+		ShortlistID: list.ID,
+		ProfileID:   profile.ID,
+		Position:    last.Position + 1,
+		Note:        input.Note,
+		Rating:      input.Rating,
+		Tags:        normalizeTags(input.Tags),
+		AddedByID:   c.GetUint("userID"),
+	}
+	if err := h.DB.Create(&entry).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to add to shortlist.", err))
+		return
+	}
+	entry.Profile = &profile
+	c.JSON(http.StatusCreated, entry)
+}
+
+func (h *DBHandler) UpdateShortlistEntryGinHandler(c *gin.Context) {
+	var input UpdateShortlistEntry
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	list, err := requireShortlist(c, h.DB, ShortlistEdit)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	entry, err := requireShortlistEntry(c, h.DB, list)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	updates := map[string]any{}
+	if input.Note != nil {
+		updates["note"] = *input.Note
+	}
+	if input.Rating != nil {
+		updates["rating"] = *input.Rating
+	}
+	if input.Tags != nil {
+		updates["tags"] = normalizeTags(input.Tags)
+	}
+	if len(updates) > 0 {
+		if err := h.DB.Model(entry).Updates(updates).Error; err != nil {
+			api_errors.Abort(c, api_errors.Internal("Failed to update shortlist entry.", err))
+			return
+		}
+	}
+	if err := h.DB.First(entry, entry.ID).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve shortlist entry.", err))
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+func (h *DBHandler) RemoveShortlistEntryGinHandler(c *gin.Context) {
+	list, err := requireShortlist(c, h.DB, ShortlistEdit)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	entry, err := requireShortlistEntry(c, h.DB, list)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	// Hard delete so the player can be shortlisted again later.
+	if err := h.DB.Unscoped().Delete(entry).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to remove shortlist entry.", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ReorderShortlistGinHandler sets the order of a list. EntryIDs must name
+// every entry of the list exactly once.
+func (h *DBHandler) ReorderShortlistGinHandler(c *gin.Context) {
+	var input ReorderShortlist
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	list, err := requireShortlist(c, h.DB, ShortlistEdit)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	var current []uint
+	if err := h.DB.Model(&ShortlistEntry{}).Where("shortlist_id = ?", list.ID).Pluck("id", &current).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve shortlist entries.", err))
+		return
+	}
+	requested := slices.Clone(input.EntryIDs)
+	slices.Sort(current)
+	slices.Sort(requested)
+	if !slices.Equal(current, slices.Compact(requested)) || len(requested) != len(input.EntryIDs) {
+		api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("entry_ids", "permutation", "")))
+		return
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		for i, id := range input.EntryIDs {
+			if err := tx.Model(&ShortlistEntry{}).Where("id = ?", id).Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to reorder shortlist.", err))
+		return
+	}
+	entries, err := GetShortlistEntries(h.DB, list.ID, "")
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve shortlist entries.", err))
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// ShareShortlistGinHandler gives a colleague view or edit access, or changes
+// the access they already have.
+func (h *DBHandler) ShareShortlistGinHandler(c *gin.Context) {
+	var input ShareShortlist
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	list, err := requireShortlist(c, h.DB, ShortlistOwner)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	user := GetUserByID(h.DB, input.UserID)
+	if user == nil || user.ID == 0 {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeUserNotFound, "User not found."))
+		return
+	}
+	if user.ID == list.OwnerID {
+		api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("user_id", "not_owner", "")))
+		return
+	}
+
+	share := ShortlistShare{ShortlistID: list.ID, UserID: user.ID}
+	if err := h.DB.Where(share).Assign(ShortlistShare{Permission: input.Permission}).FirstOrCreate(&share).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to share shortlist.", err))
+		return
+	}
+	share.User = user
+	c.JSON(http.StatusCreated, share)
+}
+
+func (h *DBHandler) UnshareShortlistGinHandler(c *gin.Context) {
+	list, err := requireShortlist(c, h.DB, ShortlistOwner)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("user_id"))
+		return
+	}
+	result := h.DB.Unscoped().Where("shortlist_id = ? AND user_id = ?", list.ID, userID).Delete(&ShortlistShare{})
+	if result.Error != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to unshare shortlist.", result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "The shortlist is not shared with this user."))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// csvCell stops spreadsheet applications from evaluating free text such as
+// notes as a formula.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// ExportShortlistGinHandler downloads a shortlist as CSV, one row per player
+// in list order.
+func (h *DBHandler) ExportShortlistGinHandler(c *gin.Context) {
+	list, err := requireShortlist(c, h.DB, ShortlistView)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	entries, err := GetShortlistEntries(h.DB, list.ID, c.Query("tag"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve shortlist entries.", err))
+		return
+	}
+
+	filename := strings.Trim(unsafeFilenameChars.ReplaceAllString(list.Name, "-"), "-")
+	if filename == "" {
+		filename = "shortlist-" + strconv.FormatUint(uint64(list.ID), 10)
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"rank", "profile_id", "first_name", "last_name", "position", "age", "nationality", "current_club", "rating", "tags", "note", "added_at"})
+	for _, entry := range entries {
+		var row []string
+		row = append(row, strconv.Itoa(entry.Position), strconv.FormatUint(uint64(entry.ProfileID), 10))
+		if p := entry.Profile; p != nil {
+			club := ""
+			if len(p.ClubProfiles) > 0 {
+				club = p.ClubProfiles[0].ClubName
+			}
+			row = append(row, p.FirstName, p.LastName, p.Position, strconv.Itoa(p.Age), p.Nationality, club)
+		} else {
+			row = append(row, "", "", "", "", "", "")
+		}
+		rating := ""
+		if entry.Rating != nil {
+			rating = strconv.Itoa(*entry.Rating)
+		}
+		row = append(row, rating, strings.Join(entry.Tags, "; "), entry.Note, entry.CreatedAt.Format(time.RFC3339))
+		for i := range row {
+			row[i] = csvCell(row[i])
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Printf("CSV export of shortlist %d failed: %v", list.ID, err)
+	}
+}
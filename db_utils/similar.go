@@ -0,0 +1,419 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/utils"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SimilarPlayersPerProfile is how many suggestions are kept per player.
+const SimilarPlayersPerProfile = 10
+
+// Features players are compared on besides position. Skill ratings are
+// features too, keyed "skill:<code>".
+const (
+	FeatureAge    = "age"
+	FeatureWeight = "weight"
+	skillFeature  = "skill:"
+)
+
+// similarityWeights weighs each feature in the distance. The skills shared
+// by two players split skillsWeight between them, so players who both list
+// many skills are not compared on skills alone.
+var similarityWeights = map[string]float64{
+	FeatureAge:         1.5,
+	MetricHeight:       1,
+	FeatureWeight:      0.5,
+	MetricSprint30m:    1,
+	MetricGoalsPer90:   1,
+	MetricAssistsPer90: 1,
+}
+
+const (
+	skillsWeight = 2.0
+	// positionShare is the part of the score given by position fit; the
+	// rest comes from the feature distance.
+	positionShare = 0.2
+	// minSharedFeatures is how many features two players must both have to
+	// be compared at all.
+	minSharedFeatures = 2
+	// closeFeature is the largest difference, in standard deviations, that
+	// is explained as similar.
+	closeFeature = 0.5
+	maxReasons   = 5
+)
+
+// SimilarityReason explains one way two players are alike. Values are the
+// viewed player's and the suggested player's, in metric units.
+type SimilarityReason struct {
+	Feature      string   `json:"feature"`
+	Description  string   `json:"description"`
+	Value        *float64 `json:"value,omitempty"`
+	SimilarValue *float64 `json:"similar_value,omitempty"`
+}
+
+// SimilarPlayer is a precomputed "players like this" suggestion. Score runs
+// from 0 to 1; Rank 1 is the closest match.
+type SimilarPlayer struct {
+	gorm.Model
+
+	ProfileID        uint               `gorm:"not null;index" json:"profile_id"`
+	SimilarProfileID uint               `gorm:"not null" json:"similar_profile_id"`
+	SimilarProfile   *Profile           `json:"similar_profile,omitempty"`
+	Rank             int                `gorm:"not null" json:"rank"`
+	Score            float64            `gorm:"not null" json:"score"`
+	Reasons          []SimilarityReason `gorm:"serializer:json;type:jsonb" json:"reasons"`
+	ComputedAt       time.Time          `gorm:"not null;index" json:"computed_at"`
+}
+
+type SimilarPlayersQuery struct {
+	Limit int    `form:"limit" json:"limit,omitempty" binding:"omitempty,gte=1,lte=10"`
+	Units string `form:"units" json:"units,omitempty" binding:"omitempty,oneof=metric imperial"`
+}
+
+// playerFeatures is what a player is compared on. Values only holds the
+// features the player has data for.
+type playerFeatures struct {
+	ProfileID uint
+	Position  string
+	Positions []string
+	Values    map[string]float64
+}
+
+// featureScale is the mean and standard deviation of a feature within the
+// players being compared, used to put features on the same scale.
+type featureScale struct {
+	Mean float64
+	Std  float64
+}
+
+// extractFeatures reads the features of a profile preloaded with its
+// positions, physical tests, skills and season stats.
+func extractFeatures(p Profile, now time.Time) playerFeatures {
+	f := playerFeatures{ProfileID: p.ID, Position: p.Position, Positions: []string{p.Position}, Values: map[string]float64{}}
+	for _, pos := range p.Positions {
+		if !slices.Contains(f.Positions, pos.Position) {
+			f.Positions = append(f.Positions, pos.Position)
+		}
+	}
+	if !p.Dob.IsZero() {
+		f.Values[FeatureAge] = float64(utils.AgeOn(p.Dob, now))
+	}
+	if p.Height > 0 {
+		f.Values[MetricHeight] = p.Height
+	}
+	if p.Weight > 0 {
+		f.Values[FeatureWeight] = p.Weight
+	}
+	if sprint, ok := bestPhysicalTests(p.PhysicalTests)[PhysicalTestSprint30m]; ok {
+		f.Values[MetricSprint30m] = sprint
+	}
+	// Coach assessments take precedence over self ratings.
+	for _, s := range p.Skills {
+		rating := s.CoachRating
+		if rating == nil {
+			rating = s.SelfRating
+		}
+		if s.Code != "" && rating != nil {
+			f.Values[skillFeature+s.Code] = float64(*rating)
+		}
+	}
+	timed := slices.DeleteFunc(slices.Clone(p.SeasonStats), func(s SeasonStat) bool { return s.MinutesPlayed == nil })
+	minutes := derefInt(sumStat(timed, func(s SeasonStat) *int32 { return s.MinutesPlayed }))
+	if v := per90(sumStat(timed, func(s SeasonStat) *int32 { return s.Goals }), minutes); v != nil {
+		f.Values[MetricGoalsPer90] = *v
+	}
+	if v := per90(sumStat(timed, func(s SeasonStat) *int32 { return s.Assists }), minutes); v != nil {
+		f.Values[MetricAssistsPer90] = *v
+	}
+	return f
+}
+
+// featureScales computes the scale of every feature over players.
+func featureScales(players []playerFeatures) map[string]featureScale {
+	sums, counts := map[string]float64{}, map[string]int{}
+	for _, p := range players {
+		for k, v := range p.Values {
+			sums[k] += v
+			counts[k]++
+		}
+	}
+	scales := map[string]featureScale{}
+	for k, sum := range sums {
+		scales[k] = featureScale{Mean: sum / float64(counts[k])}
+	}
+	for _, p := range players {
+		for k, v := range p.Values {
+			s := scales[k]
+			s.Std += (v - s.Mean) * (v - s.Mean)
+			scales[k] = s
+		}
+	}
+	for k, s := range scales {
+		s.Std = math.Sqrt(s.Std / float64(counts[k]))
+		// A feature everyone has the same value for tells players apart by
+		// its raw difference.
+		if s.Std == 0 {
+			s.Std = 1
+		}
+		scales[k] = s
+	}
+	return scales
+}
+
+// positionSimilarity is 1 for the same primary position, less for a shared
+// secondary position, and least for the same position group.
+func positionSimilarity(a, b playerFeatures) float64 {
+	switch {
+	case a.Position == b.Position:
+		return 1
+	case slices.ContainsFunc(a.Positions, func(pos string) bool { return slices.Contains(b.Positions, pos) }):
+		return 0.6
+	default:
+		return 0.3
+	}
+}
+
+// similarity scores b against a: a weighted distance over the normalized
+// features both have, blended with their position fit. ok is false when they
+// share too few features to be compared.
+func similarity(a, b playerFeatures, scales map[string]featureScale) (score float64, diffs map[string]float64, ok bool) {
+	diffs = map[string]float64{}
+	skills := 0
+	for k, v := range a.Values {
+		if w, ok := b.Values[k]; ok {
+			diffs[k] = math.Abs(v-w) / scales[k].Std
+			if strings.HasPrefix(k, skillFeature) {
+				skills++
+			}
+		}
+	}
+	if len(diffs) < minSharedFeatures {
+		return 0, nil, false
+	}
+	var sum, weights float64
+	for k, d := range diffs {
+		w := similarityWeights[k]
+		if strings.HasPrefix(k, skillFeature) {
+			w = skillsWeight / float64(skills)
+		}
+		sum += w * d * d
+		weights += w
+	}
+	distance := math.Sqrt(sum / weights)
+	score = positionShare*positionSimilarity(a, b) + (1-positionShare)/(1+distance)
+	return utils.Round(score, 3), diffs, true
+}
+
+// similarityReasons explains the match of b for a: the position fit, then
+// the closest of their features, most important first.
+func similarityReasons(a, b playerFeatures, diffs map[string]float64) []SimilarityReason {
+	var reasons []SimilarityReason
+	shared := slices.IndexFunc(a.Positions, func(pos string) bool { return slices.Contains(b.Positions, pos) })
+	switch {
+	case a.Position == b.Position:
+		reasons = append(reasons, SimilarityReason{Feature: "position", Description: "Both play " + a.Position})
+	case shared >= 0:
+		reasons = append(reasons, SimilarityReason{Feature: "position", Description: "Both can play " + a.Positions[shared]})
+	default:
+		if info, ok := LookupPosition(a.Position); ok {
+			reasons = append(reasons, SimilarityReason{Feature: "position", Description: "Also a " + info.Group})
+		}
+	}
+
+	var alike []string
+	for k, d := range diffs {
+		if d <= closeFeature {
+			alike = append(alike, k)
+		}
+	}
+	weight := func(k string) float64 {
+		if strings.HasPrefix(k, skillFeature) {
+			return skillsWeight / 2
+		}
+		return similarityWeights[k]
+	}
+	sort.Slice(alike, func(i, j int) bool {
+		wi, wj := weight(alike[i]), weight(alike[j])
+		if wi != wj {
+			return wi > wj
+		}
+		if diffs[alike[i]] != diffs[alike[j]] {
+			return diffs[alike[i]] < diffs[alike[j]]
+		}
+		return alike[i] < alike[j]
+	})
+	for _, k := range alike {
+		if len(reasons) == maxReasons {
+			break
+		}
+		value, similar := a.Values[k], b.Values[k]
+		reasons = append(reasons, SimilarityReason{
+			Feature:      k,
+			Description:  describeFeature(k, value, similar),
+			Value:        &value,
+			SimilarValue: &similar,
+		})
+	}
+	return reasons
+}
+
+func describeFeature(feature string, a, b float64) string {
+	number := func(v float64, decimals int) string { return strconv.FormatFloat(v, 'f', decimals, 64) }
+	switch feature {
+	case FeatureAge:
+		return fmt.Sprintf("Similar age (%s and %s)", number(a, 0), number(b, 0))
+	case MetricHeight:
+		return fmt.Sprintf("Similar height (%s cm and %s cm)", number(a, 0), number(b, 0))
+	case FeatureWeight:
+		return fmt.Sprintf("Similar weight (%s kg and %s kg)", number(a, 0), number(b, 0))
+	case MetricSprint30m:
+		return fmt.Sprintf("Similar 30m sprint (%s s and %s s)", number(a, 2), number(b, 2))
+	case MetricGoalsPer90:
+		return fmt.Sprintf("Similar goals per 90 (%s and %s)", number(a, 2), number(b, 2))
+	case MetricAssistsPer90:
+		return fmt.Sprintf("Similar assists per 90 (%s and %s)", number(a, 2), number(b, 2))
+	}
+	name := strings.TrimPrefix(feature, skillFeature)
+	if info, ok := LookupSkill(name); ok {
+		name = strings.ToLower(info.Name)
+	}
+	return fmt.Sprintf("Similar %s rating (%s and %s)", name, number(a, 0), number(b, 0))
+}
+
+// rankSimilar returns the closest players to target among candidates.
+func rankSimilar(target playerFeatures, candidates []playerFeatures, scales map[string]featureScale, now time.Time) []SimilarPlayer {
+	var matches []SimilarPlayer
+	for _, candidate := range candidates {
+		if candidate.ProfileID == target.ProfileID {
+			continue
+		}
+		score, diffs, ok := similarity(target, candidate, scales)
+		if !ok {
+			continue
+		}
+		matches = append(matches, SimilarPlayer{
+			ProfileID:        target.ProfileID,
+			SimilarProfileID: candidate.ProfileID,
+			Score:            score,
+			Reasons:          similarityReasons(target, candidate, diffs),
+			ComputedAt:       now,
+		})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].SimilarProfileID < matches[j].SimilarProfileID
+	})
+	if len(matches) > SimilarPlayersPerProfile {
+		matches = matches[:SimilarPlayersPerProfile]
+	}
+	for i := range matches {
+		matches[i].Rank = i + 1
+	}
+	return matches
+}
+
+// loadGroupFeatures reads the features of every public player in a position
+// group. Players are only ever compared within their group.
+func loadGroupFeatures(ctx context.Context, db *gorm.DB, group string, now time.Time) ([]playerFeatures, error) {
+	var players []playerFeatures
+	var batch []Profile
+	err := db.WithContext(ctx).Scopes(PublicProfiles).
+		Preload("Positions").
+		Preload("PhysicalTests").
+		Preload("Skills").
+		Preload("SeasonStats").
+		Where("profiles.position IN ?", PositionsInGroup(group)).
+		FindInBatches(&batch, 200, func(tx *gorm.DB, _ int) error {
+			for _, p := range batch {
+				players = append(players, extractFeatures(p, now))
+			}
+			return nil
+		}).Error
+	return players, err
+}
+
+// RunSimilarPlayers recomputes the suggestions of every public player and
+// replaces the previous run's in one transaction.
+func RunSimilarPlayers(ctx context.Context, db *gorm.DB) error {
+	now := time.Now()
+	var matches []SimilarPlayer
+	for _, group := range []string{PositionGroupGoalkeeper, PositionGroupDefender, PositionGroupMidfielder, PositionGroupForward} {
+		players, err := loadGroupFeatures(ctx, db, group, now)
+		if err != nil {
+			return err
+		}
+		scales := featureScales(players)
+		for _, p := range players {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			matches = append(matches, rankSimilar(p, players, scales, now)...)
+		}
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if len(matches) > 0 {
+			if err := tx.CreateInBatches(&matches, 500).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Where("computed_at < ?", now).Delete(&SimilarPlayer{}).Error
+	})
+}
+
+// GetSimilarPlayersGinHandler suggests players like the given one, with the
+// reasons they are alike. Suggestions only come from the periodic
+// recomputation: ranking a whole position group is too costly to do for an
+// anonymous request, so players it has not seen yet get an empty list.
+func (h *DBHandler) GetSimilarPlayersGinHandler(c *gin.Context) {
+	var query SimilarPlayersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	profileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.InvalidID("id"))
+		return
+	}
+	profile, err := requirePublicProfile(h.DB, uint(profileID))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	limit := query.Limit
+	if limit == 0 {
+		limit = SimilarPlayersPerProfile
+	}
+
+	// Players who stopped being public since the last run are left out.
+	similar := []SimilarPlayer{}
+	err = h.DB.Preload("SimilarProfile").
+		Where("profile_id = ? AND similar_profile_id IN (?)", profile.ID, h.DB.Model(&Profile{}).Scopes(PublicProfiles).Select("profiles.id")).
+		Order("rank").Limit(limit).
+		Find(&similar).Error
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve similar players.", err))
+		return
+	}
+	for i := range similar {
+		if p := similar[i].SimilarProfile; p != nil {
+			p.ConvertUnits(query.Units)
+			p.RedactMinor()
+		}
+	}
+	c.JSON(http.StatusOK, similar)
+}
@@ -0,0 +1,443 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	SkillCategoryTechnical   = "technical"
+	SkillCategoryPhysical    = "physical"
+	SkillCategoryMental      = "mental"
+	SkillCategoryDefending   = "defending"
+	SkillCategoryGoalkeeping = "goalkeeping"
+)
+
+// Skills are rated on a 1-10 scale, both by the player and by coaches.
+const (
+	MinSkillRating = 1
+	MaxSkillRating = 10
+)
+
+type SkillInfo struct {
+	Code     string   `json:"code"`
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Aliases  []string `json:"aliases,omitempty"`
+}
+
+// SkillCatalogue is the curated list of skills a profile can list. Aliases
+// are extra spellings seen in the old free-text skill names.
+var SkillCatalogue = []SkillInfo{
+	{Code: "dribbling", Name: "Dribbling", Category: SkillCategoryTechnical, Aliases: []string{"dribble", "dribbler", "1v1", "take ons"}},
+	{Code: "ball_control", Name: "Ball Control", Category: SkillCategoryTechnical, Aliases: []string{"control", "technique"}},
+	{Code: "first_touch", Name: "First Touch", Category: SkillCategoryTechnical, Aliases: []string{"touch"}},
+	{Code: "short_passing", Name: "Short Passing", Category: SkillCategoryTechnical, Aliases: []string{"passing", "pass", "link up play"}},
+	{Code: "passing_range", Name: "Passing Range", Category: SkillCategoryTechnical, Aliases: []string{"long passing", "long balls", "switching play"}},
+	{Code: "crossing", Name: "Crossing", Category: SkillCategoryTechnical, Aliases: []string{"crosses"}},
+	{Code: "finishing", Name: "Finishing", Category: SkillCategoryTechnical, Aliases: []string{"shooting", "scoring", "goalscoring"}},
+	{Code: "long_shots", Name: "Long Shots", Category: SkillCategoryTechnical, Aliases: []string{"long range shooting", "long range"}},
+	{Code: "heading", Name: "Heading", Category: SkillCategoryTechnical, Aliases: []string{"headers"}},
+	{Code: "set_pieces", Name: "Set Pieces", Category: SkillCategoryTechnical, Aliases: []string{"free kicks", "corners", "penalties", "dead ball"}},
+	{Code: "pace", Name: "Pace", Category: SkillCategoryPhysical, Aliases: []string{"speed", "sprint speed"}},
+	{Code: "acceleration", Name: "Acceleration", Category: SkillCategoryPhysical},
+	{Code: "stamina", Name: "Stamina", Category: SkillCategoryPhysical, Aliases: []string{"endurance", "fitness"}},
+	{Code: "strength", Name: "Strength", Category: SkillCategoryPhysical, Aliases: []string{"physicality", "power"}},
+	{Code: "agility", Name: "Agility", Category: SkillCategoryPhysical, Aliases: []string{"balance"}},
+	{Code: "aerial_duels", Name: "Aerial Duels", Category: SkillCategoryPhysical, Aliases: []string{"aerial ability", "jumping", "in the air"}},
+	{Code: "vision", Name: "Vision", Category: SkillCategoryMental, Aliases: []string{"creativity"}},
+	{Code: "positioning", Name: "Positioning", Category: SkillCategoryMental, Aliases: []string{"off the ball", "movement"}},
+	{Code: "composure", Name: "Composure", Category: SkillCategoryMental, Aliases: []string{"calmness"}},
+	{Code: "decision_making", Name: "Decision Making", Category: SkillCategoryMental, Aliases: []string{"decisions", "game intelligence", "football iq"}},
+	{Code: "work_rate", Name: "Work Rate", Category: SkillCategoryMental, Aliases: []string{"pressing", "work ethic"}},
+	{Code: "leadership", Name: "Leadership", Category: SkillCategoryMental, Aliases: []string{"captaincy", "communication"}},
+	{Code: "tackling", Name: "Tackling", Category: SkillCategoryDefending, Aliases: []string{"tackles", "defending"}},
+	{Code: "marking", Name: "Marking", Category: SkillCategoryDefending, Aliases: []string{"man marking"}},
+	{Code: "interceptions", Name: "Interceptions", Category: SkillCategoryDefending, Aliases: []string{"reading the game", "anticipation"}},
+	{Code: "shot_stopping", Name: "Shot Stopping", Category: SkillCategoryGoalkeeping, Aliases: []string{"reflexes", "saves"}},
+	{Code: "handling", Name: "Handling", Category: SkillCategoryGoalkeeping},
+	{Code: "distribution", Name: "Distribution", Category: SkillCategoryGoalkeeping, Aliases: []string{"kicking", "throwing"}},
+	{Code: "command_of_area", Name: "Command of Area", Category: SkillCategoryGoalkeeping, Aliases: []string{"claiming crosses"}},
+	{Code: "one_on_ones", Name: "One on Ones", Category: SkillCategoryGoalkeeping, Aliases: []string{"one v one", "1 v 1"}},
+}
+
+var skillIndex = map[string]string{}
+
+func init() {
+	for _, s := range SkillCatalogue {
+		skillIndex[normalizePositionKey(s.Code)] = s.Code
+		skillIndex[normalizePositionKey(s.Name)] = s.Code
+		for _, alias := range s.Aliases {
+			skillIndex[normalizePositionKey(alias)] = s.Code
+		}
+	}
+}
+
+// NormalizeSkill maps a catalogue code or a free-text skill name ("Passing
+// range", "long balls") onto a catalogue code.
+func NormalizeSkill(s string) (string, bool) {
+	code, ok := skillIndex[normalizePositionKey(s)]
+	return code, ok
+}
+
+// LookupSkill returns the catalogue entry for a skill code.
+func LookupSkill(code string) (SkillInfo, bool) {
+	for _, s := range SkillCatalogue {
+		if s.Code == code {
+			return s, true
+		}
+	}
+	return SkillInfo{}, false
+}
+
+// skillLevels maps the words used in the old free-text Level field onto the
+// rating scale. SkillLevelLabel goes the other way for legacy clients.
+var skillLevels = map[string]int{
+	"beginner":     2,
+	"basic":        2,
+	"developing":   4,
+	"intermediate": 5,
+	"average":      5,
+	"good":         6,
+	"advanced":     7,
+	"very good":    8,
+	"expert":       9,
+	"excellent":    9,
+	"elite":        10,
+}
+
+// ParseSkillLevel reads a legacy Level value: a word such as "Advanced", a
+// number ("7") or a fraction ("7/10", "4/5") rescaled onto 1-10.
+func ParseSkillLevel(level string) (int, bool) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if rating, ok := skillLevels[level]; ok {
+		return rating, true
+	}
+	value, scale, hasScale := strings.Cut(level, "/")
+	rating, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, false
+	}
+	if hasScale {
+		max, err := strconv.Atoi(strings.TrimSpace(scale))
+		if err != nil || max <= 0 || rating > max {
+			return 0, false
+		}
+		rating = (rating*MaxSkillRating + max/2) / max
+	}
+	if rating < MinSkillRating || rating > MaxSkillRating {
+		return 0, false
+	}
+	return rating, true
+}
+
+// SkillLevelLabel describes a rating in words. It fills Skill.Level so
+// clients that predate ratings keep showing something sensible.
+func SkillLevelLabel(rating int) string {
+	switch {
+	case rating >= 9:
+		return "Expert"
+	case rating >= 7:
+		return "Advanced"
+	case rating >= 5:
+		return "Intermediate"
+	case rating >= 3:
+		return "Developing"
+	default:
+		return "Beginner"
+	}
+}
+
+// Who may endorse a skill: a verified coach, or a player who shared a club
+// with the skill's owner.
+const (
+	EndorsementByCoach    = "coach"
+	EndorsementByTeammate = "teammate"
+)
+
+type SkillEndorsement struct {
+	gorm.Model
+
+	SkillID      uint   `gorm:"not null;uniqueIndex:idx_skill_endorser" json:"skill_id"`
+	EndorserID   uint   `gorm:"not null;uniqueIndex:idx_skill_endorser" json:"endorser_id"`
+	Endorser     *User  `json:"endorser,omitempty"`
+	Relationship string `gorm:"size:20;not null" json:"relationship"`
+	Comment      string `gorm:"size:280" json:"comment"`
+}
+
+type EndorseSkill struct {
+	Comment string `json:"comment" binding:"max=280"`
+}
+
+type AssessSkill struct {
+	Rating int `json:"rating" binding:"required,gte=1,lte=10"`
+}
+
+// SetProfileSkill adds a catalogue skill to a profile, or updates the self
+// rating when the profile already lists it.
+func SetProfileSkill(db *gorm.DB, skill *Skill) error {
+	var existing Skill
+	err := db.Where("profile_id = ? AND code = ?", skill.ProfileID, skill.Code).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return db.Create(skill).Error
+	}
+	if err != nil {
+		return err
+	}
+	if err := db.Model(&existing).Updates(map[string]any{
+		"name":        skill.Name,
+		"level":       skill.Level,
+		"self_rating": skill.SelfRating,
+	}).Error; err != nil {
+		return err
+	}
+	existing.Profile = skill.Profile
+	*skill = existing
+	return nil
+}
+
+func requireSkill(db *gorm.DB, idParam string) (Skill, error) {
+	var skill Skill
+	skillID, err := strconv.Atoi(idParam)
+	if err != nil {
+		return skill, api_errors.InvalidID("id")
+	}
+	err = db.First(&skill, skillID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return skill, api_errors.NotFound(api_errors.CodeSkillNotFound, "Skill not found.")
+	}
+	if err != nil {
+		return skill, api_errors.Internal("Could not retrieve skill.", err)
+	}
+	// Skills of a hidden minor are as missing as their profile.
+	skill.Profile, err = requirePublicProfile(db, skill.ProfileID)
+	return skill, err
+}
+
+// EndorsementRelationship works out in which capacity user may endorse a
+// skill of profile. Teammates must have played for the same club during
+// overlapping spells.
+func EndorsementRelationship(db *gorm.DB, user *User, profile Profile) (string, error) {
+	if user.Role == RoleCoach && user.IsVerified {
+		return EndorsementByCoach, nil
+	}
+	var count int64
+	err := db.Table("club_profiles AS mine").
+		Joins("JOIN profiles ON profiles.id = mine.profile_id AND profiles.deleted_at IS NULL").
+		Joins("JOIN club_profiles AS theirs ON theirs.club_id = mine.club_id AND theirs.deleted_at IS NULL").
+		Where("profiles.user_id = ? AND theirs.profile_id = ? AND mine.deleted_at IS NULL", user.ID, profile.ID).
+		Where("COALESCE(mine.start_year, '-infinity') <= COALESCE(theirs.end_year, 'infinity') AND COALESCE(theirs.start_year, '-infinity') <= COALESCE(mine.end_year, 'infinity')").
+		Count(&count).Error
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return EndorsementByTeammate, nil
+	}
+	return "", nil
+}
+
+// AddSkillEndorsement stores an endorsement and bumps the skill's counter in
+// the same transaction.
+func AddSkillEndorsement(db *gorm.DB, endorsement *SkillEndorsement) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(endorsement).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Skill{}).Where("id = ?", endorsement.SkillID).
+			Update("endorsement_count", gorm.Expr("endorsement_count + 1")).Error
+	})
+}
+
+// RemoveSkillEndorsement withdraws userID's endorsement of skillID. It
+// reports whether there was one.
+func RemoveSkillEndorsement(db *gorm.DB, skillID, userID uint) (bool, error) {
+	removed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("skill_id = ? AND endorser_id = ?", skillID, userID).Delete(&SkillEndorsement{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		removed = true
+		return tx.Model(&Skill{}).Where("id = ?", skillID).
+			Update("endorsement_count", gorm.Expr("GREATEST(endorsement_count - 1, 0)")).Error
+	})
+	return removed, err
+}
+
+func (h *DBHandler) GetSkillCatalogueGinHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, SkillCatalogue)
+}
+
+func (h *DBHandler) EndorseSkillGinHandler(c *gin.Context) {
+	var input EndorseSkill
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	skill, err := requireSkill(h.DB, c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	user, err := CurrentUser(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if skill.Profile.UserID == user.ID {
+		api_errors.Abort(c, api_errors.Forbidden(api_errors.CodeForbidden, "You cannot endorse your own skills."))
+		return
+	}
+
+	relationship, err := EndorsementRelationship(h.DB, user, skill.Profile)
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not check endorsement eligibility.", err))
+		return
+	}
+	if relationship == "" {
+		api_errors.Abort(c, api_errors.Forbidden(api_errors.CodeForbidden, "Only verified coaches and former teammates can endorse this skill."))
+		return
+	}
+
+	var count int64
+	if err := h.DB.Model(&SkillEndorsement{}).Where("skill_id = ? AND endorser_id = ?", skill.ID, user.ID).Count(&count).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not check existing endorsements.", err))
+		return
+	}
+	if count > 0 {
+		api_errors.Abort(c, api_errors.Conflict(api_errors.CodeAlreadyEndorsed, "You have already endorsed this skill."))
+		return
+	}
+
+	endorsement := SkillEndorsement{
+		SkillID:      skill.ID,
+		EndorserID:   user.ID,
+		Relationship: relationship,
+		Comment:      input.Comment,
+	}
+	if err := AddSkillEndorsement(h.DB, &endorsement); err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to endorse skill.", err))
+		return
+	}
+	endorsement.Endorser = user
+	c.JSON(http.StatusCreated, endorsement)
+}
+
+func (h *DBHandler) WithdrawSkillEndorsementGinHandler(c *gin.Context) {
+	skill, err := requireSkill(h.DB, c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	removed, err := RemoveSkillEndorsement(h.DB, skill.ID, c.GetUint("userID"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to withdraw endorsement.", err))
+		return
+	}
+	if !removed {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeNotFound, "You have not endorsed this skill."))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *DBHandler) GetSkillEndorsementsGinHandler(c *gin.Context) {
+	skill, err := requireSkill(h.DB, c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	var endorsements []SkillEndorsement
+	if err := h.DB.Preload("Endorser", selectPublicUser).Where("skill_id = ?", skill.ID).Order("created_at DESC").Find(&endorsements).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve endorsements.", err))
+		return
+	}
+	c.JSON(http.StatusOK, endorsements)
+}
+
+// AssessSkillGinHandler records a verified coach's rating of a skill. A later
+// assessment replaces the previous one.
+func (h *DBHandler) AssessSkillGinHandler(c *gin.Context) {
+	var input AssessSkill
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	skill, err := requireSkill(h.DB, c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	user, err := requireRole(c, h.DB, RoleCoach)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if !user.IsVerified {
+		api_errors.Abort(c, api_errors.Forbidden(api_errors.CodeForbidden, "Only verified coaches can assess skills."))
+		return
+	}
+	if skill.Profile.UserID == user.ID {
+		api_errors.Abort(c, api_errors.Forbidden(api_errors.CodeForbidden, "You cannot assess your own skills."))
+		return
+	}
+
+	now := time.Now()
+	if err := h.DB.Model(&skill).Updates(map[string]any{
+		"coach_rating":      input.Rating,
+		"coach_rated_by_id": user.ID,
+		"coach_rated_at":    now,
+	}).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to assess skill.", err))
+		return
+	}
+	notifyProfileWebhooks(h.DB, WebhookProfileUpdated, skill.ProfileID)
+	skill.CoachRating = &input.Rating
+	skill.CoachRatedByID = &user.ID
+	skill.CoachRatedAt = &now
+	skill.Profile.RedactMinor()
+	c.JSON(http.StatusOK, skill)
+}
+
+// MigrateSkills maps free-text skill names onto the catalogue and converts
+// their Level into a self rating. Unmapped names are logged and kept; an
+// empty code marks them so they are not looked at again on every start.
+func MigrateSkills(db *gorm.DB) error {
+	var skills []Skill
+	return db.Where("code IS NULL").FindInBatches(&skills, 200, func(tx *gorm.DB, batch int) error {
+		for _, skill := range skills {
+			code, ok := NormalizeSkill(skill.Name)
+			if !ok {
+				log.Printf("Skill migration: skill %d has unmapped name %q", skill.ID, skill.Name)
+				if err := db.Model(&Skill{}).Where("id = ?", skill.ID).Update("code", "").Error; err != nil {
+					return err
+				}
+				continue
+			}
+			info, _ := LookupSkill(code)
+			updates := map[string]any{"code": code, "name": info.Name}
+			if rating, ok := ParseSkillLevel(skill.Level); ok && skill.SelfRating == nil {
+				updates["self_rating"] = rating
+			}
+			if err := db.Model(&Skill{}).Where("id = ?", skill.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
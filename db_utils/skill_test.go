@@ -0,0 +1,42 @@
+package db_utils
+
+import "testing"
+
+func TestParseSkillLevel(t *testing.T) {
+	tests := []struct {
+		level  string
+		want   int
+		wantOK bool
+	}{
+		{"Advanced", 7, true},
+		{"  very good ", 8, true},
+		{"ELITE", 10, true},
+		{"7", 7, true},
+		{"10", 10, true},
+		{"0", 0, false},
+		{"11", 0, false},
+		{"-3", 0, false},
+		{"7/10", 7, true},
+		{"7 / 10", 7, true},
+		{"4/5", 8, true},
+		{"3/4", 8, true},
+		{"1/3", 3, true},
+		{"5/5", 10, true},
+		{"0/5", 0, false},
+		{"6/5", 0, false},
+		{"5/0", 0, false},
+		{"5/-5", 0, false},
+		{"x/10", 0, false},
+		{"7/ten", 0, false},
+		{"", 0, false},
+		{"great", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			got, ok := ParseSkillLevel(tt.level)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseSkillLevel(%q) = %d, %v, want %d, %v", tt.level, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
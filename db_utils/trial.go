@@ -0,0 +1,663 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TrialScheduled = "scheduled"
+	TrialCancelled = "cancelled"
+)
+
+// Trial application statuses. Clubs move applications forward through
+// invited, attended and offered; they may reject one at any of those steps,
+// and players may withdraw until they have attended.
+const (
+	ApplicationApplied   = "applied"
+	ApplicationInvited   = "invited"
+	ApplicationAttended  = "attended"
+	ApplicationOffered   = "offered"
+	ApplicationRejected  = "rejected"
+	ApplicationWithdrawn = "withdrawn"
+)
+
+var applicationTransitions = map[string][]string{
+	ApplicationApplied:  {ApplicationInvited, ApplicationRejected},
+	ApplicationInvited:  {ApplicationAttended, ApplicationRejected},
+	ApplicationAttended: {ApplicationOffered, ApplicationRejected},
+}
+
+// applicationsTakingPlaces are the statuses that count against a trial's
+// capacity.
+var applicationsTakingPlaces = []string{ApplicationInvited, ApplicationAttended, ApplicationOffered}
+
+// AgeGroupSenior is open to every player old enough for adult football.
+const (
+	AgeGroupSenior    = "senior"
+	SeniorMinimumAge  = 16
+	maxAgeGroupNumber = 23
+)
+
+var ageGroupPattern = regexp.MustCompile(`^[uU](\d{1,2})$`)
+
+// NormalizeAgeGroup returns the canonical form of an age group such as
+// "u16" or "Senior", and whether it is valid. "U16" is for players under 16
+// on the day of the trial.
+func NormalizeAgeGroup(group string) (string, bool) {
+	group = strings.TrimSpace(group)
+	if strings.EqualFold(group, AgeGroupSenior) {
+		return AgeGroupSenior, true
+	}
+	m := ageGroupPattern.FindStringSubmatch(group)
+	if m == nil {
+		return "", false
+	}
+	n, _ := strconv.Atoi(m[1])
+	if n < MinPlayerAge+1 || n > maxAgeGroupNumber {
+		return "", false
+	}
+	return "U" + m[1], true
+}
+
+// ageGroupAccepts reports whether a player of age fits group.
+func ageGroupAccepts(group string, age int) bool {
+	if group == AgeGroupSenior {
+		return age >= SeniorMinimumAge
+	}
+	n, _ := strconv.Atoi(strings.TrimPrefix(group, "U"))
+	return age < n
+}
+
+// TrialEvent is an open trial run by a club. Capacity caps how many players
+// can be invited; 0 means no limit.
+type TrialEvent struct {
+	gorm.Model
+
+	ClubID              uint           `gorm:"not null;index" json:"club_id"`
+	Club                *Club          `json:"club,omitempty"`
+	CreatedByID         uint           `gorm:"not null" json:"created_by_id"`
+	Title               string         `gorm:"size:150;not null" json:"title"`
+	Description         string         `gorm:"type:text" json:"description"`
+	StartsAt            time.Time      `gorm:"not null;index" json:"starts_at"`
+	EndsAt              *time.Time     `json:"ends_at"`
+	TimeZone            string         `gorm:"size:64" json:"time_zone"`
+	Venue               string         `gorm:"size:200;not null" json:"venue"`
+	Positions           pq.StringArray `gorm:"type:text[]" json:"positions"`
+	AgeGroups           pq.StringArray `gorm:"type:text[]" json:"age_groups"`
+	Capacity            int            `gorm:"not null;default:0" json:"capacity"`
+	ApplicationDeadline *time.Time     `json:"application_deadline"`
+	Status              string         `gorm:"size:10;not null;default:'scheduled'" json:"status"`
+	ApplicationCount    int64          `gorm:"-" json:"application_count"`
+}
+
+// TrialApplication is a player's application to a trial with their profile.
+type TrialApplication struct {
+	gorm.Model
+
+	TrialEventID    uint        `gorm:"not null;uniqueIndex:idx_trial_application" json:"trial_event_id"`
+	TrialEvent      *TrialEvent `json:"trial_event,omitempty"`
+	ProfileID       uint        `gorm:"not null;uniqueIndex:idx_trial_application;index" json:"profile_id"`
+	Profile         *Profile    `json:"profile,omitempty"`
+	Status          string      `gorm:"size:10;not null;default:'applied';index" json:"status"`
+	Message         string      `gorm:"size:2000" json:"message"`
+	StatusNote      string      `gorm:"size:500" json:"status_note"`
+	StatusChangedAt time.Time   `json:"status_changed_at"`
+}
+
+type SaveTrialEvent struct {
+	ClubID              uint       `json:"club_id" binding:"required"`
+	Title               string     `json:"title" binding:"required,max=150"`
+	Description         string     `json:"description" binding:"max=5000"`
+	StartsAt            time.Time  `json:"starts_at" binding:"required"`
+	EndsAt              *time.Time `json:"ends_at" binding:"omitempty,gtfield=StartsAt"`
+	TimeZone            string     `json:"time_zone" binding:"omitempty,timezone"`
+	Venue               string     `json:"venue" binding:"required,max=200"`
+	Positions           []string   `json:"positions" binding:"omitempty,max=15,dive,position"`
+	AgeGroups           []string   `json:"age_groups" binding:"omitempty,max=10,dive,age_group"`
+	Capacity            int        `json:"capacity" binding:"gte=0,lte=1000"`
+	ApplicationDeadline *time.Time `json:"application_deadline" binding:"omitempty,ltefield=StartsAt"`
+}
+
+type TrialEventsQuery struct {
+	ClubID   uint   `form:"club_id" json:"club_id,omitempty"`
+	Position string `form:"position" json:"position,omitempty" binding:"omitempty,position"`
+	AgeGroup string `form:"age_group" json:"age_group,omitempty" binding:"omitempty,age_group"`
+	Past     bool   `form:"past" json:"past,omitempty"`
+}
+
+type ApplyToTrial struct {
+	ProfileID uint   `json:"profile_id" binding:"required"`
+	Message   string `json:"message" binding:"max=2000"`
+}
+
+type UpdateApplicationStatus struct {
+	Status string `json:"status" binding:"required,oneof=invited attended offered rejected"`
+	Note   string `json:"note" binding:"max=500"`
+}
+
+type TrialApplicationsQuery struct {
+	Status string `form:"status" json:"status,omitempty" binding:"omitempty,oneof=applied invited attended offered rejected withdrawn"`
+}
+
+func requireTrialEvent(db *gorm.DB, idParam string) (*TrialEvent, error) {
+	eventID, err := strconv.Atoi(idParam)
+	if err != nil {
+		return nil, api_errors.InvalidID("id")
+	}
+	var event TrialEvent
+	err = db.Preload("Club").First(&event, eventID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, api_errors.NotFound(api_errors.CodeTrialNotFound, "Trial not found.")
+	}
+	if err != nil {
+		return nil, api_errors.Internal("Could not retrieve trial.", err)
+	}
+	return &event, nil
+}
+
+// requireTrialOrganiser loads the current user and checks that they are an
+// admin of clubID.
+func requireTrialOrganiser(c *gin.Context, db *gorm.DB, clubID uint) (*User, error) {
+	user, err := CurrentUser(c, db)
+	if err != nil {
+		return nil, err
+	}
+	isAdmin, err := IsClubAdmin(db, user, clubID)
+	if err != nil {
+		return nil, api_errors.Internal("Could not check club admins.", err)
+	}
+	if !isAdmin {
+		return nil, api_errors.Forbidden(api_errors.CodeForbidden, "Only the club's admins can manage its trials.")
+	}
+	return user, nil
+}
+
+func (input SaveTrialEvent) apply(event *TrialEvent) {
+	event.ClubID = input.ClubID
+	event.Title = input.Title
+	event.Description = input.Description
+	event.StartsAt = input.StartsAt
+	event.EndsAt = input.EndsAt
+	event.TimeZone = input.TimeZone
+	event.Venue = input.Venue
+	event.Capacity = input.Capacity
+	event.ApplicationDeadline = input.ApplicationDeadline
+	event.Positions = pq.StringArray{}
+	for _, position := range input.Positions {
+		code, _ := NormalizePosition(position)
+		if !slices.Contains(event.Positions, code) {
+			event.Positions = append(event.Positions, code)
+		}
+	}
+	event.AgeGroups = pq.StringArray{}
+	for _, group := range input.AgeGroups {
+		group, _ = NormalizeAgeGroup(group)
+		if !slices.Contains(event.AgeGroups, group) {
+			event.AgeGroups = append(event.AgeGroups, group)
+		}
+	}
+}
+
+// notifyApplicant tells the player behind application about a change to it,
+// in-app and by e-mail.
+func notifyApplicant(db *gorm.DB, application *TrialApplication, event *TrialEvent, title string) {
+	var profile Profile
+	if err := db.Select("id", "user_id").First(&profile, application.ProfileID).Error; err != nil {
+		log.Printf("Could not notify applicant %d: %v", application.ID, err)
+		return
+	}
+	body := fmt.Sprintf("%s\n\n%s, %s at %s.", title, event.Title, event.StartsAt.Format("Monday 2 January 2006 15:04"), event.Venue)
+	if application.StatusNote != "" {
+		body += "\n\n" + application.StatusNote
+	}
+	notification := Notification{
+		Type:  NotificationTrialUpdate,
+		Title: title,
+		Body:  body,
+		Data:  map[string]any{"trial_event_id": event.ID, "application_id": application.ID, "status": application.Status},
+	}
+	if err := Notify(db, profile.UserID, notification, true, true); err != nil {
+		log.Printf("Could not notify applicant %d: %v", application.ID, err)
+	}
+}
+
+func (h *DBHandler) CreateTrialEventGinHandler(c *gin.Context) {
+	var input SaveTrialEvent
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	if _, err := requireClub(h.DB, input.ClubID); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	user, err := requireTrialOrganiser(c, h.DB, input.ClubID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	event := TrialEvent{CreatedByID: user.ID, Status: TrialScheduled}
+	input.apply(&event)
+	if err := h.DB.Create(&event).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to create trial.", err))
+		return
+	}
+	c.JSON(http.StatusCreated, event)
+}
+
+// GetTrialEventsGinHandler lists scheduled trials, soonest first. Past trials
+// are only listed when asked for.
+func (h *DBHandler) GetTrialEventsGinHandler(c *gin.Context) {
+	var query TrialEventsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	db := h.DB.Preload("Club").Where("status = ?", TrialScheduled)
+	if query.Past {
+		db = db.Where("starts_at < ?", time.Now()).Order("starts_at DESC")
+	} else {
+		db = db.Where("starts_at >= ?", time.Now()).Order("starts_at")
+	}
+	if query.ClubID != 0 {
+		db = db.Where("club_id = ?", query.ClubID)
+	}
+	// Trials that do not name positions or age groups are open to everyone.
+	if code, ok := NormalizePosition(query.Position); ok {
+		db = db.Where("cardinality(positions) = 0 OR ? = ANY(positions)", code)
+	}
+	if group, ok := NormalizeAgeGroup(query.AgeGroup); ok {
+		db = db.Where("cardinality(age_groups) = 0 OR ? = ANY(age_groups)", group)
+	}
+	var events []TrialEvent
+	if err := db.Find(&events).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve trials.", err))
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+func (h *DBHandler) GetTrialEventGinHandler(c *gin.Context) {
+	event, err := requireTrialEvent(h.DB, c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if err := h.DB.Model(&TrialApplication{}).Where("trial_event_id = ? AND status <> ?", event.ID, ApplicationWithdrawn).
+		Count(&event.ApplicationCount).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not count applications.", err))
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+func (h *DBHandler) UpdateTrialEventGinHandler(c *gin.Context) {
+	var input SaveTrialEvent
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	event, err := requireTrialEvent(h.DB, c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if _, err := requireTrialOrganiser(c, h.DB, event.ClubID); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if input.ClubID != event.ClubID {
+		api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("club_id", "eq", strconv.FormatUint(uint64(event.ClubID), 10))))
+		return
+	}
+	if event.Status == TrialCancelled {
+		api_errors.Abort(c, api_errors.Conflict(api_errors.CodeTrialClosed, "This trial has been cancelled."))
+		return
+	}
+
+	input.apply(event)
+	event.Club = nil
+	if err := h.DB.Save(event).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to update trial.", err))
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+// CancelTrialEventGinHandler cancels a trial. It stays visible to the players
+// who applied, who are notified.
+func (h *DBHandler) CancelTrialEventGinHandler(c *gin.Context) {
+	event, err := requireTrialEvent(h.DB, c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if _, err := requireTrialOrganiser(c, h.DB, event.ClubID); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if event.Status == TrialCancelled {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if err := h.DB.Model(event).Update("status", TrialCancelled).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to cancel trial.", err))
+		return
+	}
+
+	var applications []TrialApplication
+	if err := h.DB.Where("trial_event_id = ? AND status IN ?", event.ID, []string{ApplicationApplied, ApplicationInvited}).
+		Find(&applications).Error; err != nil {
+		log.Printf("Could not load applicants of cancelled trial %d: %v", event.ID, err)
+	}
+	for i := range applications {
+		notifyApplicant(h.DB, &applications[i], event, "A trial you applied to has been cancelled")
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ApplyToTrialGinHandler applies to a trial with one of the current user's
+// profiles. The player must fit one of the trial's age groups on its date.
+func (h *DBHandler) ApplyToTrialGinHandler(c *gin.Context) {
+	var input ApplyToTrial
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	event, err := requireTrialEvent(h.DB, c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	profile, err := requireOwnProfile(c, h.DB, input.ProfileID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	now := time.Now()
+	deadline := event.StartsAt
+	if event.ApplicationDeadline != nil {
+		deadline = *event.ApplicationDeadline
+	}
+	if event.Status == TrialCancelled || now.After(deadline) {
+		api_errors.Abort(c, api_errors.Conflict(api_errors.CodeTrialClosed, "Applications for this trial are closed."))
+		return
+	}
+	if len(event.AgeGroups) > 0 && !profile.Dob.IsZero() {
+		age := utils.AgeOn(profile.Dob, event.StartsAt)
+		if !slices.ContainsFunc(event.AgeGroups, func(group string) bool { return ageGroupAccepts(group, age) }) {
+			api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("profile_id", "age_group_fit", strings.Join(event.AgeGroups, ", "))))
+			return
+		}
+	}
+
+	var existing int64
+	if err := h.DB.Model(&TrialApplication{}).Where("trial_event_id = ? AND profile_id = ?", event.ID, profile.ID).
+		Count(&existing).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not check existing applications.", err))
+		return
+	}
+	if existing > 0 {
+		api_errors.Abort(c, api_errors.Conflict(api_errors.CodeAlreadyApplied, "You have already applied to this trial."))
+		return
+	}
+
+	application := TrialApplication{
+		TrialEventID:    event.ID,
+		ProfileID:       profile.ID,
+		Status:          ApplicationApplied,
+		Message:         input.Message,
+		StatusChangedAt: now,
+	}
+	if err := h.DB.Create(&application).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to apply to trial.", err))
+		return
+	}
+	application.TrialEvent = event
+	c.JSON(http.StatusCreated, application)
+}
+
+// GetTrialApplicationsGinHandler lists the applicants of a trial with their
+// profiles, for the club's admins.
+func (h *DBHandler) GetTrialApplicationsGinHandler(c *gin.Context) {
+	var query TrialApplicationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	event, err := requireTrialEvent(h.DB, c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if _, err := requireTrialOrganiser(c, h.DB, event.ClubID); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	db := h.DB.Preload("Profile").Preload("Profile.Positions").Where("trial_event_id = ?", event.ID)
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	var applications []TrialApplication
+	if err := db.Order("created_at").Find(&applications).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve applications.", err))
+		return
+	}
+	for i := range applications {
+		applications[i].Profile.RedactMinor()
+	}
+	c.JSON(http.StatusOK, applications)
+}
+
+func requireTrialApplication(db *gorm.DB, idParam string) (*TrialApplication, error) {
+	applicationID, err := strconv.Atoi(idParam)
+	if err != nil {
+		return nil, api_errors.InvalidID("id")
+	}
+	var application TrialApplication
+	err = db.Preload("TrialEvent").First(&application, applicationID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, api_errors.NotFound(api_errors.CodeApplicationNotFound, "Trial application not found.")
+	}
+	if err != nil {
+		return nil, api_errors.Internal("Could not retrieve trial application.", err)
+	}
+	return &application, nil
+}
+
+// UpdateApplicationStatusGinHandler moves an application through the
+// workflow. Inviting is refused once the trial's capacity is taken.
+func (h *DBHandler) UpdateApplicationStatusGinHandler(c *gin.Context) {
+	var input UpdateApplicationStatus
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	application, err := requireTrialApplication(h.DB, c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	event := application.TrialEvent
+	if _, err := requireTrialOrganiser(c, h.DB, event.ClubID); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if !slices.Contains(applicationTransitions[application.Status], input.Status) {
+		api_errors.Abort(c, api_errors.Conflict(api_errors.CodeInvalidTransition,
+			fmt.Sprintf("An application that is %s cannot become %s.", application.Status, input.Status)))
+		return
+	}
+
+	application.Status = input.Status
+	application.StatusNote = input.Note
+	application.StatusChangedAt = time.Now()
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if input.Status == ApplicationInvited && event.Capacity > 0 {
+			// Locking the trial makes concurrent invites count one after the
+			// other, so the last place cannot be given out twice.
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&TrialEvent{}, event.ID).Error; err != nil {
+				return api_errors.Internal("Could not check the trial's capacity.", err)
+			}
+			var taken int64
+			if err := tx.Model(&TrialApplication{}).Where("trial_event_id = ? AND status IN ?", event.ID, applicationsTakingPlaces).
+				Count(&taken).Error; err != nil {
+				return api_errors.Internal("Could not check the trial's capacity.", err)
+			}
+			if taken >= int64(event.Capacity) {
+				return api_errors.Conflict(api_errors.CodeTrialFull, "Every place at this trial has been taken.")
+			}
+		}
+		if err := tx.Model(application).Updates(map[string]any{
+			"status": application.Status, "status_note": application.StatusNote, "status_changed_at": application.StatusChangedAt,
+		}).Error; err != nil {
+			return api_errors.Internal("Failed to update application.", err)
+		}
+		return nil
+	})
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	titles := map[string]string{
+		ApplicationInvited:  "You have been invited to a trial",
+		ApplicationAttended: "Thanks for attending the trial",
+		ApplicationOffered:  "You have received an offer after your trial",
+		ApplicationRejected: "Update on your trial application",
+	}
+	notifyApplicant(h.DB, application, event, titles[input.Status])
+	c.JSON(http.StatusOK, application)
+}
+
+func (h *DBHandler) WithdrawApplicationGinHandler(c *gin.Context) {
+	application, err := requireTrialApplication(h.DB, c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if _, err := requireOwnProfile(c, h.DB, application.ProfileID); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if application.Status != ApplicationApplied && application.Status != ApplicationInvited {
+		api_errors.Abort(c, api_errors.Conflict(api_errors.CodeInvalidTransition,
+			fmt.Sprintf("An application that is %s can no longer be withdrawn.", application.Status)))
+		return
+	}
+	application.Status = ApplicationWithdrawn
+	application.StatusChangedAt = time.Now()
+	if err := h.DB.Model(application).Updates(map[string]any{
+		"status": application.Status, "status_changed_at": application.StatusChangedAt,
+	}).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to withdraw application.", err))
+		return
+	}
+	c.JSON(http.StatusOK, application)
+}
+
+// PlayerTrialApplications returns the applications made with userID's
+// profiles, with their trials, soonest trial first.
+func PlayerTrialApplications(db *gorm.DB, userID uint) ([]TrialApplication, error) {
+	var applications []TrialApplication
+	err := db.Preload("TrialEvent").Preload("TrialEvent.Club").
+		Joins("JOIN trial_events ON trial_events.id = trial_applications.trial_event_id").
+		Where("trial_applications.profile_id IN (?)", db.Model(&Profile{}).Select("id").Where("user_id = ?", userID)).
+		Order("trial_events.starts_at").
+		Find(&applications).Error
+	return applications, err
+}
+
+func (h *DBHandler) GetMyTrialApplicationsGinHandler(c *gin.Context) {
+	applications, err := PlayerTrialApplications(h.DB, c.GetUint("userID"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve applications.", err))
+		return
+	}
+	c.JSON(http.StatusOK, applications)
+}
+
+// TrialCalendarEvents turns applications into calendar events. Withdrawn and
+// rejected applications, and cancelled trials, are shown as cancelled so
+// calendar clients remove them.
+func TrialCalendarEvents(applications []TrialApplication) []utils.CalendarEvent {
+	events := make([]utils.CalendarEvent, 0, len(applications))
+	for _, application := range applications {
+		trial := application.TrialEvent
+		if trial == nil {
+			continue
+		}
+		event := utils.CalendarEvent{
+			UID:      fmt.Sprintf("trial-%d-application-%d@ballerbio", trial.ID, application.ID),
+			Summary:  trial.Title,
+			Location: trial.Venue,
+			Start:    trial.StartsAt,
+			End:      trial.StartsAt.Add(2 * time.Hour),
+			Status:   utils.EventTentative,
+			Updated:  application.UpdatedAt,
+		}
+		if trial.EndsAt != nil {
+			event.End = *trial.EndsAt
+		}
+		if trial.Club != nil {
+			event.Summary = trial.Club.Name + ": " + trial.Title
+		}
+		event.Description = "Application status: " + application.Status
+		if trial.TimeZone != "" {
+			event.Description += "\nStarts: " + localTime(trial.StartsAt, trial.TimeZone)
+		}
+		if trial.Description != "" {
+			event.Description += "\n\n" + trial.Description
+		}
+		switch {
+		case trial.Status == TrialCancelled, application.Status == ApplicationWithdrawn, application.Status == ApplicationRejected:
+			event.Status = utils.EventCancelled
+		case application.Status != ApplicationApplied:
+			event.Status = utils.EventConfirmed
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// GetMyTrialCalendarGinHandler serves the trials the current user applied to
+// as an iCalendar file.
+func (h *DBHandler) GetMyTrialCalendarGinHandler(c *gin.Context) {
+	applications, err := PlayerTrialApplications(h.DB, c.GetUint("userID"))
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve applications.", err))
+		return
+	}
+	calendar := utils.Calendar{Name: "ballerbio trials", Events: TrialCalendarEvents(applications)}
+	c.Header("Content-Disposition", `attachment; filename="trials.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(calendar.String()))
+}
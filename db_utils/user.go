@@ -1,9 +1,12 @@
 package db_utils
 
 import (
+	"ballerbio/api_errors"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -13,11 +16,25 @@ import (
 	"os"
 )
 
+// User roles. Players, coaches and scouts pick their role when registering;
+// admins are appointed by other admins. IsVerified is granted by an admin.
+const (
+	RolePlayer    = "player"
+	RoleCoach     = "coach"
+	RoleScout     = "scout"
+	RoleClubAdmin = "club_admin"
+	RoleAdmin     = "admin"
+)
+
+var UserRoles = []string{RolePlayer, RoleCoach, RoleScout, RoleClubAdmin, RoleAdmin}
+
 type User struct {
 	gorm.Model
-	Username string `gorm:"unique;not null" json:"username"`
-	Password string `gorm:"not null" json:"-"`
-	Email    string `gorm:"unique;not null" json:"email"`
+	Username   string `gorm:"unique;not null" json:"username"`
+	Password   string `gorm:"not null" json:"-"`
+	Email      string `gorm:"unique;not null" json:"email,omitempty"`
+	Role       string `gorm:"size:20;not null;default:'player'" json:"role"`
+	IsVerified bool   `gorm:"default:false" json:"is_verified"`
 }
 
 type CreateUserInput struct {
@@ -25,6 +42,14 @@ type CreateUserInput struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
+	Role     string `json:"role" binding:"omitempty,oneof=player coach scout"`
+}
+
+// VerifyUser is an admin's decision on a user's role and verified status.
+type VerifyUser struct {
+	UserID     uint   `json:"user_id" binding:"required"`
+	Role       string `json:"role" binding:"omitempty,oneof=player coach scout club_admin admin"`
+	IsVerified bool   `json:"is_verified"`
 }
 
 func GetUserByID(db *gorm.DB, userID uint) *User {
@@ -50,6 +75,39 @@ func CreateUser(db *gorm.DB, user *User) error {
 	return result.Error
 }
 
+// CurrentUser loads the user behind the token of an authorized request.
+func CurrentUser(c *gin.Context, db *gorm.DB) (*User, error) {
+	user := GetUserByID(db, c.GetUint("userID"))
+	if user == nil || user.ID == 0 {
+		return nil, api_errors.Unauthorized(api_errors.CodeInvalidToken, "Token does not belong to an existing user.")
+	}
+	return user, nil
+}
+
+// requireRole loads the current user and rejects the request unless they have
+// one of roles. Admins pass every role check.
+func requireRole(c *gin.Context, db *gorm.DB, roles ...string) (*User, error) {
+	user, err := CurrentUser(c, db)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == RoleAdmin || slices.Contains(roles, user.Role) {
+		return user, nil
+	}
+	return nil, api_errors.Forbidden(api_errors.CodeForbidden, "This action requires the "+strings.Join(roles, " or ")+" role.")
+}
+
+type LoginUserInput struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type LoginResponse struct {
+	Message string `json:"message"`
+	User    *User  `json:"user"`
+	Token   string `json:"token"`
+}
+
 type JWTClaims struct {
 	jwt.RegisteredClaims
 	UserID uint `json:"user_id"`
@@ -66,23 +124,20 @@ func (h *DBHandler) LoginUserGinHandler(c *gin.Context) {
 
 	var jwtKey = []byte(os.Getenv("SECRET_KEY")) 
 
-	var input struct {
-		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required"`
-	}
+	var input LoginUserInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		log.Printf("JSON binding error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		api_errors.Abort(c, api_errors.Validation(err))
 		return
 	}
 	user := GetUserByEmail(h.DB, input.Email)
-	if user == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password."})
+	if user == nil || user.ID == 0 {
+		api_errors.Abort(c, api_errors.Unauthorized(api_errors.CodeInvalidCredentials, "Invalid email or password."))
 		return
 	}
 	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password."})
+		api_errors.Abort(c, api_errors.Unauthorized(api_errors.CodeInvalidCredentials, "Invalid email or password."))
 		return
 	}
 
@@ -92,7 +147,11 @@ func (h *DBHandler) LoginUserGinHandler(c *gin.Context) {
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	token_key, err := token.SignedString(jwtKey)
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful.", "user": user, "token": token_key})
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not issue token.", err))
+		return
+	}
+	c.JSON(http.StatusOK, LoginResponse{Message: "Login successful.", User: user, Token: token_key})
 }
 
 func (h *DBHandler) CreateUserGinHandler(c *gin.Context) {
@@ -102,48 +161,40 @@ func (h *DBHandler) CreateUserGinHandler(c *gin.Context) {
 	if err := c.ShouldBindJSON(&input); err != nil {
 		// Log the error for debugging
 		log.Printf("JSON binding error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	if input.Email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is required and must not be empty."})
+		api_errors.Abort(c, api_errors.Validation(err))
 		return
 	}
 
 	check_if_user_exists := GetUserByEmail(h.DB, input.Email)
-	// if err != nil {
-	// 	log.Printf("Error checking user existence: %v", err)
-	// 	c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify user."})
-	// 	return
-	// }
-
-	if check_if_user_exists.ID != 0 && check_if_user_exists != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User with given Email already exists."})
+	if check_if_user_exists != nil && check_if_user_exists.ID != 0 {
+		api_errors.Abort(c, api_errors.Conflict(api_errors.CodeUserAlreadyExists, "User with given Email already exists."))
 		return
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
-		log.Printf("Password hashing error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password."})
+		api_errors.Abort(c, api_errors.Internal("Failed to process password.", err))
 		return
 	}
 
+	role := input.Role
+	if role == "" {
+		role = RolePlayer
+	}
 	user := User{
 		Username: input.Username,
 		Password: string(hashedPassword),
 		Email:    input.Email,
+		Role:     role,
 	}
 
 	// 3. Call the database function
 	if err := CreateUser(h.DB, &user); err != nil {
-		log.Printf("Database create error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create profile."})
+		api_errors.Abort(c, api_errors.Internal("Failed to create user.", err))
 		return
 	}
 
-	// 4. Respond with the newly created profile (including the new ID)
+	// 4. Respond with the newly created user (including the new ID)
 	c.JSON(http.StatusCreated, user)
 }
 
@@ -151,18 +202,50 @@ func (h *DBHandler) GetUserByIDGinHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	userID, err := strconv.Atoi(idParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid user ID format.",
-		})
+		api_errors.Abort(c, api_errors.InvalidID("id"))
 		return
 	}
-	user := GetUserByID(h.DB, uint(userID))
+	// Only the user themselves may see their e-mail address.
+	db := h.DB
+	if uint(userID) != c.GetUint("userID") {
+		db = db.Scopes(selectPublicUser)
+	}
+	user := GetUserByID(db, uint(userID))
 
-	if user == nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "User not found.",
-		})
+	if user == nil || user.ID == 0 {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeUserNotFound, "User not found."))
 		return
 	}		
 	c.JSON(http.StatusOK, user)
-}
\ No newline at end of file
+}
+
+// VerifyUserGinHandler lets an admin verify a user and change their role.
+func (h *DBHandler) VerifyUserGinHandler(c *gin.Context) {
+	var input VerifyUser
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	if _, err := requireRole(c, h.DB, RoleAdmin); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	user := GetUserByID(h.DB, input.UserID)
+	if user == nil || user.ID == 0 {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeUserNotFound, "User not found."))
+		return
+	}
+
+	updates := map[string]any{"is_verified": input.IsVerified}
+	if input.Role != "" {
+		updates["role"] = input.Role
+	}
+	if err := h.DB.Model(user).Updates(updates).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to update user.", err))
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
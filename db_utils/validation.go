@@ -0,0 +1,322 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/utils"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Plausibility limits for amateur football data. They are deliberately loose:
+// the goal is to catch typos and unit mix-ups, not to police outliers.
+const (
+	MinPlayerAge            = 5
+	MaxPlayerAge            = 60
+	MaxGoalsPerAppearance   = 10
+	MaxMinutesPerAppearance = 130
+	MaxYellowsPerAppearance = 2
+	MinHeightCm             = 100
+	MaxHeightCm             = 230
+	MinWeightKg             = 30
+	MaxWeightKg             = 150
+)
+
+var seasonPattern = regexp.MustCompile(`^(\d{4})(?:/(\d{2}))?$`)
+
+// IsValidSeason accepts split seasons ("2024/25") and calendar-year seasons
+// ("2024"). The second half of a split season must follow the first.
+func IsValidSeason(season string) bool {
+	m := seasonPattern.FindStringSubmatch(season)
+	if m == nil {
+		return false
+	}
+	if m[2] == "" {
+		return true
+	}
+	start, _ := strconv.Atoi(m[1])
+	end, _ := strconv.Atoi(m[2])
+	return (start+1)%100 == end
+}
+
+func timeValue(fl validator.FieldLevel) (time.Time, bool) {
+	t, ok := fl.Field().Interface().(time.Time)
+	return t, ok
+}
+
+func validateNotFuture(fl validator.FieldLevel) bool {
+	t, ok := timeValue(fl)
+	return ok && !t.After(time.Now())
+}
+
+func validateDob(fl validator.FieldLevel) bool {
+	dob, ok := timeValue(fl)
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	return !dob.After(now.AddDate(-MinPlayerAge, 0, 0)) && dob.After(now.AddDate(-MaxPlayerAge, 0, 0))
+}
+
+func validateSeason(fl validator.FieldLevel) bool {
+	return IsValidSeason(fl.Field().String())
+}
+
+func validatePosition(fl validator.FieldLevel) bool {
+	_, ok := NormalizePosition(fl.Field().String())
+	return ok
+}
+
+func validateBodyArea(fl validator.FieldLevel) bool {
+	_, ok := NormalizeBodyArea(fl.Field().String())
+	return ok
+}
+
+func validatePlayingStyle(fl validator.FieldLevel) bool {
+	return slices.Contains(PlayingStyles, fl.Field().String())
+}
+
+func validatePhysicalTest(fl validator.FieldLevel) bool {
+	_, ok := PhysicalTestUnits[fl.Field().String()]
+	return ok
+}
+
+func validateTestSource(fl validator.FieldLevel) bool {
+	return slices.Contains(PhysicalTestSources, fl.Field().String())
+}
+
+func validateSkill(fl validator.FieldLevel) bool {
+	_, ok := NormalizeSkill(fl.Field().String())
+	return ok
+}
+
+func validateSkillLevel(fl validator.FieldLevel) bool {
+	_, ok := ParseSkillLevel(fl.Field().String())
+	return ok
+}
+
+func validateAgeGroup(fl validator.FieldLevel) bool {
+	_, ok := NormalizeAgeGroup(fl.Field().String())
+	return ok
+}
+
+func validateContractType(fl validator.FieldLevel) bool {
+	return slices.Contains(ContractTypes, fl.Field().String())
+}
+
+func validateWebhookEvent(fl validator.FieldLevel) bool {
+	return slices.Contains(WebhookEvents, fl.Field().String())
+}
+
+// checkPerAppearance reports field when value exceeds limit times the number
+// of appearances. Missing values are skipped.
+func checkPerAppearance(sl validator.StructLevel, value *int32, appearances *int32, limit int64, field, structField string) {
+	if value == nil || appearances == nil {
+		return
+	}
+	if int64(*value) > int64(*appearances)*limit {
+		sl.ReportError(*value, field, structField, "per_appearance", strconv.FormatInt(limit, 10))
+	}
+}
+
+func checkDateOrder(sl validator.StructLevel, start, end *time.Time, endField, endStructField, startField string) {
+	if start != nil && end != nil && end.Before(*start) {
+		sl.ReportError(*end, endField, endStructField, "after", startField)
+	}
+}
+
+// checkMeasurements converts height and weight to centimetres and kilograms
+// before applying the plausibility ranges, so 71 in passes and 71 cm fails.
+func checkMeasurements(sl validator.StructLevel, height *float64, heightUnit string, weight *float64, weightUnit string) {
+	if height != nil {
+		cm := utils.HeightToCm(*height, heightUnit)
+		if cm < MinHeightCm || cm > MaxHeightCm {
+			sl.ReportError(*height, "height", "Height", "height_range", "")
+		}
+	}
+	if weight != nil {
+		kg := utils.WeightToKg(*weight, weightUnit)
+		if kg < MinWeightKg || kg > MaxWeightKg {
+			sl.ReportError(*weight, "weight", "Weight", "weight_range", "")
+		}
+	}
+}
+
+// checkNationalities reports more than one primary nationality.
+func checkNationalities(sl validator.StructLevel, nationalities []NationalityInput) {
+	primaries := 0
+	for _, n := range nationalities {
+		if n.IsPrimary {
+			primaries++
+		}
+	}
+	if primaries > 1 {
+		sl.ReportError(nationalities, "nationalities", "Nationalities", "single_primary", "")
+	}
+}
+
+func validateCreateProfileInput(sl validator.StructLevel) {
+	input := sl.Current().Interface().(CreateProfileInput)
+	if input.Height > 0 && input.Weight > 0 {
+		checkMeasurements(sl, &input.Height, input.HeightUnit, &input.Weight, input.WeightUnit)
+	}
+	checkNationalities(sl, input.Nationalities)
+}
+
+func validateUpdateNationalities(sl validator.StructLevel) {
+	checkNationalities(sl, sl.Current().Interface().(UpdateNationalities).Nationalities)
+}
+
+func validateUpdateProfileAttributes(sl validator.StructLevel) {
+	input := sl.Current().Interface().(UpdateProfileAttributes)
+	checkMeasurements(sl, input.Height, input.HeightUnit, input.Weight, input.WeightUnit)
+}
+
+func validateAddSkill(sl validator.StructLevel) {
+	input := sl.Current().Interface().(AddSkill)
+	if input.Skill == "" && input.Name == "" {
+		sl.ReportError(input.Skill, "skill", "Skill", "required", "")
+	}
+	if input.SelfRating == nil && input.Level == "" {
+		sl.ReportError(input.SelfRating, "self_rating", "SelfRating", "required", "")
+	}
+}
+
+func validateAddInjury(sl validator.StructLevel) {
+	input := sl.Current().Interface().(AddInjury)
+	checkDateOrder(sl, input.StartDate, input.EndDate, "end_date", "EndDate", "start_date")
+}
+
+func validateAddClubProfile(sl validator.StructLevel) {
+	input := sl.Current().Interface().(AddClubProfile)
+	checkDateOrder(sl, input.StartYear, input.EndYear, "end_year", "EndYear", "start_year")
+	checkDateOrder(sl, input.ContractStart, input.ContractEnd, "contract_end", "ContractEnd", "contract_start")
+	if input.IsPresentClub && input.EndYear != nil && input.EndYear.Before(time.Now()) {
+		sl.ReportError(*input.EndYear, "end_year", "EndYear", "present_club_ended", "")
+	}
+	checkPerAppearance(sl, input.ClubGoals, input.ClubAppearances, MaxGoalsPerAppearance, "club_goals", "ClubGoals")
+	checkPerAppearance(sl, input.ClubAssists, input.ClubAppearances, MaxGoalsPerAppearance, "club_assists", "ClubAssists")
+}
+
+func validateAddSeasonStat(sl validator.StructLevel) {
+	input := sl.Current().Interface().(AddSeasonStat)
+	checkPerAppearance(sl, input.Goals, input.Appearances, MaxGoalsPerAppearance, "goals", "Goals")
+	checkPerAppearance(sl, input.Assists, input.Appearances, MaxGoalsPerAppearance, "assists", "Assists")
+	checkPerAppearance(sl, input.MinutesPlayed, input.Appearances, MaxMinutesPerAppearance, "minutes_played", "MinutesPlayed")
+	checkPerAppearance(sl, input.YellowCards, input.Appearances, MaxYellowsPerAppearance, "yellow_cards", "YellowCards")
+	checkPerAppearance(sl, input.RedCards, input.Appearances, 1, "red_cards", "RedCards")
+}
+
+// validateProfileFilter requires a centre for radius searches.
+func validateProfileFilter(sl validator.StructLevel) {
+	filter := sl.Current().Interface().(ProfileFilter)
+	if filter.RadiusKm > 0 && filter.Near == "" && filter.Lat == nil {
+		sl.ReportError(filter.Near, "near", "Near", "required_with", "RadiusKm")
+	}
+}
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	v.RegisterValidation("notfuture", validateNotFuture)
+	v.RegisterValidation("dob", validateDob)
+	v.RegisterValidation("season", validateSeason)
+	v.RegisterValidation("contract_type", validateContractType)
+	v.RegisterValidation("position", validatePosition)
+	v.RegisterValidation("playing_style", validatePlayingStyle)
+	v.RegisterValidation("physical_test", validatePhysicalTest)
+	v.RegisterValidation("test_source", validateTestSource)
+	v.RegisterValidation("skill", validateSkill)
+	v.RegisterValidation("skill_level", validateSkillLevel)
+	v.RegisterValidation("age_group", validateAgeGroup)
+	v.RegisterValidation("body_area", validateBodyArea)
+	v.RegisterValidation("webhook_event", validateWebhookEvent)
+
+	v.RegisterStructValidation(validateCreateProfileInput, CreateProfileInput{})
+	v.RegisterStructValidation(validateUpdateProfileAttributes, UpdateProfileAttributes{})
+	v.RegisterStructValidation(validateAddSkill, AddSkill{})
+	v.RegisterStructValidation(validateAddInjury, AddInjury{})
+	v.RegisterStructValidation(validateAddClubProfile, AddClubProfile{})
+	v.RegisterStructValidation(validateAddSeasonStat, AddSeasonStat{})
+	v.RegisterStructValidation(validateProfileFilter, ProfileFilter{})
+	v.RegisterStructValidation(validateUpdateNationalities, UpdateNationalities{})
+
+	api_errors.RegisterMessage("iso3166_1_alpha2", func(string) string { return "must be an ISO 3166-1 alpha-2 country code such as GB" })
+	api_errors.RegisterMessage("place", func(string) string { return `must be a known place such as "Manchester" or "Valencia, Spain"` })
+	api_errors.RegisterMessage("notfuture", func(string) string { return "must not be in the future" })
+	api_errors.RegisterMessage("dob", func(string) string {
+		return "must give an age between " + strconv.Itoa(MinPlayerAge) + " and " + strconv.Itoa(MaxPlayerAge)
+	})
+	api_errors.RegisterMessage("season", func(string) string { return `must be a season like "2024/25" or "2024"` })
+	api_errors.RegisterMessage("contract_type", func(string) string {
+		return "must be one of: " + strings.Join(ContractTypes, ", ")
+	})
+	api_errors.RegisterMessage("position", func(string) string {
+		return "must be a known position such as GK, CB, CM or ST"
+	})
+	api_errors.RegisterMessage("playing_style", func(string) string {
+		return "must be one of: " + strings.Join(PlayingStyles, ", ")
+	})
+	api_errors.RegisterMessage("physical_test", func(string) string {
+		return "must be a known physical test such as sprint_30m or yo_yo_ir1"
+	})
+	api_errors.RegisterMessage("test_source", func(string) string {
+		return "must be one of: " + strings.Join(PhysicalTestSources, ", ")
+	})
+	api_errors.RegisterMessage("skill", func(string) string {
+		return "must be a skill from the catalogue such as dribbling, aerial_duels or passing_range"
+	})
+	api_errors.RegisterMessage("skill_level", func(string) string {
+		return fmt.Sprintf("must be a rating from %d to %d or a level such as Intermediate or Advanced", MinSkillRating, MaxSkillRating)
+	})
+	api_errors.RegisterMessage("country", func(string) string {
+		return "must be an ISO country code such as FR or GB-ENG, or a country name"
+	})
+	api_errors.RegisterMessage("single_primary", func(string) string { return "must mark at most one entry as primary" })
+	api_errors.RegisterMessage("club_linked", func(string) string {
+		return "must refer to a record linked to a club in the directory"
+	})
+	api_errors.RegisterMessage("permutation", func(string) string {
+		return "must list every entry of the shortlist exactly once"
+	})
+	api_errors.RegisterMessage("not_owner", func(string) string { return "must not be the owner of the shortlist" })
+	api_errors.RegisterMessage("not_self", func(string) string { return "must not be yourself" })
+	api_errors.RegisterMessage("age_group", func(string) string {
+		return fmt.Sprintf("must be an age group from U%d to U%d, or senior", MinPlayerAge+1, maxAgeGroupNumber)
+	})
+	api_errors.RegisterMessage("age_group_fit", func(p string) string { return "must belong to a player in one of the age groups " + p })
+	api_errors.RegisterMessage("body_area", func(string) string {
+		return "must be a body area from GET /injury/body-areas"
+	})
+	api_errors.RegisterMessage("height_range", func(string) string {
+		minIn, _ := utils.HeightFromCm(MinHeightCm, utils.UnitSystemImperial)
+		maxIn, _ := utils.HeightFromCm(MaxHeightCm, utils.UnitSystemImperial)
+		return fmt.Sprintf("must be between %d and %d cm (%.0f and %.0f in)", MinHeightCm, MaxHeightCm, minIn, maxIn)
+	})
+	api_errors.RegisterMessage("weight_range", func(string) string {
+		minLb, _ := utils.WeightFromKg(MinWeightKg, utils.UnitSystemImperial)
+		maxLb, _ := utils.WeightFromKg(MaxWeightKg, utils.UnitSystemImperial)
+		return fmt.Sprintf("must be between %d and %d kg (%.0f and %.0f lb)", MinWeightKg, MaxWeightKg, minLb, maxLb)
+	})
+	api_errors.RegisterMessage("after", func(p string) string { return "must not be before " + p })
+	api_errors.RegisterMessage("per_appearance", func(p string) string { return "must not exceed " + p + " per appearance" })
+	api_errors.RegisterMessage("present_club_ended", func(string) string {
+		return "cannot be in the past for the present club"
+	})
+	api_errors.RegisterMessage("unique_present_club", func(string) string {
+		return "profile already has a present club"
+	})
+	api_errors.RegisterMessage("webhook_event", func(string) string {
+		return "must be one of: " + strings.Join(WebhookEvents, ", ")
+	})
+	api_errors.RegisterMessage("max_webhooks", func(p string) string { return "cannot exceed " + p + " webhooks per account" })
+}
@@ -0,0 +1,378 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Verification is embedded in the records a club can confirm. VerifiedByName
+// keeps the verifier's username so the badge can be shown without a join.
+type Verification struct {
+	IsVerified     bool       `gorm:"default:false" json:"is_verified"`
+	VerifiedAt     *time.Time `json:"verified_at"`
+	VerifiedByID   *uint      `json:"verified_by_id"`
+	VerifiedByName string     `gorm:"size:100" json:"verified_by_name,omitempty"`
+	VerifiedClubID *uint      `json:"verified_club_id"`
+}
+
+// ClubAdmin links a user to a club they may verify records for.
+type ClubAdmin struct {
+	gorm.Model
+
+	UserID uint  `gorm:"not null;uniqueIndex:idx_club_admin" json:"user_id"`
+	User   *User `json:"user,omitempty"`
+	ClubID uint  `gorm:"not null;uniqueIndex:idx_club_admin" json:"club_id"`
+	Club   *Club `json:"club,omitempty"`
+}
+
+// Records a verification request can be about.
+const (
+	VerificationSubjectClubProfile = "club_profile"
+	VerificationSubjectSeasonStat  = "season_stat"
+)
+
+const (
+	VerificationPending   = "pending"
+	VerificationApproved  = "approved"
+	VerificationRejected  = "rejected"
+	VerificationCancelled = "cancelled"
+)
+
+// VerificationRequest asks a club to confirm a ClubProfile or SeasonStat.
+type VerificationRequest struct {
+	gorm.Model
+
+	ClubID         uint       `gorm:"not null;index" json:"club_id"`
+	Club           *Club      `json:"club,omitempty"`
+	ProfileID      uint       `gorm:"not null;index" json:"profile_id"`
+	RequesterID    uint       `gorm:"not null" json:"requester_id"`
+	SubjectType    string     `gorm:"size:20;not null;index:idx_verification_subject" json:"subject_type"`
+	SubjectID      uint       `gorm:"not null;index:idx_verification_subject" json:"subject_id"`
+	Status         string     `gorm:"size:20;not null;default:'pending';index" json:"status"`
+	RequestComment string     `gorm:"size:500" json:"request_comment"`
+	ReviewerID     *uint      `json:"reviewer_id"`
+	ReviewerName   string     `gorm:"size:100" json:"reviewer_name,omitempty"`
+	ReviewComment  string     `gorm:"size:500" json:"review_comment"`
+	ReviewedAt     *time.Time `json:"reviewed_at"`
+}
+
+type AddClubAdmin struct {
+	UserID uint `json:"user_id" binding:"required"`
+	ClubID uint `json:"club_id" binding:"required"`
+}
+
+type RequestVerification struct {
+	SubjectType string `json:"subject_type" binding:"required,oneof=club_profile season_stat"`
+	SubjectID   uint   `json:"subject_id" binding:"required"`
+	Comment     string `json:"comment" binding:"max=500"`
+}
+
+type ReviewVerification struct {
+	Decision string `json:"decision" binding:"required,oneof=approve reject"`
+	Comment  string `json:"comment" binding:"required_if=Decision reject,max=500"`
+}
+
+type VerificationQuery struct {
+	Status string `form:"status" json:"status,omitempty" binding:"omitempty,oneof=pending approved rejected cancelled"`
+}
+
+// verificationSubject is the record a request is about: its table, the club
+// it names and the profile it belongs to.
+type verificationSubject struct {
+	Model     any
+	ClubID    *uint
+	ProfileID uint
+	Verified  bool
+}
+
+func loadVerificationSubject(db *gorm.DB, subjectType string, subjectID uint) (verificationSubject, error) {
+	var subject verificationSubject
+	var err error
+	switch subjectType {
+	case VerificationSubjectClubProfile:
+		var clubProfile ClubProfile
+		err = db.First(&clubProfile, subjectID).Error
+		subject = verificationSubject{Model: &ClubProfile{}, ClubID: clubProfile.ClubID, ProfileID: derefUint(clubProfile.ProfileID), Verified: clubProfile.IsVerified}
+	case VerificationSubjectSeasonStat:
+		var seasonStat SeasonStat
+		err = db.First(&seasonStat, subjectID).Error
+		subject = verificationSubject{Model: &SeasonStat{}, ClubID: seasonStat.ClubID, ProfileID: seasonStat.ProfileID, Verified: seasonStat.IsVerified}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return subject, api_errors.NotFound(api_errors.CodeNotFound, "The record to verify does not exist.")
+	}
+	if err != nil {
+		return subject, api_errors.Internal("Could not retrieve the record to verify.", err)
+	}
+	return subject, nil
+}
+
+// IsClubAdmin reports whether user may verify records for clubID. Site
+// admins may act for every club.
+func IsClubAdmin(db *gorm.DB, user *User, clubID uint) (bool, error) {
+	if user.Role == RoleAdmin {
+		return true, nil
+	}
+	var count int64
+	err := db.Model(&ClubAdmin{}).Where("user_id = ? AND club_id = ?", user.ID, clubID).Count(&count).Error
+	return count > 0, err
+}
+
+// ApplyVerificationReview records the decision on request and, on approval,
+// marks the subject as verified, in one transaction.
+func ApplyVerificationReview(db *gorm.DB, request *VerificationRequest, reviewer *User, approve bool, comment string) error {
+	now := time.Now()
+	request.ReviewerID = &reviewer.ID
+	request.ReviewerName = reviewer.Username
+	request.ReviewComment = comment
+	request.ReviewedAt = &now
+	request.Status = VerificationRejected
+	if approve {
+		request.Status = VerificationApproved
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(request).Error; err != nil {
+			return err
+		}
+		if !approve {
+			return nil
+		}
+		subject, err := loadVerificationSubject(tx, request.SubjectType, request.SubjectID)
+		if err != nil {
+			return err
+		}
+		return tx.Model(subject.Model).Where("id = ?", request.SubjectID).Updates(map[string]any{
+			"is_verified":      true,
+			"verified_at":      now,
+			"verified_by_id":   reviewer.ID,
+			"verified_by_name": reviewer.Username,
+			"verified_club_id": request.ClubID,
+		}).Error
+	})
+}
+
+// AddClubAdminGinHandler lets a site admin link a user to a club.
+func (h *DBHandler) AddClubAdminGinHandler(c *gin.Context) {
+	var input AddClubAdmin
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	if _, err := requireRole(c, h.DB, RoleAdmin); err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	user := GetUserByID(h.DB, input.UserID)
+	if user == nil || user.ID == 0 {
+		api_errors.Abort(c, api_errors.NotFound(api_errors.CodeUserNotFound, "User not found."))
+		return
+	}
+	club, err := requireClub(h.DB, input.ClubID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	// The user keeps their role: a coach made club admin still assesses skills
+	// as a coach. Club rights come from the link alone, see IsClubAdmin.
+	clubAdmin := ClubAdmin{UserID: user.ID, ClubID: club.ID}
+	if err := h.DB.Where(clubAdmin).FirstOrCreate(&clubAdmin).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to add club admin.", err))
+		return
+	}
+	clubAdmin.User = user
+	clubAdmin.Club = club
+	c.JSON(http.StatusCreated, clubAdmin)
+}
+
+// RequestVerificationGinHandler lets a player ask the club named on one of
+// their club spells or season stats to confirm it.
+func (h *DBHandler) RequestVerificationGinHandler(c *gin.Context) {
+	var input RequestVerification
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	subject, err := loadVerificationSubject(h.DB, input.SubjectType, input.SubjectID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	profile, err := requireProfile(h.DB, subject.ProfileID)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	userID := c.GetUint("userID")
+	if profile.UserID != userID {
+		api_errors.Abort(c, api_errors.Forbidden(api_errors.CodeForbidden, "You can only request verification of your own records."))
+		return
+	}
+	if subject.ClubID == nil {
+		api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("subject_id", "club_linked", "")))
+		return
+	}
+	if subject.Verified {
+		api_errors.Abort(c, api_errors.Conflict(api_errors.CodeAlreadyVerified, "This record is already verified."))
+		return
+	}
+
+	var pending int64
+	if err := h.DB.Model(&VerificationRequest{}).
+		Where("subject_type = ? AND subject_id = ? AND status = ?", input.SubjectType, input.SubjectID, VerificationPending).
+		Count(&pending).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not check existing requests.", err))
+		return
+	}
+	if pending > 0 {
+		api_errors.Abort(c, api_errors.Conflict(api_errors.CodeRequestPending, "A verification request for this record is already pending."))
+		return
+	}
+
+	request := VerificationRequest{
+		ClubID:         *subject.ClubID,
+		ProfileID:      profile.ID,
+		RequesterID:    userID,
+		SubjectType:    input.SubjectType,
+		SubjectID:      input.SubjectID,
+		Status:         VerificationPending,
+		RequestComment: input.Comment,
+	}
+	if err := h.DB.Create(&request).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to create verification request.", err))
+		return
+	}
+	c.JSON(http.StatusCreated, request)
+}
+
+// GetVerificationRequestsGinHandler lists the requests the current user made
+// together with those addressed to the clubs they administer.
+func (h *DBHandler) GetVerificationRequestsGinHandler(c *gin.Context) {
+	var query VerificationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	user, err := CurrentUser(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	db := h.DB.Preload("Club")
+	if user.Role != RoleAdmin {
+		db = db.Where("requester_id = ? OR club_id IN (SELECT club_id FROM club_admins WHERE user_id = ? AND deleted_at IS NULL)", user.ID, user.ID)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	var requests []VerificationRequest
+	if err := db.Order("created_at DESC").Find(&requests).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve verification requests.", err))
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+func requireVerificationRequest(db *gorm.DB, idParam string) (*VerificationRequest, error) {
+	requestID, err := strconv.Atoi(idParam)
+	if err != nil {
+		return nil, api_errors.InvalidID("id")
+	}
+	var request VerificationRequest
+	err = db.Preload("Club").First(&request, requestID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, api_errors.NotFound(api_errors.CodeRequestNotFound, "Verification request not found.")
+	}
+	if err != nil {
+		return nil, api_errors.Internal("Could not retrieve verification request.", err)
+	}
+	return &request, nil
+}
+
+// ReviewVerificationGinHandler lets an admin of the request's club approve
+// or reject it. Rejections must say why.
+func (h *DBHandler) ReviewVerificationGinHandler(c *gin.Context) {
+	var input ReviewVerification
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	request, err := requireVerificationRequest(h.DB, c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	user, err := CurrentUser(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	allowed, err := IsClubAdmin(h.DB, user, request.ClubID)
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not check club permissions.", err))
+		return
+	}
+	if !allowed {
+		api_errors.Abort(c, api_errors.Forbidden(api_errors.CodeForbidden, "Only admins of this club can review the request."))
+		return
+	}
+	if request.Status != VerificationPending {
+		api_errors.Abort(c, api_errors.Conflict(api_errors.CodeRequestClosed, "This request has already been "+request.Status+"."))
+		return
+	}
+
+	if err := ApplyVerificationReview(h.DB, request, user, input.Decision == "approve", input.Comment); err != nil {
+		var apiErr *api_errors.Error
+		if !errors.As(err, &apiErr) {
+			err = api_errors.Internal("Failed to review verification request.", err)
+		}
+		api_errors.Abort(c, err)
+		return
+	}
+	if input.Decision == "approve" {
+		notifyProfileWebhooks(h.DB, WebhookProfileUpdated, request.ProfileID)
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// CancelVerificationGinHandler withdraws a pending request made by the
+// current user.
+func (h *DBHandler) CancelVerificationGinHandler(c *gin.Context) {
+	request, err := requireVerificationRequest(h.DB, c.Param("id"))
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if request.RequesterID != c.GetUint("userID") {
+		api_errors.Abort(c, api_errors.Forbidden(api_errors.CodeForbidden, "You can only cancel your own requests."))
+		return
+	}
+	if request.Status != VerificationPending {
+		api_errors.Abort(c, api_errors.Conflict(api_errors.CodeRequestClosed, "This request has already been "+request.Status+"."))
+		return
+	}
+	request.Status = VerificationCancelled
+	if err := h.DB.Model(request).Update("status", VerificationCancelled).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to cancel verification request.", err))
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
@@ -0,0 +1,737 @@
+package db_utils
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/utils"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Webhook event types. WebhookPing is only sent by the test endpoint.
+const (
+	WebhookProfileCreated   = "profile.created"
+	WebhookProfileUpdated   = "profile.updated"
+	WebhookProfileHidden    = "profile.hidden"
+	WebhookStatAdded        = "stat.added"
+	WebhookAchievementAdded = "achievement.added"
+	WebhookClubAdded        = "club.added"
+	WebhookHighlightAdded   = "highlight.added"
+	WebhookPing             = "ping"
+)
+
+// WebhookEvents lists the events a subscription can ask for.
+var WebhookEvents = []string{
+	WebhookProfileCreated,
+	WebhookProfileUpdated,
+	WebhookProfileHidden,
+	WebhookStatAdded,
+	WebhookAchievementAdded,
+	WebhookClubAdded,
+	WebhookHighlightAdded,
+}
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// webhookRetryDelays is the wait before each retry of a failed delivery. A
+// delivery is given up after the last one, about a day and a half later.
+var webhookRetryDelays = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+}
+
+const (
+	maxWebhookSubscriptions = 10
+	// webhookBatchSize bounds how many deliveries one run of the job sends.
+	webhookBatchSize = 100
+	// webhookClaimLease outlasts a run of the job: a full batch of receivers
+	// timing out takes under 17 minutes.
+	webhookClaimLease = 30 * time.Minute
+	// webhookLogRetention is how long finished deliveries stay in the log.
+	webhookLogRetention = 30 * 24 * time.Hour
+	// maxLoggedResponse is how much of a response body the log keeps.
+	maxLoggedResponse = 1024
+)
+
+// Headers sent with every delivery. The signature is
+// "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">", keyed with
+// the subscription's secret; receivers should also reject old timestamps.
+const (
+	WebhookEventHeader     = "X-Ballerbio-Event"
+	WebhookDeliveryHeader  = "X-Ballerbio-Delivery"
+	WebhookSignatureHeader = "X-Ballerbio-Signature"
+)
+
+// WebhookSubscription asks for events to be POSTed to URL. Without
+// ProfileIDs it receives the events of every public profile.
+type WebhookSubscription struct {
+	gorm.Model
+
+	UserID      uint           `gorm:"not null;index" json:"user_id"`
+	URL         string         `gorm:"size:500;not null" json:"url"`
+	Description string         `gorm:"size:200" json:"description"`
+	Events      pq.StringArray `gorm:"type:text[];not null" json:"events"`
+	ProfileIDs  pq.Int64Array  `gorm:"type:bigint[]" json:"profile_ids"`
+	Enabled     bool           `gorm:"not null;default:true" json:"enabled"`
+	Secret      string         `gorm:"size:100;not null" json:"-"`
+}
+
+// WebhookSubscriptionWithSecret is returned when a secret is created, the
+// only time it is shown.
+type WebhookSubscriptionWithSecret struct {
+	WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a subscription.
+// Payload is the request body exactly as it is signed and sent.
+type WebhookDelivery struct {
+	gorm.Model
+
+	SubscriptionID uint                 `gorm:"not null;index" json:"subscription_id"`
+	Subscription   *WebhookSubscription `json:"-"`
+	EventID        string               `gorm:"size:32;not null;index" json:"event_id"`
+	Event          string               `gorm:"size:40;not null" json:"event"`
+	Payload        string               `gorm:"type:text;not null" json:"payload"`
+	Status         string               `gorm:"size:10;not null;default:'pending';index" json:"status"`
+	Attempts       int                  `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  *time.Time           `gorm:"index" json:"next_attempt_at"`
+	LastAttemptAt  *time.Time           `json:"last_attempt_at"`
+	ResponseStatus int                  `json:"response_status,omitempty"`
+	ResponseBody   string               `gorm:"type:text" json:"response_body,omitempty"`
+	Error          string               `gorm:"size:500" json:"error,omitempty"`
+	DurationMs     int64                `json:"duration_ms,omitempty"`
+}
+
+// WebhookPayload is the body of every delivery. Data is the profile for
+// profile.created and profile.updated, and the added record for the other
+// events.
+type WebhookPayload struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	ProfileID uint      `json:"profile_id,omitempty"`
+	Data      any       `json:"data"`
+}
+
+type CreateWebhook struct {
+	URL         string   `json:"url" binding:"required,max=500,http_url"`
+	Description string   `json:"description" binding:"max=200"`
+	Events      []string `json:"events" binding:"required,min=1,unique,dive,webhook_event"`
+	ProfileIDs  []int64  `json:"profile_ids" binding:"omitempty,max=100,unique,dive,gt=0"`
+}
+
+// UpdateWebhook changes the fields that are present. An empty profile_ids
+// subscribes to every profile again.
+type UpdateWebhook struct {
+	URL          *string  `json:"url" binding:"omitempty,max=500,http_url"`
+	Description  *string  `json:"description" binding:"omitempty,max=200"`
+	Events       []string `json:"events" binding:"omitempty,min=1,unique,dive,webhook_event"`
+	ProfileIDs   []int64  `json:"profile_ids" binding:"omitempty,max=100,unique,dive,gt=0"`
+	Enabled      *bool    `json:"enabled"`
+	RotateSecret bool     `json:"rotate_secret"`
+}
+
+type WebhookDeliveriesQuery struct {
+	Status   string `form:"status" json:"status,omitempty" binding:"omitempty,oneof=pending succeeded failed"`
+	BeforeID uint   `form:"before_id" json:"before_id,omitempty"`
+	Limit    int    `form:"limit" json:"limit,omitempty" binding:"omitempty,gte=1,lte=100"`
+}
+
+func newWebhookSecret() (string, error) {
+	token, _, err := utils.NewToken()
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + token, nil
+}
+
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SignWebhook returns the signature header of a delivery of body at
+// timestamp.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// EnqueueWebhookEvent queues event of profileID for every enabled
+// subscription asking for it. data is only built when someone subscribed; it
+// returns nil to skip the event. Events of profiles that are not public are
+// never sent, except profile.hidden, which is only sent for those.
+func EnqueueWebhookEvent(db *gorm.DB, event string, profileID uint, data func() (any, error)) error {
+	if profileID == 0 {
+		return nil
+	}
+	// Hooks pass the statement that created the subject; start afresh.
+	db = db.Session(&gorm.Session{NewDB: true})
+	var subscriptions []WebhookSubscription
+	err := db.Select("id").
+		Where("enabled AND ? = ANY(events)", event).
+		Where("COALESCE(cardinality(profile_ids), 0) = 0 OR ? = ANY(profile_ids)", profileID).
+		Find(&subscriptions).Error
+	if err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&Profile{}).Scopes(PublicProfiles).Where("profiles.id = ?", profileID).Count(&count).Error; err != nil {
+		return err
+	}
+	if (count == 0) != (event == WebhookProfileHidden) {
+		return nil
+	}
+	payload, err := data()
+	if err != nil || payload == nil {
+		return err
+	}
+
+	eventID, err := newEventID()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(WebhookPayload{ID: eventID, Event: event, CreatedAt: time.Now().UTC(), ProfileID: profileID, Data: payload})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	deliveries := make([]WebhookDelivery, len(subscriptions))
+	for i, s := range subscriptions {
+		deliveries[i] = WebhookDelivery{
+			SubscriptionID: s.ID,
+			EventID:        eventID,
+			Event:          event,
+			Payload:        string(body),
+			Status:         DeliveryPending,
+			NextAttemptAt:  &now,
+		}
+	}
+	return db.Create(&deliveries).Error
+}
+
+// webhookRecord is v as a JSON object without its "profile" association,
+// which the payload carries as profile_id.
+func webhookRecord(v any) func() (any, error) {
+	return func() (any, error) {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var record map[string]any
+		if err := json.Unmarshal(b, &record); err != nil {
+			return nil, err
+		}
+		delete(record, "profile")
+		return record, nil
+	}
+}
+
+// webhookProfile loads the profile as GET /profiles/:id/:slug shows it.
+func webhookProfile(db *gorm.DB, profileID uint) func() (any, error) {
+	return func() (any, error) {
+		var profile Profile
+		err := db.
+			Preload("User", selectPublicUser).
+			Preload("Positions").
+			Preload("Nationalities", primaryNationalityFirst).
+			Preload("PhysicalTests").
+			Preload("Skills").
+			Preload("Achievements").
+			Preload("Injuries").
+			Preload("SocialLinks").
+			Preload("ClubProfiles").
+			Preload("SeasonStats").
+			First(&profile, profileID).Error
+		if err != nil {
+			return nil, err
+		}
+		profile.AnalyzeInjuries(time.Now())
+		profile.ContractExpiresAt = profile.CurrentContractEnd()
+		profile.RedactMinor()
+		return profile, nil
+	}
+}
+
+// notifyProfileWebhooks queues profile.created or profile.updated once the
+// profile has been saved. Failures are logged: the change itself went through.
+func notifyProfileWebhooks(db *gorm.DB, event string, profileID uint) {
+	if err := EnqueueWebhookEvent(db, event, profileID, webhookProfile(db, profileID)); err != nil {
+		log.Printf("Queueing %s for profile %d failed: %v", event, profileID, err)
+	}
+}
+
+// enqueueFromHook queues a webhook event from an AfterCreate hook. Failures
+// are logged like notifyProfileWebhooks does instead of rolling back the
+// player's write; the savepoint keeps a failed query from aborting the
+// surrounding transaction.
+func enqueueFromHook(tx *gorm.DB, event string, profileID uint, data func() (any, error)) {
+	tx = tx.Session(&gorm.Session{NewDB: true})
+	if err := tx.SavePoint("webhook_event").Error; err != nil {
+		log.Printf("Queueing %s for profile %d failed: %v", event, profileID, err)
+		return
+	}
+	if err := EnqueueWebhookEvent(tx, event, profileID, data); err != nil {
+		log.Printf("Queueing %s for profile %d failed: %v", event, profileID, err)
+		tx.RollbackTo("webhook_event")
+	}
+}
+
+// notifyProfileHidden queues profile.hidden once a profile has stopped being
+// public, so subscribers can drop what they copied. Failures are logged.
+func notifyProfileHidden(db *gorm.DB, profileID uint) {
+	data := func() (any, error) { return map[string]any{"profile_id": profileID}, nil }
+	if err := EnqueueWebhookEvent(db, WebhookProfileHidden, profileID, data); err != nil {
+		log.Printf("Queueing %s for profile %d failed: %v", WebhookProfileHidden, profileID, err)
+	}
+}
+
+// allowPrivateWebhookTargets lets deliveries reach loopback and private
+// addresses, for local development only.
+var allowPrivateWebhookTargets = os.Getenv("WEBHOOK_ALLOW_PRIVATE_TARGETS") == "true"
+
+// reservedNetworks are ranges not reachable on the public internet that the
+// net.IP predicates do not cover.
+var reservedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),     // "this" network
+	mustParseCIDR("100.64.0.0/10"), // carrier-grade NAT
+	mustParseCIDR("192.0.0.0/24"),  // IETF protocol assignments
+	mustParseCIDR("198.18.0.0/15"), // benchmarking
+	mustParseCIDR("240.0.0.0/4"),   // reserved, including broadcast
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// isPublicAddress reports whether ip can be a webhook receiver on the public
+// internet.
+func isPublicAddress(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// refusePrivateAddresses stops deliveries from reaching the internal network.
+// It runs on the resolved address, so a public name pointing inside is
+// refused too.
+func refusePrivateAddresses(network, address string, _ syscall.RawConn) error {
+	if allowPrivateWebhookTargets {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddress(net.ParseIP(host)) {
+		return fmt.Errorf("refusing to deliver to %s", host)
+	}
+	return nil
+}
+
+// webhookClient does not follow redirects: a receiver that moved should be
+// updated, and a redirect must not lead a delivery inside.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: refusePrivateAddresses}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConnsPerHost: 2,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// deliverWebhook sends delivery once and records the outcome. A failure is
+// retried later when retry is set and attempts remain. The returned error is
+// about recording the outcome, not about the receiver.
+func deliverWebhook(ctx context.Context, db *gorm.DB, delivery *WebhookDelivery, subscription *WebhookSubscription, retry bool) error {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.NextAttemptAt = nil
+	delivery.ResponseStatus = 0
+	delivery.ResponseBody = ""
+	delivery.Error = ""
+
+	switch {
+	case subscription == nil:
+		delivery.Error = "The subscription was deleted."
+		retry = false
+	case !subscription.Enabled:
+		delivery.Error = "The subscription is disabled."
+		retry = false
+	default:
+		status, body, err := postWebhook(ctx, subscription, delivery)
+		delivery.DurationMs = time.Since(now).Milliseconds()
+		delivery.ResponseStatus = status
+		delivery.ResponseBody = body
+		if err != nil {
+			delivery.Error = truncate(err.Error(), 500)
+		} else if status < 200 || status > 299 {
+			delivery.Error = fmt.Sprintf("The receiver answered %d.", status)
+		}
+	}
+
+	switch {
+	case delivery.Error == "":
+		delivery.Status = DeliverySucceeded
+	case retry && delivery.Attempts <= len(webhookRetryDelays):
+		delivery.Status = DeliveryPending
+		next := now.Add(webhookRetryDelays[delivery.Attempts-1])
+		delivery.NextAttemptAt = &next
+	default:
+		delivery.Status = DeliveryFailed
+	}
+	return db.Model(delivery).Select("Attempts", "LastAttemptAt", "NextAttemptAt", "Status", "ResponseStatus", "ResponseBody", "Error", "DurationMs").
+		Updates(delivery).Error
+}
+
+func postWebhook(ctx context.Context, subscription *WebhookSubscription, delivery *WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ballerbio-webhooks/1")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(subscription.Secret, time.Now().Unix(), body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedResponse))
+	return resp.StatusCode, string(excerpt), nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// claimWebhookDeliveries takes the deliveries that are due for this run. The
+// claim moves their next attempt past the lease in a single statement, so a
+// job running on another instance skips them; should this one die, they are
+// picked up again once the lease is over.
+func claimWebhookDeliveries(db *gorm.DB, now time.Time) ([]WebhookDelivery, error) {
+	due := db.Model(&WebhookDelivery{}).Select("id").
+		Where("status = ? AND next_attempt_at <= ?", DeliveryPending, now).
+		Order("next_attempt_at, id").Limit(webhookBatchSize).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+	var claimed []WebhookDelivery
+	if err := db.Model(&claimed).Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("id IN (?)", due).
+		Update("next_attempt_at", now.Add(webhookClaimLease)).Error; err != nil {
+		return nil, err
+	}
+	if len(claimed) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint, len(claimed))
+	for i, d := range claimed {
+		ids[i] = d.ID
+	}
+	var deliveries []WebhookDelivery
+	err := db.Preload("Subscription").Where("id IN ?", ids).Order("id").Find(&deliveries).Error
+	return deliveries, err
+}
+
+// RunWebhookDeliveries sends the deliveries that are due, first queued first,
+// and drops finished ones from the log once they are old.
+func RunWebhookDeliveries(ctx context.Context, db *gorm.DB) error {
+	now := time.Now()
+	if err := db.Unscoped().Where("status <> ? AND created_at < ?", DeliveryPending, now.Add(-webhookLogRetention)).
+		Delete(&WebhookDelivery{}).Error; err != nil {
+		return err
+	}
+
+	deliveries, err := claimWebhookDeliveries(db, now)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := deliverWebhook(ctx, db, &deliveries[i], deliveries[i].Subscription, true); err != nil {
+			log.Printf("Webhook delivery %d could not be recorded: %v", deliveries[i].ID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d webhook delivery(ies) could not be recorded", failed)
+	}
+	return nil
+}
+
+func requireWebhook(c *gin.Context, db *gorm.DB) (*WebhookSubscription, error) {
+	webhookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, api_errors.InvalidID("id")
+	}
+	var subscription WebhookSubscription
+	err = db.Where("user_id = ?", c.GetUint("userID")).First(&subscription, webhookID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, api_errors.NotFound(api_errors.CodeNotFound, "Webhook not found.")
+	}
+	if err != nil {
+		return nil, api_errors.Internal("Could not retrieve webhook.", err)
+	}
+	return &subscription, nil
+}
+
+func (h *DBHandler) CreateWebhookGinHandler(c *gin.Context) {
+	var input CreateWebhook
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	userID := c.GetUint("userID")
+	var count int64
+	if err := h.DB.Model(&WebhookSubscription{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not count webhooks.", err))
+		return
+	}
+	if count >= maxWebhookSubscriptions {
+		api_errors.Abort(c, api_errors.Invalid(api_errors.FieldErrorFor("url", "max_webhooks", strconv.Itoa(maxWebhookSubscriptions))))
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not create a secret.", err))
+		return
+	}
+	subscription := WebhookSubscription{
+		UserID:      userID,
+		URL:         input.URL,
+		Description: input.Description,
+		Events:      pq.StringArray(input.Events),
+		ProfileIDs:  pq.Int64Array(input.ProfileIDs),
+		Enabled:     true,
+		Secret:      secret,
+	}
+	if err := h.DB.Create(&subscription).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to create webhook.", err))
+		return
+	}
+	c.JSON(http.StatusCreated, WebhookSubscriptionWithSecret{WebhookSubscription: subscription, Secret: secret})
+}
+
+func (h *DBHandler) GetWebhooksGinHandler(c *gin.Context) {
+	subscriptions := []WebhookSubscription{}
+	if err := h.DB.Where("user_id = ?", c.GetUint("userID")).Order("created_at").Find(&subscriptions).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve webhooks.", err))
+		return
+	}
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+func (h *DBHandler) GetWebhookGinHandler(c *gin.Context) {
+	subscription, err := requireWebhook(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+// UpdateWebhookGinHandler changes a subscription. With rotate_secret the new
+// secret is returned, and the old one stops working straight away.
+func (h *DBHandler) UpdateWebhookGinHandler(c *gin.Context) {
+	var input UpdateWebhook
+
+	// 1. Bind JSON data to the input struct and validate required fields
+	if err := c.ShouldBindJSON(&input); err != nil {
+		log.Printf("JSON binding error: %v", err)
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	subscription, err := requireWebhook(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+
+	updates := map[string]any{}
+	if input.URL != nil {
+		updates["url"] = *input.URL
+	}
+	if input.Description != nil {
+		updates["description"] = *input.Description
+	}
+	if input.Events != nil {
+		updates["events"] = pq.StringArray(input.Events)
+	}
+	if input.ProfileIDs != nil {
+		updates["profile_ids"] = pq.Int64Array(input.ProfileIDs)
+	}
+	if input.Enabled != nil {
+		updates["enabled"] = *input.Enabled
+	}
+	secret := ""
+	if input.RotateSecret {
+		secret, err = newWebhookSecret()
+		if err != nil {
+			api_errors.Abort(c, api_errors.Internal("Could not create a secret.", err))
+			return
+		}
+		updates["secret"] = secret
+	}
+	if len(updates) > 0 {
+		if err := h.DB.Model(subscription).Updates(updates).Error; err != nil {
+			api_errors.Abort(c, api_errors.Internal("Failed to update webhook.", err))
+			return
+		}
+	}
+	if secret != "" {
+		c.JSON(http.StatusOK, WebhookSubscriptionWithSecret{WebhookSubscription: *subscription, Secret: secret})
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+// DeleteWebhookGinHandler removes a subscription. Its pending deliveries are
+// not sent.
+func (h *DBHandler) DeleteWebhookGinHandler(c *gin.Context) {
+	subscription, err := requireWebhook(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if err := h.DB.Delete(subscription).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to delete webhook.", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveriesGinHandler pages through the delivery log of a
+// subscription, newest first. Pass the smallest ID received as before_id to
+// fetch older deliveries.
+func (h *DBHandler) GetWebhookDeliveriesGinHandler(c *gin.Context) {
+	var query WebhookDeliveriesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api_errors.Abort(c, api_errors.Validation(err))
+		return
+	}
+	subscription, err := requireWebhook(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = 50
+	}
+
+	db := h.DB.Where("subscription_id = ?", subscription.ID)
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.BeforeID > 0 {
+		db = db.Where("id < ?", query.BeforeID)
+	}
+	deliveries := []WebhookDelivery{}
+	if err := db.Order("id DESC").Limit(query.Limit).Find(&deliveries).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not retrieve deliveries.", err))
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// PingWebhookGinHandler sends a ping event straight away, even to a disabled
+// subscription, and returns the logged delivery. Pings are not retried.
+func (h *DBHandler) PingWebhookGinHandler(c *gin.Context) {
+	subscription, err := requireWebhook(c, h.DB)
+	if err != nil {
+		api_errors.Abort(c, err)
+		return
+	}
+	eventID, err := newEventID()
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not create the ping.", err))
+		return
+	}
+	body, err := json.Marshal(WebhookPayload{
+		ID:        eventID,
+		Event:     WebhookPing,
+		CreatedAt: time.Now().UTC(),
+		Data:      map[string]any{"subscription_id": subscription.ID},
+	})
+	if err != nil {
+		api_errors.Abort(c, api_errors.Internal("Could not create the ping.", err))
+		return
+	}
+	// Without a next attempt the delivery job leaves the ping alone.
+	delivery := WebhookDelivery{SubscriptionID: subscription.ID, EventID: eventID, Event: WebhookPing, Payload: string(body), Status: DeliveryPending}
+	if err := h.DB.Create(&delivery).Error; err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to log the ping.", err))
+		return
+	}
+	target := *subscription
+	target.Enabled = true
+	if err := deliverWebhook(c.Request.Context(), h.DB, &delivery, &target, false); err != nil {
+		api_errors.Abort(c, api_errors.Internal("Failed to log the ping.", err))
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
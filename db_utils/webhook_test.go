@@ -0,0 +1,85 @@
+package db_utils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSignWebhook(t *testing.T) {
+	// Expected values computed independently with Python's hmac module.
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+		want      string
+	}{
+		{
+			name:      "ping body",
+			secret:    "whsec_test",
+			timestamp: 1700000000,
+			body:      `{"event":"ping"}`,
+			want:      "t=1700000000,v1=aa8efe37b751e71157c508c5ac4acb1e9fe5225db98355dfc00f4b680afbc447",
+		},
+		{
+			name:      "empty body",
+			secret:    "whsec_test",
+			timestamp: 1700000000,
+			body:      "",
+			want:      "t=1700000000,v1=5967f3c560522fa40cf2876ebc3c3a08551dd6959aaade3b413460591895bdcc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SignWebhook(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("SignWebhook() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignWebhookDependsOnEveryInput(t *testing.T) {
+	base := SignWebhook("whsec_test", 1700000000, []byte("{}"))
+	for name, got := range map[string]string{
+		"secret":    SignWebhook("whsec_other", 1700000000, []byte("{}")),
+		"timestamp": SignWebhook("whsec_test", 1700000001, []byte("{}")),
+		"body":      SignWebhook("whsec_test", 1700000000, []byte("[]")),
+	} {
+		if got == base {
+			t.Errorf("changing the %s did not change the signature", name)
+		}
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"100.128.0.1", true},
+		{"0.0.0.0", false},
+		{"198.18.0.1", false},
+		{"255.255.255.255", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:100.64.0.1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isPublicAddress(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("isPublicAddress(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package geo
+
+import (
+	"ballerbio/utils"
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed gazetteer.tsv
+var bundledGazetteer string
+
+// Gazetteer is an offline geocoder over a list of places, matched by name
+// and alternate names. Qualifiers after the first comma ("Newcastle,
+// Australia") pick between places of the same name; otherwise the most
+// populous one wins.
+type Gazetteer struct {
+	places []Place
+	index  map[string][]int
+}
+
+var DefaultGazetteer = sync.OnceValue(func() *Gazetteer {
+	g, err := LoadGazetteer(strings.NewReader(bundledGazetteer))
+	if err != nil {
+		panic(fmt.Sprintf("geo: bundled gazetteer: %v", err))
+	}
+	return g
+})
+
+// OpenGazetteer loads a gazetteer file.
+func OpenGazetteer(path string) (*Gazetteer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadGazetteer(f)
+}
+
+// LoadGazetteer reads tab-separated places, one per line. Lines are either
+// GeoNames exports (cities15000.txt and the like) or, as in the bundled file,
+// name, alternate names (comma-separated), region, country code, latitude,
+// longitude and population. Blank lines and lines starting with # are skipped.
+func LoadGazetteer(r io.Reader) (*Gazetteer, error) {
+	g := &Gazetteer{index: map[string][]int{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		place, names, err := parsePlace(strings.Split(text, "\t"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		g.add(place, names)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, ids := range g.index {
+		sort.SliceStable(ids, func(i, j int) bool { return g.places[ids[i]].Population > g.places[ids[j]].Population })
+	}
+	return g, nil
+}
+
+func parsePlace(fields []string) (Place, []string, error) {
+	var place Place
+	var names []string
+	var lat, lng, population string
+	switch {
+	case len(fields) >= 15:
+		// geonameid, name, asciiname, alternatenames, latitude, longitude,
+		// feature class, feature code, country code, cc2, admin1 code, ...,
+		// population.
+		place = Place{Name: fields[1], Region: fields[10], CountryCode: fields[8]}
+		names = append([]string{fields[1], fields[2]}, strings.Split(fields[3], ",")...)
+		lat, lng, population = fields[4], fields[5], fields[14]
+	case len(fields) == 7:
+		place = Place{Name: fields[0], Region: fields[2], CountryCode: fields[3]}
+		names = append([]string{fields[0]}, strings.Split(fields[1], ",")...)
+		lat, lng, population = fields[4], fields[5], fields[6]
+	default:
+		return place, nil, fmt.Errorf("expected 7 or at least 15 tab-separated fields, got %d", len(fields))
+	}
+	var err error
+	if place.Latitude, err = strconv.ParseFloat(lat, 64); err != nil {
+		return place, nil, fmt.Errorf("latitude: %w", err)
+	}
+	if place.Longitude, err = strconv.ParseFloat(lng, 64); err != nil {
+		return place, nil, fmt.Errorf("longitude: %w", err)
+	}
+	if population != "" {
+		if place.Population, err = strconv.Atoi(population); err != nil {
+			return place, nil, fmt.Errorf("population: %w", err)
+		}
+	}
+	return place, names, nil
+}
+
+func (g *Gazetteer) add(place Place, names []string) {
+	id := len(g.places)
+	g.places = append(g.places, place)
+	seen := map[string]bool{}
+	for _, name := range names {
+		key := utils.NormalizeName(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		g.index[key] = append(g.index[key], id)
+	}
+}
+
+// countryAliases are common names for countries that are not their ISO name.
+var countryAliases = map[string]string{
+	"uk":  "GB",
+	"usa": "US",
+}
+
+// qualifies reports whether a qualifier such as "England", "ES" or "Spain"
+// describes place.
+func qualifies(place Place, qualifier string) bool {
+	key := utils.NormalizeName(qualifier)
+	switch {
+	case key == "":
+		return false
+	case strings.EqualFold(qualifier, place.CountryCode), countryAliases[key] == place.CountryCode:
+		return true
+	case key == utils.NormalizeName(place.Region):
+		return true
+	}
+	return key == utils.NormalizeName(utils.CountryName(place.CountryCode, "en"))
+}
+
+// Geocode resolves query, trying each comma-separated part in turn as the
+// place name so "Old Trafford, Manchester" still finds Manchester.
+func (g *Gazetteer) Geocode(query string) (*Place, error) {
+	parts := strings.Split(query, ",")
+	for i := range parts {
+		ids := g.index[utils.NormalizeName(parts[i])]
+		if len(ids) == 0 {
+			continue
+		}
+		// The most populous of the places matching the most qualifiers.
+		best, bestScore := ids[0], -1
+		for _, id := range ids {
+			score := 0
+			for _, q := range parts[i+1:] {
+				if qualifies(g.places[id], q) {
+					score++
+				}
+			}
+			if score > bestScore {
+				best, bestScore = id, score
+			}
+		}
+		place := g.places[best]
+		return &place, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,61 @@
+// Package geo resolves free-text locations to coordinates and measures the
+// distance between them.
+package geo
+
+import (
+	"math"
+	"os"
+)
+
+// EarthRadiusKm is the mean radius of the Earth.
+const EarthRadiusKm = 6371.0
+
+// Place is a populated place. Region is the first-level division (England,
+// Catalonia, Bavaria) and CountryCode the ISO 3166-1 alpha-2 code.
+type Place struct {
+	Name        string  `json:"name"`
+	Region      string  `json:"region,omitempty"`
+	CountryCode string  `json:"country_code"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Population  int     `json:"population,omitempty"`
+}
+
+// Geocoder resolves free text such as "Manchester" or "Newcastle, Australia"
+// to a place. It returns nil when it knows no such place.
+type Geocoder interface {
+	Geocode(query string) (*Place, error)
+}
+
+// FromEnv returns the geocoder configured by the environment: the gazetteer
+// file named by GAZETTEER_FILE (a GeoNames cities export or the format of
+// the bundled gazetteer.tsv), or the bundled gazetteer.
+func FromEnv() (Geocoder, error) {
+	path := os.Getenv("GAZETTEER_FILE")
+	if path == "" {
+		return DefaultGazetteer(), nil
+	}
+	return OpenGazetteer(path)
+}
+
+// Distance is the great-circle distance in kilometres between two points.
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat, dLng := rad(lat2-lat1), rad(lng2-lng1)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+// BoundingBox returns the latitude and longitude ranges enclosing every point
+// within radiusKm of the centre. wraps is true when the longitude range
+// crosses the antimeridian or a pole, and longitudes should not be bounded.
+func BoundingBox(lat, lng, radiusKm float64) (minLat, maxLat, minLng, maxLng float64, wraps bool) {
+	dLat := radiusKm / EarthRadiusKm * 180 / math.Pi
+	minLat, maxLat = lat-dLat, lat+dLat
+	if minLat <= -90 || maxLat >= 90 {
+		return math.Max(minLat, -90), math.Min(maxLat, 90), -180, 180, true
+	}
+	dLng := dLat / math.Cos(lat*math.Pi/180)
+	minLng, maxLng = lng-dLng, lng+dLng
+	return minLat, maxLat, minLng, maxLng, minLng < -180 || maxLng > 180
+}
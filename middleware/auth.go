@@ -1,7 +1,7 @@
 package auth
 
 import (
-	"net/http"
+	"ballerbio/api_errors"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"log"
@@ -31,15 +31,13 @@ func AuthMiddleware() gin.HandlerFunc {
 		// 1. Get the Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			api_errors.Abort(c, api_errors.Unauthorized(api_errors.CodeUnauthorized, "Authorization header required"))
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header format"})
-			c.Abort()
+			api_errors.Abort(c, api_errors.Unauthorized(api_errors.CodeUnauthorized, "Invalid Authorization header format"))
 			return
 		}
 		tokenString := parts[1]
@@ -51,12 +49,24 @@ func AuthMiddleware() gin.HandlerFunc {
 		})
 		if err != nil || !token.Valid {
 			log.Printf("JWT parsing/validation error: %v", err)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+			api_errors.Abort(c, api_errors.Unauthorized(api_errors.CodeInvalidToken, "Invalid or expired token"))
 			return
 		}
 
 		c.Set("userID", claims.UserID)
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// OptionalAuthMiddleware identifies the caller when a token is sent and lets
+// anonymous requests through. An invalid token is still rejected.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	required := AuthMiddleware()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		required(c)
+	}
+}
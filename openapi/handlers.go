@@ -0,0 +1,115 @@
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpecGinHandler serves the generated document. It is marshalled once up
+// front since the route table cannot change after start-up.
+func SpecGinHandler(doc *Document) gin.HandlerFunc {
+	body, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic(fmt.Sprintf("openapi: could not marshal document: %v", err))
+	}
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+}
+
+// Swagger UI is embedded at a pinned version so /docs works offline and does
+// not depend on a CDN. Bump it deliberately, together with the files in assets.
+const swaggerUIVersion = "5.18.2"
+
+//go:embed assets/*.js assets/*.css
+var assets embed.FS
+
+// AssetsPath is where AssetsGinHandler is mounted.
+const AssetsPath = "/docs/assets"
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>ballerbio API</title>
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <link rel="stylesheet" href="` + AssetsPath + `/swagger-ui.css?v=` + swaggerUIVersion + `">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="` + AssetsPath + `/swagger-ui-bundle.js?v=` + swaggerUIVersion + `"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui", deepLinking: true });
+  </script>
+</body>
+</html>`
+
+// DocsGinHandler serves an interactive Swagger UI page for /openapi.json.
+func DocsGinHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
+
+// AssetsGinHandler serves the embedded Swagger UI files. It expects a
+// *filepath parameter.
+func AssetsGinHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.FileFromFS(path.Join("assets", c.Param("filepath")), http.FS(assets))
+}
+
+// RedocGinHandler sends the old Redoc reference to /docs. Redoc is not
+// bundled, and loading it from a CDN would break the docs offline.
+func RedocGinHandler(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, "/docs")
+}
+
+// CheckDrift compares the routes gin actually serves with the documented
+// operations and reports any that appear on only one side. Paths listed in
+// undocumented (e.g. the spec and docs pages themselves) are skipped.
+func CheckDrift(routes gin.RoutesInfo, doc *Document, undocumented ...string) error {
+	skip := map[string]bool{}
+	for _, path := range undocumented {
+		skip[path] = true
+	}
+
+	served := map[string]bool{}
+	for _, route := range routes {
+		if skip[route.Path] {
+			continue
+		}
+		served[route.Method+" "+ToOpenAPIPath(route.Path)] = true
+	}
+
+	var missing, stale []string
+	documented := map[string]bool{}
+	for _, op := range doc.Operations() {
+		documented[op] = true
+		if !served[op] {
+			stale = append(stale, op)
+		}
+	}
+	for op := range served {
+		if !documented[op] {
+			missing = append(missing, op)
+		}
+	}
+	if len(missing) == 0 && len(stale) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "undocumented routes: "+strings.Join(missing, ", "))
+	}
+	if len(stale) > 0 {
+		problems = append(problems, "documented but not served: "+strings.Join(stale, ", "))
+	}
+	return fmt.Errorf("openapi spec drift: %s", strings.Join(problems, "; "))
+}
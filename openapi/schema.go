@@ -0,0 +1,278 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema (2020-12 dialect, as used by OpenAPI 3.1) node.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+}
+
+var (
+	enums         = map[reflect.Type]map[string][]any{}
+	typeOverrides = map[reflect.Type]Schema{
+		reflect.TypeOf(time.Time{}): {Type: "string", Format: "date-time"},
+	}
+)
+
+// RegisterEnum documents the allowed values of field (the Go field name) on
+// model. Use it for string fields backed by a set of constants.
+func RegisterEnum(model any, field string, values ...string) {
+	t := indirectType(reflect.TypeOf(model))
+	if enums[t] == nil {
+		enums[t] = map[string][]any{}
+	}
+	for _, v := range values {
+		enums[t][field] = append(enums[t][field], v)
+	}
+}
+
+// bindingRules documents custom validator tags that have a JSON Schema
+// equivalent. Built-in rules are handled by applyBinding.
+var bindingRules = map[string]func(s *Schema, param string){}
+
+// RegisterBindingRule teaches the generator how to document a custom
+// validator tag, e.g. by setting a pattern or enum.
+func RegisterBindingRule(rule string, apply func(s *Schema, param string)) {
+	bindingRules[rule] = apply
+}
+
+// RegisterType overrides the schema generated for a Go type, for types that
+// marshal to something other than their struct layout (gorm.DeletedAt, ...).
+func RegisterType(value any, schema Schema) {
+	typeOverrides[reflect.TypeOf(value)] = schema
+}
+
+type schemaRegistry struct {
+	components map[string]*Schema
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{components: map[string]*Schema{}}
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func (r *schemaRegistry) schemaFor(t reflect.Type) *Schema {
+	nullable := t.Kind() == reflect.Pointer
+	t = indirectType(t)
+
+	if override, ok := typeOverrides[t]; ok {
+		s := override
+		if nullable {
+			s.Type = []any{s.Type, "null"}
+		}
+		return &s
+	}
+
+	var s *Schema
+	switch t.Kind() {
+	case reflect.Bool:
+		s = &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = &Schema{Type: "integer"}
+		if t.Kind() == reflect.Int32 {
+			s.Format = "int32"
+		} else if t.Kind() == reflect.Int64 {
+			s.Format = "int64"
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = &Schema{Type: "integer", Minimum: ptr(0.0)}
+	case reflect.Float32, reflect.Float64:
+		s = &Schema{Type: "number"}
+	case reflect.String:
+		s = &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s = &Schema{Type: "string", Format: "byte"}
+		} else {
+			s = &Schema{Type: "array", Items: r.schemaFor(t.Elem())}
+		}
+	case reflect.Map:
+		s = &Schema{Type: "object", AdditionalProperties: r.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := r.components[name]; !ok {
+			// Reserve the name first so self-referencing types terminate.
+			r.components[name] = &Schema{}
+			*r.components[name] = *r.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		s = &Schema{}
+	}
+
+	if nullable && s.Type != nil {
+		s.Type = []any{s.Type, "null"}
+	}
+	return s
+}
+
+func (r *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	r.addFields(s, t)
+	if len(s.Properties) == 0 {
+		s.Properties = nil
+	}
+	return s
+}
+
+func (r *schemaRegistry) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, skip := jsonName(field)
+		if skip {
+			continue
+		}
+		if field.Anonymous && field.Tag.Get("json") == "" && indirectType(field.Type).Kind() == reflect.Struct {
+			r.addFields(s, indirectType(field.Type))
+			continue
+		}
+
+		prop := r.schemaFor(field.Type)
+		if values, ok := enums[t][field.Name]; ok {
+			prop.Enum = values
+		}
+		// validator ignores "required" on nested struct values, so only
+		// advertise it where gin actually enforces it.
+		enforced := field.Type.Kind() != reflect.Struct || prop.Ref == ""
+		if applyBinding(prop, field.Tag.Get("binding")) && enforced {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		name = field.Name
+	}
+	return name, false
+}
+
+// applyBinding translates the validator rules gin enforces through the
+// binding tag into schema keywords and reports whether the field is required.
+func applyBinding(s *Schema, binding string) bool {
+	required := false
+	if binding == "" || s.Ref != "" {
+		return strings.Contains(binding, "required")
+	}
+
+	isString := s.Type == "string" || (isSlice(s.Type) && s.Type.([]any)[0] == "string")
+	isArray := s.Type == "array"
+	rules := strings.Split(binding, ",")
+	for i, rule := range rules {
+		key, param, _ := strings.Cut(rule, "=")
+		switch key {
+		case "dive":
+			// Everything after dive applies to the elements.
+			if isArray && s.Items != nil && s.Items.Ref == "" {
+				applyBinding(s.Items, strings.Join(rules[i+1:], ","))
+			}
+			return required
+		case "required":
+			required = true
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "oneof":
+			if len(s.Enum) == 0 {
+				for _, v := range strings.Fields(param) {
+					s.Enum = append(s.Enum, v)
+				}
+			}
+		case "min", "gte":
+			if n, err := strconv.ParseFloat(param, 64); err == nil {
+				if isArray {
+					s.MinItems = ptr(int(n))
+				} else if isString {
+					s.MinLength = ptr(int(n))
+				} else {
+					s.Minimum = ptr(n)
+				}
+			}
+		case "max", "lte":
+			if n, err := strconv.ParseFloat(param, 64); err == nil {
+				if isArray {
+					s.MaxItems = ptr(int(n))
+				} else if isString {
+					s.MaxLength = ptr(int(n))
+				} else {
+					s.Maximum = ptr(n)
+				}
+			}
+		default:
+			if apply, ok := bindingRules[key]; ok {
+				apply(s, param)
+			}
+		}
+	}
+	return required
+}
+
+func isSlice(v any) bool {
+	_, ok := v.([]any)
+	return ok
+}
+
+func (r *schemaRegistry) queryParameters(t reflect.Type) []Parameter {
+	t = indirectType(t)
+	var params []Parameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("form"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		prop := r.schemaFor(field.Type)
+		if values, ok := enums[t][field.Name]; ok {
+			prop.Enum = values
+		}
+		required := applyBinding(prop, field.Tag.Get("binding"))
+		param := Parameter{Name: name, In: "query", Required: required, Schema: prop}
+		// Gin reads csv collections from a single comma-separated value.
+		if field.Tag.Get("collection_format") == "csv" {
+			explode := false
+			param.Style, param.Explode = "form", &explode
+		}
+		params = append(params, param)
+	}
+	return params
+}
@@ -0,0 +1,221 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Document is the subset of the OpenAPI 3.1 object model ballerbio emits.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Servers    []Server                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Style    string  `json:"style,omitempty"`
+	Explode  *bool   `json:"explode,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Endpoint describes one mounted route for the generator. Request and Response
+// are zero values of the Go types bound and returned by the handler.
+type Endpoint struct {
+	Method     string
+	Path       string
+	Summary    string
+	Tag        string
+	Auth       bool
+	Deprecated bool
+	Request    any
+	Response   any
+	Query      any
+	Status     int
+}
+
+var pathParam = regexp.MustCompile(`[:*]([A-Za-z_][A-Za-z0-9_]*)`)
+
+// ToOpenAPIPath converts a gin path (/profiles/:id/:slug) into the OpenAPI
+// templated form (/profiles/{id}/{slug}).
+func ToOpenAPIPath(path string) string {
+	return pathParam.ReplaceAllString(path, "{$1}")
+}
+
+// Generator accumulates endpoints into a Document.
+type Generator struct {
+	doc     *Document
+	schemas *schemaRegistry
+
+	errorContentType string
+	errorSchema      *Schema
+}
+
+func NewGenerator(info Info) *Generator {
+	schemas := newSchemaRegistry()
+	return &Generator{
+		doc: &Document{
+			OpenAPI: "3.1.0",
+			Info:    info,
+			Servers: []Server{{URL: "/"}},
+			Paths:   map[string]map[string]*Operation{},
+			Components: Components{
+				Schemas: schemas.components,
+				SecuritySchemes: map[string]*SecurityScheme{
+					"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				},
+			},
+		},
+		schemas: schemas,
+	}
+}
+
+// SetErrorResponse documents the body every endpoint returns on failure.
+// It applies to endpoints added afterwards.
+func (g *Generator) SetErrorResponse(contentType string, model any) {
+	g.errorContentType = contentType
+	g.errorSchema = g.schemas.schemaFor(reflect.TypeOf(model))
+}
+
+// Add documents a single endpoint.
+func (g *Generator) Add(e Endpoint) {
+	path := ToOpenAPIPath(e.Path)
+	method := strings.ToLower(e.Method)
+
+	op := &Operation{
+		OperationID: operationID(e.Method, e.Path),
+		Summary:     e.Summary,
+		Deprecated:  e.Deprecated,
+		Responses:   map[string]*Response{},
+	}
+	if e.Tag != "" {
+		op.Tags = []string{e.Tag}
+	}
+
+	for _, match := range pathParam.FindAllStringSubmatch(e.Path, -1) {
+		schema := &Schema{Type: "string"}
+		if match[1] == "id" || strings.HasSuffix(match[1], "_id") {
+			schema = &Schema{Type: "integer", Minimum: ptr(1.0)}
+		}
+		op.Parameters = append(op.Parameters, Parameter{Name: match[1], In: "path", Required: true, Schema: schema})
+	}
+	if e.Query != nil {
+		op.Parameters = append(op.Parameters, g.schemas.queryParameters(reflect.TypeOf(e.Query))...)
+	}
+
+	if e.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{"application/json": {Schema: g.schemas.schemaFor(reflect.TypeOf(e.Request))}},
+		}
+	}
+
+	status := e.Status
+	if status == 0 {
+		status = 200
+	}
+	success := &Response{Description: "Successful response."}
+	if e.Response != nil {
+		success.Content = map[string]*MediaType{"application/json": {Schema: g.schemas.schemaFor(reflect.TypeOf(e.Response))}}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+	if g.errorSchema != nil {
+		op.Responses["default"] = &Response{
+			Description: "Error response.",
+			Content:     map[string]*MediaType{g.errorContentType: {Schema: g.errorSchema}},
+		}
+	}
+
+	if e.Auth {
+		op.Security = []map[string][]string{{"bearerAuth": {}}}
+	}
+
+	if g.doc.Paths[path] == nil {
+		g.doc.Paths[path] = map[string]*Operation{}
+	}
+	g.doc.Paths[path][method] = op
+}
+
+// Document returns the generated specification.
+func (g *Generator) Document() *Document {
+	return g.doc
+}
+
+// Operations lists every documented "METHOD /path" pair in sorted order.
+func (d *Document) Operations() []string {
+	var ops []string
+	for path, item := range d.Paths {
+		for method := range item {
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == ':' || r == '*' || r == '_' || r == '-' || r == '.'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,190 @@
+package routes
+
+import (
+	"ballerbio/api_errors"
+	"ballerbio/db_utils"
+	"ballerbio/openapi"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// undocumentedPaths are served by the router but deliberately left out of the
+// OpenAPI document.
+var undocumentedPaths = []string{"/openapi.json", "/docs", "/redoc", openapi.AssetsPath + "/*filepath"}
+
+func init() {
+	openapi.RegisterType(gorm.DeletedAt{}, openapi.Schema{Type: []any{"string", "null"}, Format: "date-time"})
+
+	openapi.RegisterEnum(db_utils.ClubProfile{}, "ContractType", db_utils.ContractTypes...)
+	openapi.RegisterBindingRule("contract_type", func(s *openapi.Schema, _ string) {
+		for _, v := range db_utils.ContractTypes {
+			s.Enum = append(s.Enum, v)
+		}
+	})
+	openapi.RegisterBindingRule("season", func(s *openapi.Schema, _ string) {
+		s.Pattern = `^\d{4}(/\d{2})?$`
+	})
+	openapi.RegisterEnum(PositionsQuery{}, "Lang", db_utils.SupportedLanguages...)
+	openapi.RegisterBindingRule("position", func(s *openapi.Schema, _ string) {
+		s.Description = "Canonical position code (GK, CB, CM, ST, ...). Common aliases such as \"centre back\" are accepted."
+	})
+	openapi.RegisterType(pq.StringArray{}, openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}})
+	openapi.RegisterEnum(db_utils.Profile{}, "PreferredFoot", db_utils.PreferredFeet...)
+	openapi.RegisterEnum(db_utils.Profile{}, "TransferStatus", db_utils.TransferStatuses...)
+	openapi.RegisterEnum(db_utils.PhysicalTest{}, "TestType", physicalTestTypes()...)
+	openapi.RegisterEnum(db_utils.PhysicalTest{}, "Source", db_utils.PhysicalTestSources...)
+	openapi.RegisterBindingRule("physical_test", func(s *openapi.Schema, _ string) {
+		for _, v := range physicalTestTypes() {
+			s.Enum = append(s.Enum, v)
+		}
+	})
+	openapi.RegisterBindingRule("test_source", func(s *openapi.Schema, _ string) {
+		for _, v := range db_utils.PhysicalTestSources {
+			s.Enum = append(s.Enum, v)
+		}
+	})
+	openapi.RegisterBindingRule("playing_style", func(s *openapi.Schema, _ string) {
+		for _, v := range db_utils.PlayingStyles {
+			s.Enum = append(s.Enum, v)
+		}
+	})
+	openapi.RegisterEnum(db_utils.User{}, "Role", db_utils.UserRoles...)
+	openapi.RegisterEnum(db_utils.Skill{}, "Code", skillCodes()...)
+	openapi.RegisterEnum(db_utils.SkillInfo{}, "Code", skillCodes()...)
+	openapi.RegisterEnum(db_utils.SkillEndorsement{}, "Relationship", db_utils.EndorsementByCoach, db_utils.EndorsementByTeammate)
+	openapi.RegisterBindingRule("skill", func(s *openapi.Schema, _ string) {
+		s.Description = "Skill code from GET /skills/catalogue. Free-text names such as \"long balls\" are mapped onto the catalogue."
+	})
+	openapi.RegisterBindingRule("skill_level", func(s *openapi.Schema, _ string) {
+		s.Description = "Deprecated: use self_rating. A rating (\"7\", \"7/10\") or a level such as \"Advanced\"."
+	})
+	openapi.RegisterEnum(db_utils.DirectoryMatch{}, "Type", db_utils.DirectoryClub, db_utils.DirectoryLeague, db_utils.DirectoryCountry)
+	openapi.RegisterEnum(db_utils.VerificationRequest{}, "SubjectType", db_utils.VerificationSubjectClubProfile, db_utils.VerificationSubjectSeasonStat)
+	openapi.RegisterEnum(db_utils.VerificationRequest{}, "Status", db_utils.VerificationPending, db_utils.VerificationApproved, db_utils.VerificationRejected, db_utils.VerificationCancelled)
+	openapi.RegisterEnum(db_utils.Shortlist{}, "Access", db_utils.ShortlistOwner, db_utils.ShortlistEdit, db_utils.ShortlistView)
+	openapi.RegisterEnum(db_utils.ShortlistShare{}, "Permission", db_utils.ShortlistView, db_utils.ShortlistEdit)
+	openapi.RegisterEnum(db_utils.SavedSearch{}, "Frequency", db_utils.SearchFrequencyInstant, db_utils.SearchFrequencyDaily, db_utils.SearchFrequencyWeekly)
+	openapi.RegisterEnum(db_utils.SavedSearch{}, "Channel", db_utils.AlertChannelInApp, db_utils.AlertChannelEmail, db_utils.AlertChannelBoth)
+	openapi.RegisterEnum(db_utils.Notification{}, "Type", db_utils.NotificationSavedSearchMatch, db_utils.NotificationNewMessage, db_utils.NotificationTrialUpdate,
+		db_utils.NotificationContractExpiry)
+	openapi.RegisterEnum(db_utils.UserReport{}, "Reason", db_utils.ReportSpam, db_utils.ReportHarassment, db_utils.ReportInappropriate, db_utils.ReportOther)
+	openapi.RegisterEnum(db_utils.UserReport{}, "Status", db_utils.ReportOpen, db_utils.ReportReviewed)
+	openapi.RegisterEnum(db_utils.GuardianLink{}, "Status", db_utils.GuardianPending, db_utils.GuardianConsented)
+	openapi.RegisterEnum(db_utils.TrialEvent{}, "Status", db_utils.TrialScheduled, db_utils.TrialCancelled)
+	openapi.RegisterEnum(db_utils.TrialApplication{}, "Status", db_utils.ApplicationApplied, db_utils.ApplicationInvited,
+		db_utils.ApplicationAttended, db_utils.ApplicationOffered, db_utils.ApplicationRejected, db_utils.ApplicationWithdrawn)
+	openapi.RegisterEnum(db_utils.Injury{}, "BodyArea", bodyAreaCodes()...)
+	openapi.RegisterEnum(db_utils.BodyAreaInfo{}, "Code", bodyAreaCodes()...)
+	openapi.RegisterEnum(db_utils.InjurySummary{}, "Status", db_utils.InjuryStatusFit, db_utils.InjuryStatusInjured, db_utils.InjuryStatusReturning)
+	openapi.RegisterEnum(db_utils.Profile{}, "InjuryStatus", db_utils.InjuryStatusFit, db_utils.InjuryStatusInjured, db_utils.InjuryStatusReturning)
+	openapi.RegisterBindingRule("body_area", func(s *openapi.Schema, _ string) {
+		s.Description = "Body area code from GET /injury/body-areas. Common words such as \"ACL\" or \"thigh\" are accepted."
+	})
+	openapi.RegisterEnum(db_utils.CareerEvent{}, "Type", db_utils.CareerEventJoined, db_utils.CareerEventTransfer, db_utils.CareerEventLoan,
+		db_utils.CareerEventLoanReturn, db_utils.CareerEventLeft, db_utils.CareerEventInjury, db_utils.CareerEventAchievement)
+	openapi.RegisterEnum(db_utils.CareerClub{}, "Source", db_utils.CareerSourceSeasonStats, db_utils.CareerSourceClubProfile)
+	openapi.RegisterEnum(db_utils.ActivityEvent{}, "Type", db_utils.ActivitySeasonStat, db_utils.ActivityAchievement, db_utils.ActivityClubMove, db_utils.ActivityHighlight)
+	openapi.RegisterType(pq.Int64Array{}, openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "integer"}})
+	openapi.RegisterEnum(db_utils.WebhookDelivery{}, "Status", db_utils.DeliveryPending, db_utils.DeliverySucceeded, db_utils.DeliveryFailed)
+	openapi.RegisterEnum(db_utils.WebhookDelivery{}, "Event", slices.Concat(db_utils.WebhookEvents, []string{db_utils.WebhookPing})...)
+	openapi.RegisterBindingRule("webhook_event", func(s *openapi.Schema, _ string) {
+		for _, v := range db_utils.WebhookEvents {
+			s.Enum = append(s.Enum, v)
+		}
+	})
+	openapi.RegisterEnum(db_utils.Match{}, "HomeAway", db_utils.MatchHome, db_utils.MatchAway, db_utils.MatchNeutral)
+	openapi.RegisterBindingRule("age_group", func(s *openapi.Schema, _ string) {
+		s.Description = "Age group such as U12 or U18 (players under that age on the day), or senior."
+		s.Pattern = `^([uU]\d{1,2}|[sS]enior)$`
+	})
+	openapi.RegisterBindingRule("iso3166_1_alpha2", func(s *openapi.Schema, _ string) {
+		s.Description = "ISO 3166-1 alpha-2 country code."
+		s.Pattern = `^[A-Z]{2}$`
+	})
+	openapi.RegisterBindingRule("notfuture", func(s *openapi.Schema, _ string) {
+		s.Description = "Must not be in the future."
+	})
+	openapi.RegisterBindingRule("dob", func(s *openapi.Schema, _ string) {
+		s.Description = fmt.Sprintf("Date of birth; the player must be between %d and %d years old.", db_utils.MinPlayerAge, db_utils.MaxPlayerAge)
+	})
+}
+
+func physicalTestTypes() []string {
+	types := make([]string, 0, len(db_utils.PhysicalTestUnits))
+	for t := range db_utils.PhysicalTestUnits {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func skillCodes() []string {
+	codes := make([]string, 0, len(db_utils.SkillCatalogue))
+	for _, s := range db_utils.SkillCatalogue {
+		codes = append(codes, s.Code)
+	}
+	return codes
+}
+
+func bodyAreaCodes() []string {
+	codes := make([]string, 0, len(db_utils.BodyAreaCatalogue))
+	for _, a := range db_utils.BodyAreaCatalogue {
+		codes = append(codes, a.Code)
+	}
+	return codes
+}
+
+// PositionsQuery documents the query string of GET /positions.
+type PositionsQuery struct {
+	Lang string `form:"lang"`
+}
+
+// CountriesQuery documents the query string of GET /countries. Lang is any
+// BCP 47 tag.
+type CountriesQuery struct {
+	Lang string `form:"lang"`
+}
+
+// buildSpec documents every route of the table on every version it is
+// mounted on, so the document always mirrors what the router serves.
+func buildSpec(table []Route) *openapi.Document {
+	gen := openapi.NewGenerator(openapi.Info{
+		Title:       "ballerbio API",
+		Version:     VersionV1,
+		Description: "Public profiles, stats and career history for amateur football players.",
+	})
+	gen.SetErrorResponse(api_errors.ProblemContentType, api_errors.Problem{})
+
+	for _, route := range table {
+		for _, name := range route.Versions {
+			version, _ := findVersion(name)
+			gen.Add(openapi.Endpoint{
+				Method:     route.Method,
+				Path:       versionedPath(version, route),
+				Summary:    route.Summary,
+				Tag:        route.Tag,
+				Auth:       route.Auth,
+				Deprecated: version.Status == VersionStatusDeprecated,
+				Request:    route.Request,
+				Response:   route.Response,
+				Query:      route.Query,
+				Status:     route.Status,
+			})
+		}
+	}
+
+	gen.Add(openapi.Endpoint{
+		Method:   http.MethodGet,
+		Path:     "/api/versions",
+		Summary:  "List the available API versions",
+		Tag:      "meta",
+		Response: VersionsResponse{},
+	})
+
+	return gen.Document()
+}
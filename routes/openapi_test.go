@@ -0,0 +1,104 @@
+package routes
+
+import (
+	"ballerbio/db_utils"
+	"ballerbio/openapi"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/openapi.json from the route table")
+
+var goldenSpec = filepath.Join("testdata", "openapi.json")
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+func servedSpec(t *testing.T, router *gin.Engine) []byte {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d", w.Code)
+	}
+	return append(w.Body.Bytes(), '\n')
+}
+
+// TestOpenAPIGolden fails whenever the served document changes. Review the
+// diff and rerun with -update to accept it:
+//
+//	go test ./routes -run TestOpenAPIGolden -update
+func TestOpenAPIGolden(t *testing.T) {
+	got := servedSpec(t, NewRouter(&db_utils.DBHandler{}))
+	if *update {
+		if err := os.WriteFile(goldenSpec, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(goldenSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is out of date with the route table; run go test ./routes -run TestOpenAPIGolden -update and review the diff", goldenSpec)
+	}
+}
+
+// TestRoutesMatchGoldenSpec checks the routes gin serves against the
+// committed document rather than one generated from the same table.
+func TestRoutesMatchGoldenSpec(t *testing.T) {
+	body, err := os.ReadFile(goldenSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc openapi.Document
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("%s: %v", goldenSpec, err)
+	}
+	router := NewRouter(&db_utils.DBHandler{})
+	if err := openapi.CheckDrift(router.Routes(), &doc, undocumentedPaths...); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpenAPIIsDeterministic(t *testing.T) {
+	first := servedSpec(t, NewRouter(&db_utils.DBHandler{}))
+	second := servedSpec(t, NewRouter(&db_utils.DBHandler{}))
+	if !bytes.Equal(first, second) {
+		t.Error("two builds of the document differ")
+	}
+}
+
+// TestDocsServeEmbeddedAssets checks that /docs only loads files the server
+// embeds, so the page works without a CDN.
+func TestDocsServeEmbeddedAssets(t *testing.T) {
+	router := NewRouter(&db_utils.DBHandler{})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /docs = %d", w.Code)
+	}
+	refs := regexp.MustCompile(`(?:src|href)="([^"]+)"`).FindAllStringSubmatch(w.Body.String(), -1)
+	if len(refs) == 0 {
+		t.Fatal("/docs references no assets")
+	}
+	for _, ref := range refs {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, ref[1], nil))
+		if w.Code != http.StatusOK || w.Body.Len() == 0 {
+			t.Errorf("GET %s = %d with %d bytes, want an embedded file", ref[1], w.Code, w.Body.Len())
+		}
+	}
+}
@@ -1,45 +1,594 @@
 package routes
 
 import (
+	"ballerbio/api_errors"
 	"ballerbio/db_utils"
+	"ballerbio/geo"
+	"ballerbio/jobs"
 	"ballerbio/middleware"
+	"ballerbio/openapi"
+	"context"
 	"log"
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 )
 
+// Route is a single entry of the API route table. Paths are relative to the
+// version base path; Auth routes are mounted behind the JWT middleware, and
+// OptionalAuth routes identify the caller when a token is sent.
+// Summary, Tag, Request, Response and Status only feed the OpenAPI document.
+type Route struct {
+	Method       string
+	Path         string
+	Handler      gin.HandlerFunc
+	Auth         bool
+	OptionalAuth bool
+	Versions     []string
+	Transforms   map[string]Transform
+
+	Summary  string
+	Tag      string
+	Request  any
+	Response any
+	Query    any
+	Status   int
+}
+
+var (
+	allVersions     = []string{VersionLegacy, VersionV1, VersionV2}
+	currentVersions = []string{VersionV1, VersionV2}
+)
+
+func routeTable(handler *db_utils.DBHandler) []Route {
+	childResource := map[string]Transform{VersionV2: OmitEmbeddedProfile}
+
+	return []Route{
+		{
+			Method: http.MethodPost, Path: "/profiles/create", Handler: handler.CreateProfileGinHandler, Auth: true, Versions: allVersions,
+			Summary: "Create a player profile", Tag: "profiles", Request: db_utils.CreateProfileInput{}, Response: db_utils.Profile{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPost, Path: "/skills/add", Handler: handler.AddSkillToProfileGinHandler, Auth: true, Versions: allVersions, Transforms: childResource,
+			Summary: "Add a skill to a profile", Tag: "skills", Request: db_utils.AddSkill{}, Response: db_utils.Skill{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPost, Path: "/achievements/add", Handler: handler.AddAchievementToProfileGinHandler, Auth: true, Versions: allVersions, Transforms: childResource,
+			Summary: "Add an achievement to a profile", Tag: "achievements", Request: db_utils.AddAchievement{}, Response: db_utils.Achievement{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPost, Path: "/injury/add", Handler: handler.AddInjuryToProfileGinHandler, Auth: true, Versions: allVersions, Transforms: childResource,
+			Summary: "Record an injury on a profile", Tag: "injuries", Request: db_utils.AddInjury{}, Response: db_utils.Injury{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPost, Path: "/injury/:id/update", Handler: handler.UpdateInjuryGinHandler, Auth: true, Versions: currentVersions, Transforms: childResource,
+			Summary: "Update an injury or record the player's return", Tag: "injuries", Request: db_utils.UpdateInjury{}, Response: db_utils.Injury{},
+		},
+		{
+			Method: http.MethodGet, Path: "/injury/summary", Handler: handler.GetInjurySummaryGinHandler, Versions: currentVersions,
+			Summary: "A player's injury status, days out per season and body area, and recurrences", Tag: "injuries", Query: db_utils.InjurySummaryQuery{}, Response: db_utils.InjurySummary{},
+		},
+		{
+			Method: http.MethodGet, Path: "/injury/body-areas", Handler: handler.GetBodyAreasGinHandler, Versions: currentVersions,
+			Summary: "List the body areas injuries are classified by", Tag: "injuries", Response: []db_utils.BodyAreaInfo{},
+		},
+		{
+			Method: http.MethodPost, Path: "/sociallink/add", Handler: handler.AddSocialLinkToProfileGinHandler, Auth: true, Versions: allVersions, Transforms: childResource,
+			Summary: "Add a social link to a profile", Tag: "social links", Request: db_utils.AddSocialLink{}, Response: db_utils.SocialLink{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPost, Path: "/clubprofile/add", Handler: handler.AddClubProfileToProfileGinHandler, Auth: true, Versions: allVersions, Transforms: childResource,
+			Summary: "Add a club spell to a profile", Tag: "clubs", Request: db_utils.AddClubProfile{}, Response: db_utils.ClubProfile{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPost, Path: "/clubprofile/:id/contract", Handler: handler.UpdateContractGinHandler, Auth: true, Versions: currentVersions, Transforms: childResource,
+			Summary: "Set the contract type and dates of a club spell", Tag: "club profiles", Request: db_utils.UpdateContract{}, Response: db_utils.ClubProfile{},
+		},
+		{
+			Method: http.MethodPost, Path: "/seasonstats/add", Handler: handler.AddSeasonStatToProfileGinHandler, Auth: true, Versions: allVersions, Transforms: childResource,
+			Summary: "Add season statistics to a profile", Tag: "season stats", Request: db_utils.AddSeasonStat{}, Response: db_utils.SeasonStat{}, Status: http.StatusCreated,
+		},
+
+		{
+			Method: http.MethodGet, Path: "/profiles", Handler: handler.GetProfilesGinHandler, Versions: allVersions,
+			Summary: "List player profiles", Tag: "profiles", Query: db_utils.ProfileFilter{}, Response: []db_utils.Profile{},
+		},
+		{
+			Method: http.MethodGet, Path: "/profiles/free-agents", Handler: handler.GetFreeAgentsGinHandler, Versions: currentVersions,
+			Summary: "List players without a club under contract", Tag: "profiles", Query: db_utils.ProfileFilter{}, Response: []db_utils.Profile{},
+		},
+		{
+			Method: http.MethodGet, Path: "/profiles/:id/similar", Handler: handler.GetSimilarPlayersGinHandler, Versions: currentVersions,
+			Summary: "Suggest players similar to a profile, with the reasons why", Tag: "profiles", Query: db_utils.SimilarPlayersQuery{}, Response: []db_utils.SimilarPlayer{},
+		},
+		{
+			Method: http.MethodPost, Path: "/profiles/:id/follow", Handler: handler.FollowProfileGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Follow a player", Tag: "follows", Response: db_utils.Follow{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodDelete, Path: "/profiles/:id/follow", Handler: handler.UnfollowProfileGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Unfollow a player", Tag: "follows", Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodGet, Path: "/following", Handler: handler.GetFollowingGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List the players you follow", Tag: "follows", Response: []db_utils.Follow{},
+		},
+		{
+			Method: http.MethodGet, Path: "/feed", Handler: handler.GetFeedGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Activity of the players you follow, newest first", Tag: "follows", Query: db_utils.FeedQuery{}, Response: []db_utils.ActivityEvent{},
+		},
+		{
+			Method: http.MethodPost, Path: "/highlights/add", Handler: handler.AddHighlightGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Add a video highlight to your profile", Tag: "profiles", Request: db_utils.AddHighlight{}, Response: db_utils.Highlight{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/profiles/:id/highlights", Handler: handler.GetHighlightsGinHandler, Versions: currentVersions,
+			Summary: "List a player's video highlights", Tag: "profiles", Response: []db_utils.Highlight{},
+		},
+		{
+			Method: http.MethodPost, Path: "/webhooks/create", Handler: handler.CreateWebhookGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Subscribe a URL to profile events; the signing secret is only returned here", Tag: "webhooks", Request: db_utils.CreateWebhook{}, Response: db_utils.WebhookSubscriptionWithSecret{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/webhooks", Handler: handler.GetWebhooksGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List your webhooks", Tag: "webhooks", Response: []db_utils.WebhookSubscription{},
+		},
+		{
+			Method: http.MethodGet, Path: "/webhooks/:id", Handler: handler.GetWebhookGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Get a webhook", Tag: "webhooks", Response: db_utils.WebhookSubscription{},
+		},
+		{
+			Method: http.MethodPost, Path: "/webhooks/:id/update", Handler: handler.UpdateWebhookGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Update a webhook or rotate its secret", Tag: "webhooks", Request: db_utils.UpdateWebhook{}, Response: db_utils.WebhookSubscriptionWithSecret{},
+		},
+		{
+			Method: http.MethodDelete, Path: "/webhooks/:id", Handler: handler.DeleteWebhookGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Delete a webhook", Tag: "webhooks", Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodGet, Path: "/webhooks/:id/deliveries", Handler: handler.GetWebhookDeliveriesGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Delivery log of a webhook, newest first", Tag: "webhooks", Query: db_utils.WebhookDeliveriesQuery{}, Response: []db_utils.WebhookDelivery{},
+		},
+		{
+			Method: http.MethodPost, Path: "/webhooks/:id/ping", Handler: handler.PingWebhookGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Send a test ping to a webhook", Tag: "webhooks", Response: db_utils.WebhookDelivery{},
+		},
+		{
+			Method: http.MethodGet, Path: "/compare", Handler: handler.ComparePlayersGinHandler, Versions: currentVersions,
+			Summary: "Compare two or three players side by side with percentile ranks", Tag: "profiles", Query: db_utils.CompareQuery{}, Response: db_utils.Comparison{},
+		},
+		{
+			Method: http.MethodGet, Path: "/leaderboards/:category", Handler: handler.GetLeaderboardGinHandler, Versions: currentVersions,
+			Summary: "Rank players on goals, assists, minutes or discipline", Tag: "season stats", Query: db_utils.LeaderboardQuery{}, Response: db_utils.Leaderboard{},
+		},
+		{
+			Method: http.MethodGet, Path: "/positions", Handler: handler.GetPositionsGinHandler, Versions: currentVersions,
+			Summary: "List the canonical position taxonomy", Tag: "positions", Query: PositionsQuery{}, Response: []db_utils.PositionInfo{},
+		},
+		{
+			Method: http.MethodPost, Path: "/positions/add", Handler: handler.AddProfilePositionGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Add or update a position on a profile", Tag: "positions", Request: db_utils.AddProfilePosition{}, Response: db_utils.ProfilePosition{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/profiles/:id/career", Handler: handler.GetCareerGinHandler, Versions: currentVersions,
+			Summary: "A player's career timeline with transfers, loans, gaps, overlaps and output per club", Tag: "profiles", Response: db_utils.Career{},
+		},
+		{
+			Method: http.MethodGet, Path: "/profiles/:id/:slug", Handler: handler.GetProfileByIDGinHandler, Versions: allVersions,
+			Summary: "Get a player profile", Tag: "profiles", Query: db_utils.UnitsQuery{}, Response: db_utils.Profile{},
+		},
+		{
+			Method: http.MethodPost, Path: "/profiles/attributes/update", Handler: handler.UpdateProfileAttributesGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Update foot, playing style and body measurements", Tag: "profiles", Request: db_utils.UpdateProfileAttributes{}, Response: db_utils.Profile{},
+		},
+		{
+			Method: http.MethodPost, Path: "/profiles/nationalities/update", Handler: handler.UpdateNationalitiesGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Replace a player's nationalities and eligibility notes", Tag: "profiles", Request: db_utils.UpdateNationalities{}, Response: []db_utils.Nationality{},
+		},
+		{
+			Method: http.MethodPost, Path: "/physicaltests/add", Handler: handler.AddPhysicalTestToProfileGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Record a sprint, endurance or jump test result", Tag: "physical tests", Request: db_utils.AddPhysicalTest{}, Response: db_utils.PhysicalTest{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/skills/:id", Handler: handler.GetPlayerSkillsGinHandler, Versions: allVersions, Transforms: childResource,
+			Summary: "List a player's skills", Tag: "skills", Response: []db_utils.Skill{},
+		},
+		{
+			Method: http.MethodGet, Path: "/skills/catalogue", Handler: handler.GetSkillCatalogueGinHandler, Versions: currentVersions,
+			Summary: "List the skill catalogue", Tag: "skills", Response: []db_utils.SkillInfo{},
+		},
+		{
+			Method: http.MethodPost, Path: "/skills/:id/endorse", Handler: handler.EndorseSkillGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Endorse a skill as a verified coach or former teammate", Tag: "skills", Request: db_utils.EndorseSkill{}, Response: db_utils.SkillEndorsement{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodDelete, Path: "/skills/:id/endorse", Handler: handler.WithdrawSkillEndorsementGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Withdraw your endorsement of a skill", Tag: "skills", Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodGet, Path: "/skills/:id/endorsements", Handler: handler.GetSkillEndorsementsGinHandler, Versions: currentVersions,
+			Summary: "List the endorsements of a skill", Tag: "skills", Response: []db_utils.SkillEndorsement{},
+		},
+		{
+			Method: http.MethodPost, Path: "/skills/:id/assess", Handler: handler.AssessSkillGinHandler, Auth: true, Versions: currentVersions, Transforms: childResource,
+			Summary: "Rate a skill as a verified coach", Tag: "skills", Request: db_utils.AssessSkill{}, Response: db_utils.Skill{},
+		},
+		{
+			Method: http.MethodGet, Path: "/directory/search", Handler: handler.SearchDirectoryGinHandler, Versions: currentVersions,
+			Summary: "Autocomplete clubs, leagues and countries", Tag: "directory", Query: db_utils.DirectorySearchQuery{}, Response: []db_utils.DirectoryMatch{},
+		},
+		{
+			Method: http.MethodPost, Path: "/directory/aliases/add", Handler: handler.AddDirectoryAliasGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Add an alias to a club, league or country (admins only)", Tag: "directory", Request: db_utils.AddDirectoryAliasInput{}, Response: []db_utils.DirectoryAlias{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/countries", Handler: handler.GetCountriesGinHandler, Versions: currentVersions,
+			Summary: "List countries with localized names", Tag: "directory", Query: CountriesQuery{}, Response: []db_utils.Country{},
+		},
+		{
+			Method: http.MethodGet, Path: "/clubs/:id", Handler: handler.GetClubGinHandler, Versions: currentVersions,
+			Summary: "Get a club", Tag: "directory", Response: db_utils.Club{},
+		},
+		{
+			Method: http.MethodPost, Path: "/clubs/merge", Handler: handler.MergeClubsGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Merge a duplicate club into another (admins only)", Tag: "directory", Request: db_utils.MergeClubsInput{}, Response: db_utils.Club{},
+		},
+		{
+			Method: http.MethodPost, Path: "/clubs/admins/add", Handler: handler.AddClubAdminGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Make a user an admin of a club (admins only)", Tag: "verification", Request: db_utils.AddClubAdmin{}, Response: db_utils.ClubAdmin{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPost, Path: "/verifications/request", Handler: handler.RequestVerificationGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Ask a club to verify a club spell or season stat", Tag: "verification", Request: db_utils.RequestVerification{}, Response: db_utils.VerificationRequest{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/verifications", Handler: handler.GetVerificationRequestsGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List your verification requests and those sent to your clubs", Tag: "verification", Query: db_utils.VerificationQuery{}, Response: []db_utils.VerificationRequest{},
+		},
+		{
+			Method: http.MethodPost, Path: "/verifications/:id/review", Handler: handler.ReviewVerificationGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Approve or reject a verification request (club admins)", Tag: "verification", Request: db_utils.ReviewVerification{}, Response: db_utils.VerificationRequest{},
+		},
+		{
+			Method: http.MethodPost, Path: "/verifications/:id/cancel", Handler: handler.CancelVerificationGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Cancel a pending verification request", Tag: "verification", Response: db_utils.VerificationRequest{},
+		},
+		{
+			Method: http.MethodPost, Path: "/shortlists/create", Handler: handler.CreateShortlistGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Create a shortlist (scouts, coaches and club admins)", Tag: "shortlists", Request: db_utils.CreateShortlist{}, Response: db_utils.Shortlist{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/shortlists", Handler: handler.GetShortlistsGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List your own and shared shortlists", Tag: "shortlists", Response: []db_utils.Shortlist{},
+		},
+		{
+			Method: http.MethodGet, Path: "/shortlists/:id", Handler: handler.GetShortlistGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Get a shortlist with its players", Tag: "shortlists", Query: db_utils.ShortlistQuery{}, Response: db_utils.Shortlist{},
+		},
+		{
+			Method: http.MethodPost, Path: "/shortlists/:id/update", Handler: handler.UpdateShortlistGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Rename a shortlist", Tag: "shortlists", Request: db_utils.CreateShortlist{}, Response: db_utils.Shortlist{},
+		},
+		{
+			Method: http.MethodDelete, Path: "/shortlists/:id", Handler: handler.DeleteShortlistGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Delete a shortlist", Tag: "shortlists", Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodPost, Path: "/shortlists/:id/entries/add", Handler: handler.AddShortlistEntryGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Add a player to a shortlist", Tag: "shortlists", Request: db_utils.AddShortlistEntry{}, Response: db_utils.ShortlistEntry{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPost, Path: "/shortlists/:id/entries/:entry_id/update", Handler: handler.UpdateShortlistEntryGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Update the notes, rating or tags of a shortlisted player", Tag: "shortlists", Request: db_utils.UpdateShortlistEntry{}, Response: db_utils.ShortlistEntry{},
+		},
+		{
+			Method: http.MethodDelete, Path: "/shortlists/:id/entries/:entry_id", Handler: handler.RemoveShortlistEntryGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Remove a player from a shortlist", Tag: "shortlists", Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodPost, Path: "/shortlists/:id/reorder", Handler: handler.ReorderShortlistGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Reorder the players of a shortlist", Tag: "shortlists", Request: db_utils.ReorderShortlist{}, Response: []db_utils.ShortlistEntry{},
+		},
+		{
+			Method: http.MethodPost, Path: "/shortlists/:id/share", Handler: handler.ShareShortlistGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Share a shortlist read-only or editable", Tag: "shortlists", Request: db_utils.ShareShortlist{}, Response: db_utils.ShortlistShare{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodDelete, Path: "/shortlists/:id/share/:user_id", Handler: handler.UnshareShortlistGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Stop sharing a shortlist with a user", Tag: "shortlists", Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodGet, Path: "/shortlists/:id/export.csv", Handler: handler.ExportShortlistGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Download a shortlist as CSV", Tag: "shortlists", Query: db_utils.ShortlistQuery{},
+		},
+		{
+			Method: http.MethodPost, Path: "/searches/save", Handler: handler.SaveSearchGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Save a profile search and get alerted about new matches", Tag: "searches", Request: db_utils.SaveSearch{}, Response: db_utils.SavedSearch{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/searches", Handler: handler.GetSavedSearchesGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List your saved searches", Tag: "searches", Response: []db_utils.SavedSearch{},
+		},
+		{
+			Method: http.MethodPost, Path: "/searches/:id/update", Handler: handler.UpdateSavedSearchGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Update a saved search", Tag: "searches", Request: db_utils.SaveSearch{}, Response: db_utils.SavedSearch{},
+		},
+		{
+			Method: http.MethodDelete, Path: "/searches/:id", Handler: handler.DeleteSavedSearchGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Delete a saved search", Tag: "searches", Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodGet, Path: "/searches/:id/run", Handler: handler.RunSavedSearchGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List the profiles currently matching a saved search", Tag: "searches", Response: []db_utils.Profile{},
+		},
+		{
+			Method: http.MethodGet, Path: "/notifications", Handler: handler.GetNotificationsGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List your notifications", Tag: "notifications", Query: db_utils.NotificationsQuery{}, Response: db_utils.NotificationsResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/notifications/read-all", Handler: handler.MarkAllNotificationsReadGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Mark all notifications as read", Tag: "notifications", Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodPost, Path: "/notifications/:id/read", Handler: handler.MarkNotificationReadGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Mark a notification as read", Tag: "notifications", Response: db_utils.Notification{},
+		},
+		{
+			Method: http.MethodPost, Path: "/conversations/start", Handler: handler.StartConversationGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Message a user, starting a conversation if needed", Tag: "messages", Request: db_utils.StartConversation{}, Response: db_utils.Conversation{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/conversations", Handler: handler.GetConversationsGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List your conversations with unread counters", Tag: "messages", Response: db_utils.ConversationsResponse{},
+		},
+		{
+			Method: http.MethodGet, Path: "/conversations/:id", Handler: handler.GetConversationGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Get a conversation and its participants", Tag: "messages", Response: db_utils.Conversation{},
+		},
+		{
+			Method: http.MethodGet, Path: "/conversations/:id/messages", Handler: handler.GetMessagesGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List the messages of a conversation, newest first", Tag: "messages", Query: db_utils.MessagesQuery{}, Response: []db_utils.Message{},
+		},
+		{
+			Method: http.MethodPost, Path: "/conversations/:id/messages", Handler: handler.SendMessageGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Send a message to a conversation", Tag: "messages", Request: db_utils.SendMessage{}, Response: db_utils.Message{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPost, Path: "/conversations/:id/read", Handler: handler.MarkConversationReadGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Mark a conversation as read", Tag: "messages", Response: db_utils.Conversation{},
+		},
+		{
+			Method: http.MethodGet, Path: "/users/blocks", Handler: handler.GetBlockedUsersGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List the users you have blocked", Tag: "messages", Response: []db_utils.UserBlock{},
+		},
+		{
+			Method: http.MethodPost, Path: "/users/:id/block", Handler: handler.BlockUserGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Block a user from messaging you", Tag: "messages", Response: db_utils.UserBlock{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodDelete, Path: "/users/:id/block", Handler: handler.UnblockUserGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Unblock a user", Tag: "messages", Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodPost, Path: "/users/:id/report", Handler: handler.ReportUserGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Report a user to the admins", Tag: "messages", Request: db_utils.ReportUser{}, Response: db_utils.UserReport{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/reports", Handler: handler.GetReportsGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List user reports (admins only)", Tag: "messages", Query: db_utils.ReportsQuery{}, Response: []db_utils.UserReport{},
+		},
+		{
+			Method: http.MethodPost, Path: "/reports/:id/resolve", Handler: handler.ResolveReportGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Mark a user report as reviewed (admins only)", Tag: "messages", Response: db_utils.UserReport{},
+		},
+		{
+			Method: http.MethodPost, Path: "/guardians/request", Handler: handler.RequestGuardianConsentGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Ask a parent or guardian to consent to your profile being public", Tag: "guardians", Request: db_utils.RequestGuardianConsent{}, Response: db_utils.GuardianLink{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPost, Path: "/guardians/consent", Handler: handler.GiveGuardianConsentGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Consent as guardian using the token from the consent e-mail", Tag: "guardians", Request: db_utils.GiveGuardianConsent{}, Response: db_utils.GuardianLink{},
+		},
+		{
+			Method: http.MethodGet, Path: "/guardians/wards", Handler: handler.GetWardsGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List the profiles you are guardian of", Tag: "guardians", Response: []db_utils.Profile{},
+		},
+		{
+			Method: http.MethodGet, Path: "/guardians/profiles/:id", Handler: handler.GetGuardianLinkGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Get the guardian of a profile (player and guardian only)", Tag: "guardians", Response: db_utils.GuardianLink{},
+		},
+		{
+			Method: http.MethodDelete, Path: "/guardians/profiles/:id", Handler: handler.RemoveGuardianGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Withdraw guardian consent, hiding a minor's profile again", Tag: "guardians", Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodPost, Path: "/trials/create", Handler: handler.CreateTrialEventGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Announce a trial (club admins only)", Tag: "trials", Request: db_utils.SaveTrialEvent{}, Response: db_utils.TrialEvent{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/trials", Handler: handler.GetTrialEventsGinHandler, Versions: currentVersions,
+			Summary: "List upcoming trials", Tag: "trials", Query: db_utils.TrialEventsQuery{}, Response: []db_utils.TrialEvent{},
+		},
+		{
+			Method: http.MethodGet, Path: "/trials/:id", Handler: handler.GetTrialEventGinHandler, Versions: currentVersions,
+			Summary: "Get a trial", Tag: "trials", Response: db_utils.TrialEvent{},
+		},
+		{
+			Method: http.MethodPost, Path: "/trials/:id/update", Handler: handler.UpdateTrialEventGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Update a trial (club admins only)", Tag: "trials", Request: db_utils.SaveTrialEvent{}, Response: db_utils.TrialEvent{},
+		},
+		{
+			Method: http.MethodDelete, Path: "/trials/:id", Handler: handler.CancelTrialEventGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Cancel a trial and notify its applicants (club admins only)", Tag: "trials", Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodPost, Path: "/trials/:id/apply", Handler: handler.ApplyToTrialGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Apply to a trial with your profile", Tag: "trials", Request: db_utils.ApplyToTrial{}, Response: db_utils.TrialApplication{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/trials/:id/applications", Handler: handler.GetTrialApplicationsGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List the applications to a trial (club admins only)", Tag: "trials", Query: db_utils.TrialApplicationsQuery{}, Response: []db_utils.TrialApplication{},
+		},
+		{
+			Method: http.MethodGet, Path: "/trials/applications/mine", Handler: handler.GetMyTrialApplicationsGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "List your trial applications", Tag: "trials", Response: []db_utils.TrialApplication{},
+		},
+		{
+			Method: http.MethodGet, Path: "/trials/applications/mine.ics", Handler: handler.GetMyTrialCalendarGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Download the trials you applied to as an iCalendar file", Tag: "trials",
+		},
+		{
+			Method: http.MethodPost, Path: "/trials/applications/:id/status", Handler: handler.UpdateApplicationStatusGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Invite, reject or make an offer to an applicant (club admins only)", Tag: "trials", Request: db_utils.UpdateApplicationStatus{}, Response: db_utils.TrialApplication{},
+		},
+		{
+			Method: http.MethodPost, Path: "/trials/applications/:id/withdraw", Handler: handler.WithdrawApplicationGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Withdraw your trial application", Tag: "trials", Response: db_utils.TrialApplication{},
+		},
+		{
+			Method: http.MethodPost, Path: "/matches/add", Handler: handler.AddMatchGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Log a played or upcoming match", Tag: "matches", Request: db_utils.AddMatch{}, Response: db_utils.Match{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/matches", Handler: handler.GetMatchesGinHandler, Versions: currentVersions,
+			Summary: "List a player's logged matches", Tag: "matches", Query: db_utils.MatchesQuery{}, Response: []db_utils.Match{},
+		},
+		{
+			Method: http.MethodDelete, Path: "/matches/:id", Handler: handler.DeleteMatchGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Delete a logged match", Tag: "matches", Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodPost, Path: "/calendar/feed", Handler: handler.RotateCalendarFeedGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Create your calendar feed or rotate its secret URL", Tag: "calendar", Request: db_utils.CreateCalendarFeed{}, Response: db_utils.CalendarFeed{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/calendar/feed", Handler: handler.GetCalendarFeedGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Get your calendar feed settings", Tag: "calendar", Response: db_utils.CalendarFeed{},
+		},
+		{
+			Method: http.MethodDelete, Path: "/calendar/feed", Handler: handler.DeleteCalendarFeedGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Turn off your calendar feed", Tag: "calendar", Status: http.StatusNoContent,
+		},
+		{
+			Method: http.MethodGet, Path: "/calendar/feeds/:file", Handler: handler.ServeCalendarFeedGinHandler, Versions: currentVersions,
+			Summary: "iCalendar feed of trials, matches and return-to-play dates (file is <token>.ics)", Tag: "calendar",
+		},
+		{
+			Method: http.MethodGet, Path: "/users/:id", Handler: handler.GetUserByIDGinHandler, OptionalAuth: true, Versions: allVersions,
+			Summary: "Get a user (the e-mail address is only returned to the user themselves)", Tag: "users", Response: db_utils.User{},
+		},
+		{
+			Method: http.MethodPost, Path: "/users/create", Handler: handler.CreateUserGinHandler, Versions: allVersions,
+			Summary: "Register a user", Tag: "users", Request: db_utils.CreateUserInput{}, Response: db_utils.User{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPost, Path: "/users/login", Handler: handler.LoginUserGinHandler, Versions: allVersions,
+			Summary: "Log in and obtain a JWT", Tag: "users", Request: db_utils.LoginUserInput{}, Response: db_utils.LoginResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/users/verify", Handler: handler.VerifyUserGinHandler, Auth: true, Versions: currentVersions,
+			Summary: "Verify a user and set their role (admins only)", Tag: "users", Request: db_utils.VerifyUser{}, Response: db_utils.User{},
+		},
+	}
+}
+
+// versionedPath returns where route is served for version. Legacy routes keep
+// their original locations: authorized ones under /api, public ones at the root.
+func versionedPath(version APIVersion, route Route) string {
+	if version.Name != VersionLegacy {
+		return version.BasePath + route.Path
+	}
+	if route.Auth {
+		return "/api" + route.Path
+	}
+	return route.Path
+}
+
+// mount registers route on every version it declares.
+func mount(router *gin.Engine, route Route) {
+	for _, name := range route.Versions {
+		version, ok := findVersion(name)
+		if !ok {
+			log.Fatalf("Route %s %s declares unknown API version %q", route.Method, route.Path, name)
+		}
+
+		path := versionedPath(version, route)
+		chain := []gin.HandlerFunc{VersionHeaders(version)}
+		if route.Auth {
+			chain = append(chain, auth.AuthMiddleware())
+		} else if route.OptionalAuth {
+			chain = append(chain, auth.OptionalAuthMiddleware())
+		}
+		if transform, ok := route.Transforms[name]; ok {
+			chain = append(chain, ApplyTransform(transform))
+		}
+		chain = append(chain, route.Handler)
+
+		router.Handle(route.Method, path, chain...)
+	}
+}
+
+// NewRouter builds the gin engine with every versioned route mounted.
+func NewRouter(handler *db_utils.DBHandler) *gin.Engine {
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	router.Use(gin.Logger(), api_errors.Recovery(), api_errors.RequestID(), api_errors.Handler())
+	router.NoRoute(api_errors.NoRoute)
+	router.NoMethod(api_errors.NoMethod)
+
+	table := routeTable(handler)
+	for _, route := range table {
+		mount(router, route)
+	}
+
+	router.GET("/api/versions", GetVersionsGinHandler)
+
+	doc := buildSpec(table)
+	router.GET("/openapi.json", openapi.SpecGinHandler(doc))
+	router.GET("/docs", openapi.DocsGinHandler)
+	router.GET("/redoc", openapi.RedocGinHandler)
+	router.GET(openapi.AssetsPath+"/*filepath", openapi.AssetsGinHandler)
+
+	if err := openapi.CheckDrift(router.Routes(), doc, undocumentedPaths...); err != nil {
+		log.Fatal(err)
+	}
+
+	return router
+}
+
 func RegisterRoutes() {
 
+	geocoder, err := geo.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load the gazetteer: %v", err)
+	}
+	db_utils.SetGeocoder(geocoder)
+
 	db, err := db_utils.ConnectAndMigrate()
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
 	// Inject the DB connection into the handler struct
-	handler := &db_utils.DBHandler{DB: db} 
-
-	router := gin.Default()
-
-	authorized := router.Group("/api")
-	authorized.Use(auth.AuthMiddleware())
-	{
-		authorized.POST("/profiles/create", handler.CreateProfileGinHandler)
-		authorized.POST("/skills/add", handler.AddSkillToProfileGinHandler)
-		authorized.POST("/achievements/add", handler.AddAchievementToProfileGinHandler)
-		authorized.POST("/injury/add", handler.AddInjuryToProfileGinHandler)
-		authorized.POST("/sociallink/add", handler.AddSocialLinkToProfileGinHandler)
-		authorized.POST("/clubprofile/add", handler.AddClubProfileToProfileGinHandler)
-		authorized.POST("/seasonstats/add", handler.AddSeasonStatToProfileGinHandler)
-	}
-	
-
-	router.GET("/profiles", handler.GetProfilesGinHandler)
-	router.GET("/profiles/:id/:slug", handler.GetProfileByIDGinHandler)
-	router.GET("/skills/:id", handler.GetPlayerSkillsGinHandler)
-	// router.POST("/profiles/create", handler.CreateProfileGinHandler)
-	router.GET("/users/:id", handler.GetUserByIDGinHandler)
-	router.POST("/users/create", handler.CreateUserGinHandler)
-	router.POST("/users/login", handler.LoginUserGinHandler)
-	// router.POST("/skills/add", handler.AddSkillToProfileGinHandler)
+	handler := &db_utils.DBHandler{DB: db}
+
+	scheduler := jobs.NewScheduler()
+	scheduler.Every("saved-search-alerts", 15*time.Minute, func(ctx context.Context) error {
+		return db_utils.RunSavedSearchAlerts(ctx, db)
+	})
+	scheduler.Every("contract-expiry-alerts", time.Hour, func(ctx context.Context) error {
+		return db_utils.RunContractExpiryAlerts(ctx, db)
+	})
+	scheduler.Every("similar-players", 6*time.Hour, func(ctx context.Context) error {
+		return db_utils.RunSimilarPlayers(ctx, db)
+	})
+	scheduler.Every("webhook-deliveries", time.Minute, func(ctx context.Context) error {
+		return db_utils.RunWebhookDeliveries(ctx, db)
+	})
+	scheduler.Start(context.Background())
+
+	router := NewRouter(handler)
 
 	router.Run("localhost:8081")
-}
\ No newline at end of file
+}
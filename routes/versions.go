@@ -0,0 +1,188 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	VersionStatusCurrent    = "current"
+	VersionStatusPreview    = "preview"
+	VersionStatusDeprecated = "deprecated"
+)
+
+// APIVersion describes one mounted version of the API and its lifecycle.
+type APIVersion struct {
+	Name         string     `json:"version"`
+	BasePath     string     `json:"base_path"`
+	Status       string     `json:"status"`
+	DeprecatedAt *time.Time `json:"deprecated_at,omitempty"`
+	Sunset       *time.Time `json:"sunset,omitempty"`
+	Successor    string     `json:"successor,omitempty"`
+}
+
+// Transform rewrites the decoded JSON body of a successful response so that a
+// single handler can serve several versions of the same contract.
+type Transform func(body any) any
+
+const (
+	VersionLegacy = "legacy"
+	VersionV1     = "v1"
+	VersionV2     = "v2"
+)
+
+var (
+	legacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	legacySunset       = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// Versions lists every API version the server mounts, oldest first.
+// The legacy entry covers the original unversioned paths (/profiles,
+// /api/profiles/create, ...) which are kept alive until their sunset date.
+var Versions = []APIVersion{
+	{
+		Name:         VersionLegacy,
+		BasePath:     "/",
+		Status:       VersionStatusDeprecated,
+		DeprecatedAt: &legacyDeprecatedAt,
+		Sunset:       &legacySunset,
+		Successor:    VersionV1,
+	},
+	{Name: VersionV1, BasePath: "/api/v1", Status: VersionStatusCurrent},
+	{Name: VersionV2, BasePath: "/api/v2", Status: VersionStatusPreview},
+}
+
+func findVersion(name string) (APIVersion, bool) {
+	for _, v := range Versions {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return APIVersion{}, false
+}
+
+// successorPath maps a legacy request path onto the v1 tree, e.g.
+// /profiles -> /api/v1/profiles and /api/skills/add -> /api/v1/skills/add.
+func successorPath(path string) string {
+	return "/api/v1" + strings.TrimPrefix(path, "/api")
+}
+
+// VersionHeaders tags every response with the API version that served it and,
+// for deprecated versions, the Deprecation (RFC 9745) and Sunset (RFC 8594)
+// headers plus a link to the successor resource.
+func VersionHeaders(v APIVersion) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("API-Version", v.Name)
+		if v.Status == VersionStatusDeprecated {
+			if v.DeprecatedAt != nil {
+				c.Header("Deprecation", "@"+strconv.FormatInt(v.DeprecatedAt.Unix(), 10))
+			} else {
+				c.Header("Deprecation", "true")
+			}
+			if v.Sunset != nil {
+				c.Header("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if v.Successor != "" {
+				c.Header("Link", "<"+successorPath(c.Request.URL.Path)+`>; rel="successor-version"`)
+			}
+		}
+		c.Next()
+	}
+}
+
+// bufferedWriter holds the response back so a Transform can rewrite it.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (w *bufferedWriter) WriteHeader(code int) { w.status = code }
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) { return w.body.Write(b) }
+
+func (w *bufferedWriter) WriteString(s string) (int, error) { return w.body.WriteString(s) }
+
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int { return w.body.Len() }
+
+func (w *bufferedWriter) Written() bool { return w.status != 0 || w.body.Len() > 0 }
+
+// ApplyTransform runs the handler chain against a buffered writer and applies
+// fn to successful JSON responses before they are sent to the client.
+func ApplyTransform(fn Transform) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original}
+		c.Writer = buffered
+
+		c.Next()
+
+		c.Writer = original
+		if !buffered.Written() {
+			// Nothing to rewrite, e.g. the handler aborted with an error that
+			// api_errors.Handler renders further up the chain.
+			return
+		}
+		status := buffered.Status()
+		body := buffered.body.Bytes()
+
+		isJSON := strings.HasPrefix(original.Header().Get("Content-Type"), "application/json")
+		if status >= 200 && status < 300 && isJSON {
+			var decoded any
+			if err := json.Unmarshal(body, &decoded); err == nil {
+				if rewritten, err := json.Marshal(fn(decoded)); err == nil {
+					body = rewritten
+				}
+			}
+		}
+
+		original.WriteHeader(status)
+		original.Write(body)
+	}
+}
+
+// OmitEmbeddedProfile drops the "profile" object that child resources (skills,
+// achievements, injuries, ...) embed. v2 clients already know which profile
+// they are working with and only need profile_id.
+func OmitEmbeddedProfile(body any) any {
+	switch v := body.(type) {
+	case []any:
+		for i := range v {
+			v[i] = OmitEmbeddedProfile(v[i])
+		}
+		return v
+	case map[string]any:
+		if _, ok := v["profile_id"]; ok {
+			delete(v, "profile")
+		}
+		return v
+	default:
+		return body
+	}
+}
+
+type VersionsResponse struct {
+	Current  string       `json:"current"`
+	Versions []APIVersion `json:"versions"`
+}
+
+// GetVersionsGinHandler lists the mounted API versions so clients can discover
+// which ones exist and when deprecated ones stop being served.
+func GetVersionsGinHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, VersionsResponse{Current: VersionV1, Versions: Versions})
+}
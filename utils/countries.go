@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// ISOCountryCodes lists every ISO 3166-1 alpha-2 code.
+var ISOCountryCodes = strings.Fields(`
+	AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ
+	BL BM BN BO BQ BR BS BT BV BW BY BZ CA CC CD CF CG CH CI CK CL CM CN CO CR
+	CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE EG EH ER ES ET FI FJ FK FM FO FR
+	GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM HN HR HT HU
+	ID IE IL IM IN IO IQ IR IS IT JE JM JO JP KE KG KH KI KM KN KP KR KW KY KZ
+	LA LB LC LI LK LR LS LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN MO MP MQ
+	MR MS MT MU MV MW MX MY MZ NA NC NE NF NG NI NL NO NP NR NU NZ OM PA PE PF
+	PG PH PK PL PM PN PR PS PT PW PY QA RE RO RS RU RW SA SB SC SD SE SG SH SI
+	SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO TR
+	TT TV TW TZ UA UG UM US UY UZ VA VC VE VG VI VN VU WF WS YE YT ZA ZM ZW
+`)
+
+// FootballNations are the ISO 3166-2 subdivisions that field their own
+// national teams and run their own league systems.
+var FootballNations = map[string]string{
+	"GB-ENG": "England",
+	"GB-SCT": "Scotland",
+	"GB-WLS": "Wales",
+	"GB-NIR": "Northern Ireland",
+}
+
+// CountryName returns the name of an ISO 3166-1 alpha-2 code or a football
+// nation code in lang (a BCP 47 tag such as "fr"), falling back to English.
+func CountryName(code, lang string) string {
+	if name, ok := FootballNations[code]; ok {
+		return name
+	}
+	region, err := language.ParseRegion(code)
+	if err != nil {
+		return code
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		tag = language.English
+	}
+	if name := display.Regions(tag).Name(region); name != "" {
+		return name
+	}
+	return display.English.Regions().Name(region)
+}
+
+// Demonyms are the adjectives players commonly give as their nationality,
+// keyed by country code. Ambiguous ones ("Congolese", "Guinean") are left out.
+var Demonyms = map[string][]string{
+	"AF":     {"Afghan"},
+	"AL":     {"Albanian"},
+	"DZ":     {"Algerian"},
+	"AD":     {"Andorran"},
+	"AO":     {"Angolan"},
+	"AR":     {"Argentine", "Argentinian"},
+	"AM":     {"Armenian"},
+	"AU":     {"Australian"},
+	"AT":     {"Austrian"},
+	"AZ":     {"Azerbaijani"},
+	"BH":     {"Bahraini"},
+	"BD":     {"Bangladeshi"},
+	"BB":     {"Barbadian", "Bajan"},
+	"BY":     {"Belarusian"},
+	"BE":     {"Belgian"},
+	"BJ":     {"Beninese"},
+	"BM":     {"Bermudian"},
+	"BO":     {"Bolivian"},
+	"BA":     {"Bosnian"},
+	"BR":     {"Brazilian"},
+	"BG":     {"Bulgarian"},
+	"BF":     {"Burkinabe"},
+	"BI":     {"Burundian"},
+	"CM":     {"Cameroonian"},
+	"CA":     {"Canadian"},
+	"CV":     {"Cape Verdean"},
+	"CL":     {"Chilean"},
+	"CN":     {"Chinese"},
+	"CO":     {"Colombian"},
+	"CR":     {"Costa Rican"},
+	"CI":     {"Ivorian"},
+	"HR":     {"Croatian", "Croat"},
+	"CU":     {"Cuban"},
+	"CW":     {"Curacaoan"},
+	"CY":     {"Cypriot"},
+	"CZ":     {"Czech"},
+	"DK":     {"Danish", "Dane"},
+	"EC":     {"Ecuadorian"},
+	"EG":     {"Egyptian"},
+	"SV":     {"Salvadoran"},
+	"EE":     {"Estonian"},
+	"ET":     {"Ethiopian"},
+	"FO":     {"Faroese"},
+	"FJ":     {"Fijian"},
+	"FI":     {"Finnish", "Finn"},
+	"FR":     {"French"},
+	"GA":     {"Gabonese"},
+	"GM":     {"Gambian"},
+	"GE":     {"Georgian"},
+	"DE":     {"German"},
+	"GH":     {"Ghanaian"},
+	"GI":     {"Gibraltarian"},
+	"GR":     {"Greek"},
+	"GD":     {"Grenadian"},
+	"GT":     {"Guatemalan"},
+	"GY":     {"Guyanese"},
+	"HT":     {"Haitian"},
+	"HN":     {"Honduran"},
+	"HK":     {"Hong Konger"},
+	"HU":     {"Hungarian"},
+	"IS":     {"Icelandic", "Icelander"},
+	"IN":     {"Indian"},
+	"ID":     {"Indonesian"},
+	"IR":     {"Iranian"},
+	"IQ":     {"Iraqi"},
+	"IE":     {"Irish"},
+	"IL":     {"Israeli"},
+	"IT":     {"Italian"},
+	"JM":     {"Jamaican"},
+	"JP":     {"Japanese"},
+	"JO":     {"Jordanian"},
+	"KZ":     {"Kazakh", "Kazakhstani"},
+	"KE":     {"Kenyan"},
+	"KW":     {"Kuwaiti"},
+	"KG":     {"Kyrgyz"},
+	"LV":     {"Latvian"},
+	"LB":     {"Lebanese"},
+	"LR":     {"Liberian"},
+	"LY":     {"Libyan"},
+	"LI":     {"Liechtensteiner"},
+	"LT":     {"Lithuanian"},
+	"LU":     {"Luxembourgish", "Luxembourger"},
+	"MG":     {"Malagasy"},
+	"MW":     {"Malawian"},
+	"MY":     {"Malaysian"},
+	"ML":     {"Malian"},
+	"MT":     {"Maltese"},
+	"MR":     {"Mauritanian"},
+	"MX":     {"Mexican"},
+	"MD":     {"Moldovan"},
+	"MN":     {"Mongolian"},
+	"ME":     {"Montenegrin"},
+	"MA":     {"Moroccan"},
+	"MZ":     {"Mozambican"},
+	"NA":     {"Namibian"},
+	"NP":     {"Nepali", "Nepalese"},
+	"NL":     {"Dutch"},
+	"NZ":     {"New Zealander", "Kiwi"},
+	"NI":     {"Nicaraguan"},
+	"NE":     {"Nigerien"},
+	"NG":     {"Nigerian"},
+	"MK":     {"Macedonian"},
+	"NO":     {"Norwegian"},
+	"OM":     {"Omani"},
+	"PK":     {"Pakistani"},
+	"PS":     {"Palestinian"},
+	"PA":     {"Panamanian"},
+	"PY":     {"Paraguayan"},
+	"PE":     {"Peruvian"},
+	"PH":     {"Filipino", "Philippine"},
+	"PL":     {"Polish", "Pole"},
+	"PT":     {"Portuguese"},
+	"PR":     {"Puerto Rican"},
+	"QA":     {"Qatari"},
+	"RO":     {"Romanian"},
+	"RU":     {"Russian"},
+	"RW":     {"Rwandan"},
+	"SA":     {"Saudi", "Saudi Arabian"},
+	"SN":     {"Senegalese"},
+	"RS":     {"Serbian", "Serb"},
+	"SL":     {"Sierra Leonean"},
+	"SG":     {"Singaporean"},
+	"SK":     {"Slovak", "Slovakian"},
+	"SI":     {"Slovenian", "Slovene"},
+	"SO":     {"Somali"},
+	"ZA":     {"South African"},
+	"KR":     {"South Korean"},
+	"SS":     {"South Sudanese"},
+	"ES":     {"Spanish", "Spaniard"},
+	"LK":     {"Sri Lankan"},
+	"SD":     {"Sudanese"},
+	"SR":     {"Surinamese"},
+	"SE":     {"Swedish", "Swede"},
+	"CH":     {"Swiss"},
+	"SY":     {"Syrian"},
+	"TW":     {"Taiwanese"},
+	"TJ":     {"Tajik"},
+	"TZ":     {"Tanzanian"},
+	"TH":     {"Thai"},
+	"TG":     {"Togolese"},
+	"TT":     {"Trinidadian", "Tobagonian"},
+	"TN":     {"Tunisian"},
+	"TR":     {"Turkish", "Turk"},
+	"TM":     {"Turkmen"},
+	"UG":     {"Ugandan"},
+	"UA":     {"Ukrainian"},
+	"AE":     {"Emirati"},
+	"GB":     {"British", "Briton"},
+	"US":     {"American"},
+	"UY":     {"Uruguayan"},
+	"UZ":     {"Uzbek"},
+	"VE":     {"Venezuelan"},
+	"VN":     {"Vietnamese"},
+	"YE":     {"Yemeni"},
+	"ZM":     {"Zambian"},
+	"ZW":     {"Zimbabwean"},
+	"GB-ENG": {"English"},
+	"GB-SCT": {"Scottish", "Scots"},
+	"GB-WLS": {"Welsh"},
+	"GB-NIR": {"Northern Irish"},
+}
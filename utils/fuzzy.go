@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// nameAbbreviations expands shorthand that is common in club and league names.
+var nameAbbreviations = map[string]string{
+	"utd":   "united",
+	"st":    "saint",
+	"ath":   "athletic",
+	"intl":  "international",
+	"wand":  "wanderers",
+	"div":   "division",
+	"prem":  "premier",
+	"lge":   "league",
+	"champ": "championship",
+}
+
+// nameNoise are tokens that carry no identity ("FC Barcelona" is "Barcelona").
+var nameNoise = map[string]bool{
+	"fc": true, "cf": true, "afc": true, "sc": true, "sv": true, "fk": true,
+	"club": true, "football": true, "the": true, "de": true, "cd": true,
+}
+
+// NormalizeName folds a club, league or country name into the form used for
+// matching: lower case, no accents or punctuation, abbreviations expanded and
+// noise words such as "FC" dropped. "Man. Utd F.C." becomes "man united".
+func NormalizeName(s string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+	if err != nil {
+		folded = s
+	}
+	folded = strings.ToLower(folded)
+	folded = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			return r
+		case r == '.' || r == '\'':
+			// "F.C." is "fc", "Newell's" is "newells".
+			return -1
+		default:
+			return ' '
+		}
+	}, folded)
+
+	tokens := make([]string, 0, 4)
+	for _, token := range strings.Fields(folded) {
+		if expanded, ok := nameAbbreviations[token]; ok {
+			token = expanded
+		}
+		if nameNoise[token] {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	if len(tokens) == 0 {
+		// A name made only of noise words ("Club de Futbol") is still a name.
+		return strings.Join(strings.Fields(folded), " ")
+	}
+	return strings.Join(tokens, " ")
+}
+
+func trigrams(s string) map[string]bool {
+	grams := map[string]bool{}
+	for _, word := range strings.Fields(s) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			grams[string(padded[i:i+3])] = true
+		}
+	}
+	return grams
+}
+
+// Similarity scores two normalized names between 0 and 1 using trigram
+// overlap, the same measure as PostgreSQL's pg_trgm.
+func Similarity(a, b string) float64 {
+	ga, gb := trigrams(a), trigrams(b)
+	if len(ga) == 0 || len(gb) == 0 {
+		return 0
+	}
+	shared := 0
+	for g := range ga {
+		if gb[g] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ga)+len(gb)-shared)
+}
+
+// MatchScore rates how well name answers query for autocomplete. Every query
+// word that starts a word of name counts as a full match, so "man u" finds
+// "manchester united"; otherwise trigram similarity catches typos.
+func MatchScore(query, name string) float64 {
+	if query == "" || name == "" {
+		return 0
+	}
+	if query == name {
+		return 1
+	}
+	nameWords := strings.Fields(name)
+	queryWords := strings.Fields(query)
+	prefixed := 0
+	for _, q := range queryWords {
+		for _, w := range nameWords {
+			if strings.HasPrefix(w, q) {
+				prefixed++
+				break
+			}
+		}
+	}
+	score := Similarity(query, name)
+	if prefixed == len(queryWords) {
+		// Rank prefix matches above fuzzy ones, tighter ones first.
+		score = 0.6 + 0.4*score
+	}
+	return score
+}
+
+// SearchPrefixes returns the short prefixes of each query word, used to narrow
+// candidates in SQL before scoring them with MatchScore.
+func SearchPrefixes(query string, length int) []string {
+	var prefixes []string
+	for _, word := range strings.Fields(query) {
+		r := []rune(word)
+		if len(r) > length {
+			r = r[:length]
+		}
+		prefixes = append(prefixes, string(r))
+	}
+	return prefixes
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// iCalendar (RFC 5545) output. Times are written in UTC, which every client
+// converts to the viewer's zone without needing VTIMEZONE definitions.
+
+const (
+	icsDateTime   = "20060102T150405Z"
+	icsDate       = "20060102"
+	icsLineOctets = 75
+)
+
+// Event statuses defined by RFC 5545.
+const (
+	EventConfirmed = "CONFIRMED"
+	EventTentative = "TENTATIVE"
+	EventCancelled = "CANCELLED"
+)
+
+// CalendarEvent is a VEVENT. AllDay events use the dates of Start and End
+// only; End is exclusive, so a one-day event ends the next day.
+type CalendarEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Status      string
+	Updated     time.Time
+}
+
+// Calendar is a VCALENDAR published as a read-only feed. TimeZone is the
+// zone clients should display it in; Refresh how often they should poll.
+type Calendar struct {
+	Name     string
+	TimeZone string
+	Refresh  time.Duration
+	Events   []CalendarEvent
+}
+
+// String renders the calendar with CRLF line endings and folded lines.
+func (cal Calendar) String() string {
+	var b strings.Builder
+	line := func(name, value string) {
+		writeICSLine(&b, name+":"+value)
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//ballerbio//Player calendar//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if cal.Name != "" {
+		line("NAME", EscapeICSText(cal.Name))
+		line("X-WR-CALNAME", EscapeICSText(cal.Name))
+	}
+	if cal.TimeZone != "" {
+		line("X-WR-TIMEZONE", cal.TimeZone)
+	}
+	if cal.Refresh > 0 {
+		interval := fmt.Sprintf("PT%dM", int(cal.Refresh.Minutes()))
+		line("REFRESH-INTERVAL;VALUE=DURATION", interval)
+		line("X-PUBLISHED-TTL", interval)
+	}
+
+	stamp := time.Now().UTC().Format(icsDateTime)
+	for _, event := range cal.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", event.UID)
+		line("DTSTAMP", stamp)
+		if event.AllDay {
+			end := event.End
+			if !end.After(event.Start) {
+				end = event.Start.AddDate(0, 0, 1)
+			}
+			line("DTSTART;VALUE=DATE", event.Start.Format(icsDate))
+			line("DTEND;VALUE=DATE", end.Format(icsDate))
+		} else {
+			line("DTSTART", event.Start.UTC().Format(icsDateTime))
+			if !event.End.IsZero() {
+				line("DTEND", event.End.UTC().Format(icsDateTime))
+			}
+		}
+		if !event.Updated.IsZero() {
+			line("LAST-MODIFIED", event.Updated.UTC().Format(icsDateTime))
+		}
+		line("SUMMARY", EscapeICSText(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", EscapeICSText(event.Description))
+		}
+		if event.Location != "" {
+			line("LOCATION", EscapeICSText(event.Location))
+		}
+		if event.Status != "" {
+			line("STATUS", event.Status)
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return b.String()
+}
+
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// EscapeICSText escapes a TEXT property value.
+func EscapeICSText(s string) string {
+	return icsTextEscaper.Replace(s)
+}
+
+// writeICSLine writes a content line, folding it so that no line exceeds 75
+// octets. Continuation lines start with a space and multi-byte characters
+// are never split.
+func writeICSLine(b *strings.Builder, s string) {
+	limit := icsLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = icsLineOctets - 1
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestWriteICSLine(t *testing.T) {
+	a := func(n int) string { return strings.Repeat("a", n) }
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"short", "SUMMARY:Trial", "SUMMARY:Trial\r\n"},
+		{"exactly 75 octets", a(75), a(75) + "\r\n"},
+		{"76 octets", a(76), a(75) + "\r\n a\r\n"},
+		{"continuations hold 74 octets", a(75 + 74 + 1), a(75) + "\r\n " + a(74) + "\r\n a\r\n"},
+		{"multi-byte character at the fold", a(74) + "é", a(74) + "\r\n é\r\n"},
+		{"multi-byte character before the fold", a(73) + "é" + "b", a(73) + "é\r\n b\r\n"},
+		{"three-byte character across the fold", a(73) + "⚽⚽", a(73) + "\r\n ⚽⚽\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			writeICSLine(&b, tt.line)
+			got := b.String()
+			if got != tt.want {
+				t.Errorf("writeICSLine(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n") {
+				if len(line) > icsLineOctets || !utf8.ValidString(line) {
+					t.Errorf("folded line %q is %d octets or splits a character", line, len(line))
+				}
+			}
+			if unfolded := strings.ReplaceAll(strings.TrimSuffix(got, "\r\n"), "\r\n ", ""); unfolded != tt.line {
+				t.Errorf("unfolding gives %q, want %q", unfolded, tt.line)
+			}
+		})
+	}
+}
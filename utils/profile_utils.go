@@ -2,6 +2,7 @@ package utils
 
 import (
 	"strings"
+	"time"
 )
 
 func ProfileSlugify(firstName, lastName string) string {
@@ -10,4 +11,23 @@ func ProfileSlugify(firstName, lastName string) string {
 	slug := strings.ToLower(fullName)
 	slug = strings.ReplaceAll(slug, " ", "-")
 	return slug
-}
\ No newline at end of file
+}
+
+// AgeOn returns the age in whole years of someone born on dob at the date at.
+func AgeOn(dob, at time.Time) int {
+	age := at.Year() - dob.Year()
+	if at.Month() < dob.Month() || (at.Month() == dob.Month() && at.Day() < dob.Day()) {
+		age--
+	}
+	return age
+}
+
+// CoarseLocation reduces a "Town, Region, Country" location to its last,
+// broadest part. Single-part locations are dropped entirely.
+func CoarseLocation(location string) string {
+	i := strings.LastIndex(location, ",")
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSpace(location[i+1:])
+}
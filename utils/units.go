@@ -0,0 +1,54 @@
+package utils
+
+import "math"
+
+const (
+	UnitSystemMetric   = "metric"
+	UnitSystemImperial = "imperial"
+
+	UnitCentimetre = "cm"
+	UnitInch       = "in"
+	UnitKilogram   = "kg"
+	UnitPound      = "lb"
+
+	centimetresPerInch = 2.54
+	kilogramsPerPound  = 0.45359237
+)
+
+// HeightToCm converts a height given in unit ("cm" or "in") to centimetres.
+func HeightToCm(value float64, unit string) float64 {
+	if unit == UnitInch {
+		return value * centimetresPerInch
+	}
+	return value
+}
+
+// WeightToKg converts a weight given in unit ("kg" or "lb") to kilograms.
+func WeightToKg(value float64, unit string) float64 {
+	if unit == UnitPound {
+		return value * kilogramsPerPound
+	}
+	return value
+}
+
+// HeightFromCm converts centimetres into the height unit of system.
+func HeightFromCm(cm float64, system string) (float64, string) {
+	if system == UnitSystemImperial {
+		return Round(cm/centimetresPerInch, 1), UnitInch
+	}
+	return Round(cm, 1), UnitCentimetre
+}
+
+// WeightFromKg converts kilograms into the weight unit of system.
+func WeightFromKg(kg float64, system string) (float64, string) {
+	if system == UnitSystemImperial {
+		return Round(kg/kilogramsPerPound, 1), UnitPound
+	}
+	return Round(kg, 1), UnitKilogram
+}
+
+// Round rounds v to the given number of decimal places.
+func Round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}